@@ -0,0 +1,235 @@
+package accountant
+
+import (
+	"errors"
+	"time"
+
+	"park/database"
+	"park/service/analytics"
+	"park/util"
+	"park/util/sitetime"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type RevenueReport struct {
+	Range         analytics.Range          `json:"range"`
+	PreviousRange analytics.Range          `json:"previous_range"`
+	Current       []analytics.RevenuePoint `json:"current"`
+	Previous      []analytics.RevenuePoint `json:"previous"`
+	Total         analytics.Delta          `json:"total"`
+}
+
+type VisitReport struct {
+	Range         analytics.Range        `json:"range"`
+	PreviousRange analytics.Range        `json:"previous_range"`
+	Current       []analytics.VisitPoint `json:"current"`
+	Previous      []analytics.VisitPoint `json:"previous"`
+	Visits        analytics.Delta        `json:"visits"`
+	AverageStay   analytics.Delta        `json:"average_stay"`
+}
+
+type PeakHourReport struct {
+	Range         analytics.Range      `json:"range"`
+	PreviousRange analytics.Range      `json:"previous_range"`
+	Current       []analytics.HourCell `json:"current"`
+	Previous      []analytics.HourCell `json:"previous"`
+	Peak          analytics.Delta      `json:"peak"`
+}
+
+type FreeExitReport struct {
+	Range         analytics.Range      `json:"range"`
+	PreviousRange analytics.Range      `json:"previous_range"`
+	Current       []analytics.FreeExit `json:"current"`
+	Previous      []analytics.FreeExit `json:"previous"`
+	Exits         analytics.Delta      `json:"exits"`
+	FreeShare     analytics.Delta      `json:"free_share"`
+}
+
+// analyticsRange reads the start and end dates, both inclusive, and the
+// park. Without dates the last 30 days are used. Days are those of the
+// time zone of the park.
+func analyticsRange(c *fiber.Ctx) (analytics.Range, error) {
+	parkNo := c.Query("parkno")
+	loc := util.ParkLocation(parkNo)
+	today := time.Now().In(loc)
+	start, err := sitetime.ParseDateIn(c.Query("start", today.AddDate(0, 0, -29).Format("2006-01-02")), loc)
+	if err != nil {
+		return analytics.Range{}, err
+	}
+	end, err := sitetime.ParseDateIn(c.Query("end", today.Format("2006-01-02")), loc)
+	if err != nil {
+		return analytics.Range{}, err
+	}
+	if end.Before(start) {
+		return analytics.Range{}, errors.New("end is before start")
+	}
+	return analytics.Range{
+		Start:  sitetime.From(start),
+		End:    sitetime.From(end.AddDate(0, 0, 1)),
+		ParkNo: parkNo,
+		Zone:   loc,
+	}, nil
+}
+
+func analyticsError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, analytics.ErrInvalidInterval) || errors.Is(err, analytics.ErrInvalidGroup) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": "Failed to build the report", "details": err.Error()})
+}
+
+// Revenue godoc
+// @Summary Revenue by period
+// @Description Net takings from the payments ledger by day, week or month, optionally grouped by park, operator or payment method, compared with the previous period of the same length. Amounts are in minor units.
+// @Tags Accountant
+// @Produce json
+// @Param start query string false "First day, default 29 days ago" example("2025-01-01")
+// @Param end query string false "Last day, default today" example("2025-01-31")
+// @Param parkno query string false "Park code"
+// @Param interval query string false "day, week or month" default(day)
+// @Param group query string false "park, operator or method"
+// @Success 200 {object} RevenueReport
+// @Failure 400 {object} map[string]string "Invalid date, interval or group"
+// @Router /api/v1/accountant/analytics/revenue [get]
+func Revenue(c *fiber.Ctx) error {
+	r, err := analyticsRange(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid date range", "details": err.Error()})
+	}
+	interval, group := c.Query("interval", "day"), c.Query("group")
+
+	report := RevenueReport{Range: r, PreviousRange: r.Previous()}
+	if report.Current, err = analytics.Revenue(database.DB, r, interval, group); err != nil {
+		return analyticsError(c, err)
+	}
+	if report.Previous, err = analytics.Revenue(database.DB, report.PreviousRange, interval, group); err != nil {
+		return analyticsError(c, err)
+	}
+
+	var current, previous int64
+	for _, p := range report.Current {
+		current += p.Amount
+	}
+	for _, p := range report.Previous {
+		previous += p.Amount
+	}
+	report.Total = analytics.Compare(float64(current), float64(previous))
+	return c.JSON(report)
+}
+
+// Visits godoc
+// @Summary Visits and average stay
+// @Description Visit counts and the average stay in minutes by day, week or month, compared with the previous period of the same length.
+// @Tags Accountant
+// @Produce json
+// @Param start query string false "First day, default 29 days ago" example("2025-01-01")
+// @Param end query string false "Last day, default today" example("2025-01-31")
+// @Param parkno query string false "Park code"
+// @Param interval query string false "day, week or month" default(day)
+// @Success 200 {object} VisitReport
+// @Failure 400 {object} map[string]string "Invalid date or interval"
+// @Router /api/v1/accountant/analytics/visits [get]
+func Visits(c *fiber.Ctx) error {
+	r, err := analyticsRange(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid date range", "details": err.Error()})
+	}
+	interval := c.Query("interval", "day")
+
+	report := VisitReport{Range: r, PreviousRange: r.Previous()}
+	if report.Current, err = analytics.Visits(database.DB, r, interval); err != nil {
+		return analyticsError(c, err)
+	}
+	if report.Previous, err = analytics.Visits(database.DB, report.PreviousRange, interval); err != nil {
+		return analyticsError(c, err)
+	}
+
+	current, err := analytics.VisitTotals(database.DB, r)
+	if err != nil {
+		return analyticsError(c, err)
+	}
+	previous, err := analytics.VisitTotals(database.DB, report.PreviousRange)
+	if err != nil {
+		return analyticsError(c, err)
+	}
+	report.Visits = analytics.Compare(float64(current.Visits), float64(previous.Visits))
+	report.AverageStay = analytics.Compare(current.AverageStay, previous.AverageStay)
+	return c.JSON(report)
+}
+
+// PeakHours godoc
+// @Summary Peak hour occupancy matrix
+// @Description The number of cars in the park at the start of every hour, averaged by weekday (1 is Monday) and hour, with the highest count seen, compared with the previous period of the same length.
+// @Tags Accountant
+// @Produce json
+// @Param start query string false "First day, default 29 days ago" example("2025-01-01")
+// @Param end query string false "Last day, default today" example("2025-01-31")
+// @Param parkno query string false "Park code"
+// @Success 200 {object} PeakHourReport
+// @Failure 400 {object} map[string]string "Invalid date range"
+// @Router /api/v1/accountant/analytics/peak-hours [get]
+func PeakHours(c *fiber.Ctx) error {
+	r, err := analyticsRange(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid date range", "details": err.Error()})
+	}
+
+	report := PeakHourReport{Range: r, PreviousRange: r.Previous()}
+	if report.Current, err = analytics.PeakHours(database.DB, r); err != nil {
+		return analyticsError(c, err)
+	}
+	if report.Previous, err = analytics.PeakHours(database.DB, report.PreviousRange); err != nil {
+		return analyticsError(c, err)
+	}
+
+	peak := func(cells []analytics.HourCell) float64 {
+		highest := 0
+		for _, cell := range cells {
+			highest = max(highest, cell.Peak)
+		}
+		return float64(highest)
+	}
+	report.Peak = analytics.Compare(peak(report.Current), peak(report.Previous))
+	return c.JSON(report)
+}
+
+// FreeExits godoc
+// @Summary Share of free exits by reason
+// @Description Exits without payment grouped by reason, VIP subscriptions included, with their share of all exits in percent, compared with the previous period of the same length.
+// @Tags Accountant
+// @Produce json
+// @Param start query string false "First day, default 29 days ago" example("2025-01-01")
+// @Param end query string false "Last day, default today" example("2025-01-31")
+// @Param parkno query string false "Park code"
+// @Success 200 {object} FreeExitReport
+// @Failure 400 {object} map[string]string "Invalid date range"
+// @Router /api/v1/accountant/analytics/free-exits [get]
+func FreeExits(c *fiber.Ctx) error {
+	r, err := analyticsRange(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid date range", "details": err.Error()})
+	}
+
+	report := FreeExitReport{Range: r, PreviousRange: r.Previous()}
+	current, exits, err := analytics.FreeExits(database.DB, r)
+	if err != nil {
+		return analyticsError(c, err)
+	}
+	previous, previousExits, err := analytics.FreeExits(database.DB, report.PreviousRange)
+	if err != nil {
+		return analyticsError(c, err)
+	}
+	report.Current, report.Previous = current, previous
+
+	share := func(free []analytics.FreeExit) float64 {
+		total := 0.0
+		for _, f := range free {
+			total += f.Share
+		}
+		return total
+	}
+	report.Exits = analytics.Compare(float64(exits), float64(previousExits))
+	report.FreeShare = analytics.Compare(share(current), share(previous))
+	return c.JSON(report)
+}
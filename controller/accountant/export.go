@@ -0,0 +1,27 @@
+package accountant
+
+import (
+	"park/database"
+	modeloperator "park/models/operatorModel"
+	"park/util/export"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ExportOperators godoc
+// @Summary Export operator sessions
+// @Description Streams all operator sessions, newest first, as CSV or XLSX.
+// @Tags Accountant
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv or xlsx" default(csv)
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string "Unknown format"
+// @Router /api/v1/accountant/operators/export [get]
+func ExportOperators(c *fiber.Ctx) error {
+	query := database.DB.Model(&modeloperator.Operator{}).Order("id DESC")
+	header := []string{"ID", "Operator", "Park", "Login", "Logout", "Money"}
+	return export.Stream(c, query, "operators", header, func(o modeloperator.Operator) []interface{} {
+		return []interface{}{o.ID, o.Operator, o.Park, o.LoginAt, o.LogoutAt, o.Money}
+	})
+}
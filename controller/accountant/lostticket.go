@@ -0,0 +1,77 @@
+package accountant
+
+import (
+	"park/database"
+	modelscar "park/models/modelsCar"
+	modelpayment "park/models/paymentModel"
+	"park/service/ledger"
+	"park/util/sitetime"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type LostTicketReport struct {
+	Cars         []modelscar.Car_Model `json:"cars"`
+	Count        int                   `json:"count"`
+	Estimated    int                   `json:"estimated"`
+	Open         int                   `json:"open"`
+	TotalPayment float64               `json:"total_payment"`
+}
+
+// LostTickets godoc
+// @Summary Report exits without entry
+// @Description Lists lost ticket visits whose exit falls in the time range, with the number that got an estimated entry, the number still open and the net amount paid for them.
+// @Tags Accountant
+// @Produce json
+// @Param start query string false "Exit from" example("2025-01-29 00:00:00")
+// @Param end query string false "Exit until" example("2025-01-29 23:59:59")
+// @Param parkno query string false "Park code"
+// @Success 200 {object} LostTicketReport
+// @Failure 400 {object} map[string]string "Invalid time format"
+// @Failure 500 {object} map[string]string "Failed to fetch cars"
+// @Router /api/v1/accountant/lost-tickets [get]
+func LostTickets(c *fiber.Ctx) error {
+	query := database.DB.Where("no_entry = ?", true)
+
+	if start := c.Query("start"); start != "" {
+		startTime, err := sitetime.Parse(start)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid start time format"})
+		}
+		query = query.Where("end_time >= ?", startTime)
+	}
+	if end := c.Query("end"); end != "" {
+		endTime, err := sitetime.Parse(end)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid end time format"})
+		}
+		query = query.Where("end_time <= ?", endTime)
+	}
+	if parkNo := c.Query("parkno"); parkNo != "" {
+		query = query.Where("park_no = ?", parkNo)
+	}
+
+	var cars []modelscar.Car_Model
+	if err := query.Order("id DESC").Find(&cars).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch cars"})
+	}
+
+	report := LostTicketReport{Cars: cars, Count: len(cars)}
+	ids := make([]int, len(cars))
+	for i, car := range cars {
+		ids[i] = car.ID
+		if car.EntryEstimated {
+			report.Estimated++
+		}
+		if car.Status != "Exited" {
+			report.Open++
+		}
+	}
+
+	total, err := ledger.Sum(database.DB.Model(&modelpayment.Payment{}).Where("car_id IN ?", ids))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to sum payments"})
+	}
+	report.TotalPayment = ledger.FromMinor(total)
+	return c.JSON(report)
+}
@@ -5,8 +5,9 @@ import (
 	"park/database"
 	modelscar "park/models/modelsCar"
 	modeloperator "park/models/operatorModel"
-
-	"time"
+	modelpayment "park/models/paymentModel"
+	"park/service/ledger"
+	"park/util/sitetime"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -29,34 +30,36 @@ func CalculateMoney(c *fiber.Ctx) error {
 	query := database.DB
 
 	if start != "" && end != "" {
-		startTime, err := time.Parse("2006-01-02 15:04:05", start)
+		startTime, err := sitetime.Parse(start)
 		if err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "Invalid start time format"})
 		}
 
-		endTime, err := time.Parse("2006-01-02 15:04:05", end)
+		endTime, err := sitetime.Parse(end)
 		if err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "Invalid end time format"})
 		}
 
-		startTimeStr := startTime.Format("2006-01-02 15:04:05")
-		endTimeStr := endTime.Format("2006-01-02 15:04:05")
-
-		query = query.Where("start_time >= ? AND end_time <= ?", startTimeStr, endTimeStr)
+		query = query.Where("start_time >= ? AND end_time <= ?", startTime, endTime)
 	}
 
 	if err := query.Where("user_id = ?", user).Order("id DESC").Find(&cars).Error; err != nil {
 		fmt.Println("Database error:", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch cars"})
 	}
-	var totalPayment float64
-
-	for _, car := range cars {
-		totalPayment += car.Total_payment
+	ids := make([]int, len(cars))
+	for i, car := range cars {
+		ids[i] = car.ID
 	}
+	total, err := ledger.Sum(database.DB.Model(&modelpayment.Payment{}).Where("operator = ? AND car_id IN ?", user, ids))
+	if err != nil {
+		fmt.Println("Database error:", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to sum payments"})
+	}
+
 	return c.JSON(fiber.Map{
 		"cars":          cars,
-		"total_payment": totalPayment,
+		"total_payment": ledger.FromMinor(total),
 	})
 }
 
@@ -1,8 +1,11 @@
 package admincontrol
 
 import (
+	"strconv"
+
 	"park/database"
 	"park/models/camera"
+	"park/service/audit"
 	"park/util"
 
 	"github.com/gofiber/fiber/v2"
@@ -34,6 +37,7 @@ func CreateCamera(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"message": "Internal server error"})
 	}
 
+	audit.After(c, "camera", strconv.Itoa(cam.Id), cam)
 	return c.Status(201).JSON(cam)
 }
 
@@ -59,6 +63,7 @@ func UpdateCamera(c *fiber.Ctx) error {
 			"message": "Camera not found",
 		})
 	}
+	audit.Before(c, "camera", strconv.Itoa(datacam.Id), datacam)
 
 	var updateCamera camera.Cameras
 	if err := c.BodyParser(&updateCamera); err != nil {
@@ -86,6 +91,7 @@ func UpdateCamera(c *fiber.Ctx) error {
 		})
 	}
 
+	audit.After(c, "camera", strconv.Itoa(datacam.Id), datacam)
 	return c.Status(200).JSON(datacam)
 }
 
@@ -107,6 +113,7 @@ func DeleteCamera(c *fiber.Ctx) error {
 			"message": "Camera not found",
 		})
 	}
+	audit.Before(c, "camera", strconv.Itoa(camera.Id), camera)
 
 	if err := database.DB.Delete(&camera).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{
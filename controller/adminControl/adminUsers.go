@@ -4,11 +4,15 @@ import (
 	"math"
 	"park/database"
 	modelsuser "park/models/modelsUser"
+	"park/service/audit"
+	"park/service/password"
+	"park/service/security"
+	"park/service/session"
 	"park/util"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // CreateUser creates a new user
@@ -32,14 +36,13 @@ func CreateUser(c *fiber.Ctx) error {
 	if err := database.DB.Where("username = ?", user.Username).First(&existingUser).Error; err == nil {
 		return c.Status(400).JSON(fiber.Map{"message": "Username already exists"})
 	}
-	if len(user.Password) < 8 {
-		return c.Status(400).JSON(fiber.Map{"message": "Password must be at least 8 characters long"})
+	if err := password.Current().Check(user.Password, user.Username); err != nil {
+		security.Record(c, security.PasswordRejected, user.Username, err.Error())
+		return c.Status(400).JSON(fiber.Map{"message": err.Error()})
 	}
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
-	if err != nil {
+	if err := password.Set(&user, user.Password, time.Now()); err != nil {
 		return c.Status(500).JSON(fiber.Map{"message": "Error hashing password"})
 	}
-	user.Password = string(hashedPassword)
 	if !util.IsValidRole(user.Role) {
 		return c.Status(400).JSON(fiber.Map{"message": "Invalid role provided"})
 	}
@@ -47,6 +50,7 @@ func CreateUser(c *fiber.Ctx) error {
 	if err := database.DB.Create(&user).Error; err != nil {
 		return c.Status(500).JSON("can not create")
 	}
+	audit.After(c, "user", strconv.Itoa(user.Id), user)
 	return c.Status(200).JSON(user)
 }
 
@@ -176,6 +180,8 @@ func UserUpdate(c *fiber.Ctx) error {
 	}
 
 	// Güncellenmiş alanları kontrol ediyoruz ve yalnızca mevcut veriyi güncelliyoruz
+	audit.Before(c, "user", strconv.Itoa(user.Id), user)
+	oldRole := user.Role
 	if updatedUser.Username != "" {
 		user.Username = updatedUser.Username
 	}
@@ -186,13 +192,17 @@ func UserUpdate(c *fiber.Ctx) error {
 		user.Lastname = updatedUser.Lastname
 	}
 	if updatedUser.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(updatedUser.Password), bcrypt.DefaultCost)
-		if err != nil {
+		if err := password.Current().Check(updatedUser.Password, user.Username); err != nil {
+			security.Record(c, security.PasswordRejected, user.Username, err.Error())
+			return c.Status(400).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		if err := password.Set(&user, updatedUser.Password, time.Now()); err != nil {
 			return c.Status(500).JSON(fiber.Map{
 				"message": "Error hashing password",
 			})
 		}
-		user.Password = string(hashedPassword)
 	}
 	if updatedUser.IsActive != user.IsActive {
 		user.IsActive = updatedUser.IsActive
@@ -216,6 +226,27 @@ func UserUpdate(c *fiber.Ctx) error {
 		})
 	}
 
+	// A deactivated user, a new password or a new role ends the sessions
+	// of the user.
+	reason := ""
+	switch {
+	case !user.IsActive:
+		reason = session.ReasonDeactivated
+	case updatedUser.Password != "":
+		reason = session.ReasonPassword
+	case user.Role != oldRole:
+		reason = session.ReasonRole
+	}
+	if reason != "" {
+		if err := session.RevokeUser(database.DB, user.Id, reason); err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"message": "User updated but failed to end the sessions",
+			})
+		}
+	}
+
+	audit.After(c, "user", strconv.Itoa(user.Id), user)
+
 	// Güncellenmiş kullanıcıyı döndürüyoruz
 	userRes := modelsuser.UserRes{
 		Id:        user.Id,
@@ -250,11 +281,17 @@ func UserDelete(c *fiber.Ctx) error {
 		})
 	}
 
+	audit.Before(c, "user", strconv.Itoa(user.Id), user)
 	if err := database.DB.Delete(&user).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"message": "Error deleting user",
 		})
 	}
+	if err := session.RevokeUser(database.DB, user.Id, session.ReasonDeleted); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"message": "User deleted but failed to end the sessions",
+		})
+	}
 
 	return c.Status(200).JSON(fiber.Map{
 		"message": "User deleted successfully",
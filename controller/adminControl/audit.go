@@ -0,0 +1,106 @@
+package admincontrol
+
+import (
+	"math"
+	"park/database"
+	modelaudit "park/models/auditModel"
+	"park/service/audit"
+	"park/util/sitetime"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetAuditEntries lists the audit trail
+// @Summary Get the audit trail
+// @Description Lists the mutating requests of users, newest first, with the entity they changed and its state before and after.
+// @Tags Audit
+// @Produce json
+// @Param page query int false "Page number"
+// @Param limit query int false "Limit per page"
+// @Param actor query string false "Username of the actor"
+// @Param role query string false "Role of the actor"
+// @Param entity query string false "Entity" example("user")
+// @Param entity_id query string false "Entity ID"
+// @Param action query string false "Method and route" example("PUT /api/v1/users/:id")
+// @Param start query string false "From" example("2025-01-29 00:00:00")
+// @Param end query string false "Until" example("2025-01-29 23:59:59")
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string "Invalid time format"
+// @Failure 500 {object} map[string]string "Can not retrieve audit entries"
+// @Router /api/v1/audit [get]
+func GetAuditEntries(c *fiber.Ctx) error {
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "20"))
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+
+	query := database.DB.Model(&modelaudit.Entry{})
+	for param, column := range map[string]string{
+		"actor":     "actor",
+		"role":      "role",
+		"entity":    "entity",
+		"entity_id": "entity_id",
+		"action":    "action",
+	} {
+		if value := c.Query(param); value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+	if start := c.Query("start"); start != "" {
+		startTime, err := sitetime.Parse(start)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"message": "Invalid start time format"})
+		}
+		query = query.Where("at >= ?", startTime)
+	}
+	if end := c.Query("end"); end != "" {
+		endTime, err := sitetime.Parse(end)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"message": "Invalid end time format"})
+		}
+		query = query.Where("at <= ?", endTime)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Can not retrieve audit entries"})
+	}
+
+	entries := []modelaudit.Entry{}
+	if err := query.Order("id DESC").Limit(limit).Offset((page - 1) * limit).Find(&entries).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Can not retrieve audit entries"})
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+	return c.JSON(fiber.Map{
+		"entries":    entries,
+		"page":       page,
+		"limit":      limit,
+		"total":      total,
+		"totalPages": totalPages,
+		"hasNext":    page < totalPages,
+		"hasPrev":    page > 1,
+	})
+}
+
+// VerifyAudit checks the hash chain of the audit trail
+// @Summary Verify the audit trail
+// @Description Recomputes the hash chain of the audit trail from the first entry. broken_at is the first entry that was changed, or that follows a removed one. Keep last_hash elsewhere to detect the removal of the newest entries.
+// @Tags Audit
+// @Produce json
+// @Success 200 {object} audit.Verification
+// @Failure 500 {object} map[string]string "Can not verify the audit trail"
+// @Router /api/v1/audit/verify [get]
+func VerifyAudit(c *fiber.Ctx) error {
+	result, err := audit.Verify(database.DB)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Can not verify the audit trail"})
+	}
+	return c.JSON(result)
+}
@@ -0,0 +1,64 @@
+package admincontrol
+
+import (
+	"errors"
+	"park/database"
+	"park/middleware"
+	modelsuser "park/models/modelsUser"
+	"park/service/audit"
+	"park/util"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type PermissionInput struct {
+	Roles []modelsuser.RoleType `json:"roles" example:"accountant"`
+}
+
+// GetPermissions returns the permission matrix
+// @Summary Get the permission matrix
+// @Description Lists every permission with the roles that hold it. Admins hold every permission.
+// @Tags Users
+// @Produce json
+// @Success 200 {object} map[string][]modelsuser.RoleType
+// @Router /api/v1/permissions [get]
+func GetPermissions(c *fiber.Ctx) error {
+	return c.JSON(middleware.Matrix())
+}
+
+// UpdatePermission sets the roles of a permission
+// @Summary Set the roles of a permission
+// @Description Replaces the roles that hold a permission. The change applies to the next request.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param name path string true "Permission name"
+// @Param roles body PermissionInput true "Roles"
+// @Success 200 {object} map[string][]modelsuser.RoleType
+// @Failure 400 {object} map[string]string "Invalid role"
+// @Failure 404 {object} map[string]string "Unknown permission"
+// @Router /api/v1/permissions/{name} [put]
+func UpdatePermission(c *fiber.Ctx) error {
+	var input PermissionInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"message": "Failed to parse request body"})
+	}
+	for _, role := range input.Roles {
+		if !util.IsValidRole(role) {
+			return c.Status(400).JSON(fiber.Map{"message": "Invalid role " + string(role)})
+		}
+	}
+
+	name := c.Params("name")
+	audit.Before(c, "permission", name, fiber.Map{"roles": middleware.Matrix()[name]})
+	err := middleware.SetPermission(database.DB, name, input.Roles)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(404).JSON(fiber.Map{"message": "Unknown permission"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Internal server error"})
+	}
+	audit.After(c, "permission", name, fiber.Map{"roles": middleware.Matrix()[name]})
+	return c.JSON(middleware.Matrix())
+}
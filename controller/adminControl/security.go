@@ -0,0 +1,81 @@
+package admincontrol
+
+import (
+	"math"
+	"park/database"
+	modelsuser "park/models/modelsUser"
+	"park/util/sitetime"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetSecurityEvents lists the security event log
+// @Summary Get security events
+// @Description Lists failed and refused authentications, newest first, with pagination.
+// @Tags Users
+// @Produce json
+// @Param page query int false "Page number"
+// @Param limit query int false "Limit per page"
+// @Param kind query string false "Event kind" Enums(login_failed, login_throttled, login_locked, password_expired, password_rejected, password_changed, refresh_failed, token_rejected, permission_denied)
+// @Param username query string false "Username"
+// @Param start query string false "From" example("2025-01-29 00:00:00")
+// @Param end query string false "Until" example("2025-01-29 23:59:59")
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string "Invalid time format"
+// @Failure 500 {object} map[string]string "Can not retrieve security events"
+// @Router /api/v1/security-events [get]
+func GetSecurityEvents(c *fiber.Ctx) error {
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "20"))
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+
+	query := database.DB.Model(&modelsuser.SecurityEvent{})
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if username := c.Query("username"); username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if start := c.Query("start"); start != "" {
+		startTime, err := sitetime.Parse(start)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"message": "Invalid start time format"})
+		}
+		query = query.Where("created_at >= ?", startTime)
+	}
+	if end := c.Query("end"); end != "" {
+		endTime, err := sitetime.Parse(end)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"message": "Invalid end time format"})
+		}
+		query = query.Where("created_at <= ?", endTime)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Can not retrieve security events"})
+	}
+
+	events := []modelsuser.SecurityEvent{}
+	if err := query.Order("id DESC").Limit(limit).Offset((page - 1) * limit).Find(&events).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Can not retrieve security events"})
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+	return c.JSON(fiber.Map{
+		"events":     events,
+		"page":       page,
+		"limit":      limit,
+		"total":      total,
+		"totalPages": totalPages,
+		"hasNext":    page < totalPages,
+		"hasPrev":    page > 1,
+	})
+}
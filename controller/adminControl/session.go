@@ -0,0 +1,121 @@
+package admincontrol
+
+import (
+	"strconv"
+
+	"park/database"
+	modelsuser "park/models/modelsUser"
+	"park/service/audit"
+	"park/service/security"
+	"park/service/session"
+	"park/service/twofactor"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetUserSessions lists the sessions of a user
+// @Summary List the sessions of a user
+// @Description Lists the sessions of a user that are neither revoked nor expired, most recently used first.
+// @Tags Users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {array} modelsuser.Session
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/users/{id}/sessions [get]
+func GetUserSessions(c *fiber.Ctx) error {
+	var user modelsuser.User
+	if err := database.DB.First(&user, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"message": "User not found"})
+	}
+
+	sessions, err := session.List(database.DB, user.Id)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Internal server error"})
+	}
+	return c.JSON(sessions)
+}
+
+// RevokeUserSessions ends every session of a user
+// @Summary End every session of a user
+// @Description Revokes every session of a user. Their access tokens are refused at once and their refresh tokens stop working.
+// @Tags Users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string "Sessions revoked"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/users/{id}/sessions [delete]
+func RevokeUserSessions(c *fiber.Ctx) error {
+	var user modelsuser.User
+	if err := database.DB.First(&user, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"message": "User not found"})
+	}
+
+	sessions, err := session.List(database.DB, user.Id)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Internal server error"})
+	}
+	audit.Before(c, "sessions", strconv.Itoa(user.Id), sessions)
+
+	if err := session.RevokeUser(database.DB, user.Id, session.ReasonRevoked); err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Internal server error"})
+	}
+	return c.JSON(fiber.Map{"message": "Sessions revoked"})
+}
+
+// RevokeUserSession ends one session of a user
+// @Summary End a session of a user
+// @Description Revokes one session of a user.
+// @Tags Users
+// @Produce json
+// @Param id path int true "User ID"
+// @Param session path string true "Session ID"
+// @Success 200 {object} map[string]string "Session revoked"
+// @Failure 404 {object} map[string]string "Session not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/users/{id}/sessions/{session} [delete]
+func RevokeUserSession(c *fiber.Ctx) error {
+	var s modelsuser.Session
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Params("session"), c.Params("id")).First(&s).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"message": "Session not found"})
+	}
+	audit.Before(c, "session", s.Id, s)
+
+	if err := session.Revoke(database.DB, s.Id, session.ReasonRevoked); err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Internal server error"})
+	}
+	database.DB.First(&s, "id = ?", s.Id)
+	audit.After(c, "session", s.Id, s)
+	return c.JSON(fiber.Map{"message": "Session revoked"})
+}
+
+// ResetTwoFactor removes the two-factor authentication of a user
+// @Summary Reset the two-factor authentication of a user
+// @Description Removes the TOTP secret and the recovery codes of a user who lost the authenticator, and ends the sessions of the user. A role that requires two-factor authentication sets it up again at the next login.
+// @Tags Users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string "Two-factor authentication reset"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/users/{id}/2fa [delete]
+func ResetTwoFactor(c *fiber.Ctx) error {
+	var user modelsuser.User
+	if err := database.DB.First(&user, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"message": "User not found"})
+	}
+	var tf modelsuser.TwoFactor
+	database.DB.Where("user_id = ?", user.Id).Limit(1).Find(&tf)
+	audit.Before(c, "two_factor", strconv.Itoa(user.Id), tf)
+
+	if err := twofactor.Disable(database.DB, user.Id); err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Internal server error"})
+	}
+	if err := session.RevokeUser(database.DB, user.Id, session.ReasonTwoFactor); err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Internal server error"})
+	}
+	admin, _ := c.Locals("username").(string)
+	security.Record(c, security.TwoFactorReset, user.Username, "by "+admin)
+	return c.JSON(fiber.Map{"message": "Two-factor authentication reset"})
+}
@@ -1,18 +1,28 @@
 package usercontrol
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 
 	"park/controller/realtime"
 	"park/database"
 	"park/middleware"
 	"park/models/camera"
 	modelsuser "park/models/modelsUser"
+	modelpark "park/models/parkModel"
+	"park/service/loginguard"
+	"park/service/password"
+	"park/service/security"
+	"park/service/session"
+	"park/service/twofactor"
 	"park/util"
 )
 
@@ -34,7 +44,7 @@ type UserResponse struct {
 }
 
 // @Summary      Register User
-// @Description  Creates a new user and stores their hashed password. Example: { "username": "newUser", "password": "password123", "firstname": "John", "lastname": "Doe", "role": "admin" }
+// @Description  Creates a new user and stores their hashed password. Users registered by an admin stay inactive until they are activated; the first user of a fresh install is created as an active admin. Example: { "username": "newUser", "password": "password123", "firstname": "John", "lastname": "Doe", "role": "admin" }
 // @Tags         Auth
 // @Accept       json
 // @Produce      json
@@ -50,7 +60,7 @@ type UserResponse struct {
 // @Success      201 {object} map[string]string "message: User Created"
 // @Failure      400 {object} map[string]string "message: Bad Request"
 // @Failure      400 {object} map[string]string "message: Username already exists"
-// @Failure      400 {object} map[string]string "message: Password must be at least 8 characters long"
+// @Failure      400 {object} map[string]string "message: Password does not follow the password policy"
 // @Failure      500 {object} map[string]string "message: Internal Server Error"
 // @Router       /api/v1/auth/register [post]
 func Register(c *fiber.Ctx) error {
@@ -60,20 +70,22 @@ func Register(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"message": "Bad Request", "error": err.Error()})
 	}
 	user.IsActive = false
-	var existingUser modelsuser.User
-	if err := database.DB.Where("username = ?", user.Username).First(&existingUser).Error; err == nil {
+	if first, _ := c.Locals(firstUser).(bool); first {
+		user.IsActive = true
+		user.Role = modelsuser.AdminRole
+	}
+	if usernameTaken(user.Username) {
 		return c.Status(400).JSON(fiber.Map{"message": "Username already exists"})
 	}
-	if len(user.Password) < 8 {
-		return c.Status(400).JSON(fiber.Map{"message": "Password must be at least 8 characters long"})
+	if err := password.Current().Check(user.Password, user.Username); err != nil {
+		security.Record(c, security.PasswordRejected, user.Username, err.Error())
+		return c.Status(400).JSON(fiber.Map{"message": err.Error()})
 	}
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
-	if err != nil {
+	if err := password.Set(&user, user.Password, time.Now()); err != nil {
 		return c.Status(500).JSON(fiber.Map{"message": "Error hashing password"})
 	}
-	user.Password = string(hashedPassword)
 
-	if err := database.DB.Create(&user).Error; err != nil {
+	if err := createUser(&user); err != nil {
 		return c.Status(500).JSON(fiber.Map{"message": "Internal Server Error", "error": err.Error()})
 	}
 
@@ -81,7 +93,7 @@ func Register(c *fiber.Ctx) error {
 }
 
 // @Summary      Login User
-// @Description   { "username": "Dowran", "password": "12345678", "parkno": "P4" }
+// @Description   { "username": "Dowran", "password": "12345678", "parkno": "P4" }. parkno is the code of a park; the name of one of its outside cameras or a prefix of it ("P4-1") still works as before.
 // @Tags         Auth
 // @Accept       json
 // @Produce      json
@@ -89,12 +101,16 @@ func Register(c *fiber.Ctx) error {
 //	@Param        credentials body LoginInput true "User Login Data" {
 //	  "username": "Dowran",
 //	  "password": "12345678",
-//	  "parkno": "P4-1"
+//	  "parkno": "P4"
 //	}
 //
 // @Success      200 {object} map[string]string "message: Login successful"
+// @Success      202 {object} map[string]interface{} "message: Two-factor code required, with the challenge for /api/v1/auth/2fa/challenge"
 // @Failure      400 {object} map[string]string "message: Invalid request body"
 // @Failure      401 {object} map[string]string "message: Invalid username or password"
+// @Failure      401 {object} map[string]string "message: Invalid Parkno"
+// @Failure      403 {object} map[string]interface{} "message: Password expired"
+// @Failure      429 {object} map[string]interface{} "message: Too many login attempts"
 // @Failure      500 {object} map[string]string "message: Internal Server Error"
 // @Router       /api/v1/auth/login [post]
 func Login(c *fiber.Ctx) error {
@@ -109,74 +125,347 @@ func Login(c *fiber.Ctx) error {
 		})
 	}
 
+	if wait, locked := loginguard.Wait(loginInput.Username, c.IP()); wait > 0 {
+		return tooManyAttempts(c, loginInput.Username, wait, locked)
+	}
+
 	var user modelsuser.User
 	if err := database.DB.Where("username = ?", loginInput.Username).First(&user).Error; err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Invalid username or password",
-		})
+		return loginFailed(c, loginInput.Username, "unknown username", "Invalid username or password")
 	}
 
-	if !user.IsActive {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Account is not active",
-		})
+	if reason, message := checkCredentials(user, loginInput.Password); reason != "" {
+		return loginFailed(c, user.Username, reason, message)
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginInput.Password)); err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Invalid username or password",
+	park, err := loginPark(loginInput.ParkNo)
+	if err != nil {
+		return loginFailed(c, user.Username, "unknown park "+loginInput.ParkNo, "Invalid Parkno")
+	}
+
+	if password.Current().Expired(user, time.Now()) {
+		security.Record(c, security.PasswordExpired, user.Username, "")
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message":          "Password expired",
+			"password_expired": true,
 		})
 	}
 
-	var camFixes []camera.CamFix
-	if err := database.DB.Where("channel_name LIKE ? AND type = ?", loginInput.ParkNo+"%", "outside").Find(&camFixes).Error; err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Invalid Parkno or No matching records with 'outside' type",
+	enabled, err := twofactor.Enabled(database.DB, user.Id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
+	if enabled || twofactor.Required(user.Role) {
+		challenge, err := twofactor.NewChallenge(user.Id, park.Code, !enabled)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Internal Server Error",
+			})
+		}
+		message := "Two-factor code required"
+		if !enabled {
+			message = "Two-factor setup required"
+		}
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"message":                   message,
+			"two_factor_required":       enabled,
+			"two_factor_setup_required": !enabled,
+			"challenge":                 challenge,
 		})
 	}
 
-	camFixesJson, err := json.Marshal(camFixes)
+	// Failed attempts are only forgiven once every factor has passed;
+	// logins with a second factor are forgiven by Challenge.
+	loginguard.Succeed(user.Username)
+	return startSession(c, user, park.Code, nil)
+}
+
+// startSession completes a login: it opens a session of user in the park
+// and responds with the tokens. extra is added to the response.
+func startSession(c *fiber.Ctx, user modelsuser.User, parkNo string, extra fiber.Map) error {
+	s, refreshToken, err := session.Start(database.DB, user, parkNo, c.Get(fiber.HeaderUserAgent), c.IP())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Error marshalling camFixes",
+			"message": "Error creating session",
+		})
+	}
+	if err := setTokens(c, user, s, refreshToken); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error creating JWT",
 		})
 	}
+	middleware.SetParkNoCookie(c, parkNo)
 
-	keys := string(camFixesJson)
+	util.LoginMath(user.Username, string(user.Role), parkNo)
 
-	var macuser modelsuser.MacUser
-	if err := database.DB.Where("id = ?", 1).First(&macuser).Error; err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "MacUser with id 1 not found",
+	response := fiber.Map{
+		"message":    "Login successful",
+		"role":       user.Role,
+		"expires_in": int(session.AccessTTL.Seconds()),
+	}
+	for key, value := range extra {
+		response[key] = value
+	}
+	return c.JSON(response)
+}
+
+type ChangePasswordInput struct {
+	Username    string `json:"username" example:"Dowran"`
+	Password    string `json:"password" example:"12345678"`
+	NewPassword string `json:"new_password" example:"N3w-password"`
+	Code        string `json:"code" example:"123456"` // needed when two-factor authentication is enabled
+}
+
+// @Summary      Change password
+// @Description  Replaces the password of a user who knows the current one. It also works when the password has expired. Attempts are throttled like logins, and every session of the user is ended.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        credentials body ChangePasswordInput true "Current and new password"
+// @Success      200 {object} map[string]string "message: Password changed"
+// @Failure      400 {object} map[string]string "message: Password does not follow the password policy"
+// @Failure      401 {object} map[string]string "message: Invalid username or password"
+// @Failure      429 {object} map[string]interface{} "message: Too many login attempts"
+// @Failure      500 {object} map[string]string "message: Internal Server Error"
+// @Router       /api/v1/auth/password [post]
+func ChangePassword(c *fiber.Ctx) error {
+	var input ChangePasswordInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Bad Request",
 		})
 	}
 
-	token, err := util.CreateJWT(user.Id, user.Username, user.Role, loginInput.ParkNo, macuser.MacUsername, macuser.MacPassword, keys)
+	if wait, locked := loginguard.Wait(input.Username, c.IP()); wait > 0 {
+		return tooManyAttempts(c, input.Username, wait, locked)
+	}
+
+	var user modelsuser.User
+	if err := database.DB.Where("username = ?", input.Username).First(&user).Error; err != nil {
+		return loginFailed(c, input.Username, "unknown username", "Invalid username or password")
+	}
+	if reason, message := checkCredentials(user, input.Password); reason != "" {
+		return loginFailed(c, user.Username, reason, message)
+	}
+	enabled, err := twofactor.Enabled(database.DB, user.Id)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Error creating JWT",
+			"message": "Internal Server Error",
 		})
 	}
-	middleware.SetParkNoCookie(c, loginInput.ParkNo)
+	if enabled {
+		if err := twofactor.Verify(database.DB, user.Id, input.Code, time.Now()); err != nil {
+			return codeFailed(c, user.Username, err)
+		}
+	}
+	loginguard.Succeed(user.Username)
+
+	if input.NewPassword == input.Password {
+		security.Record(c, security.PasswordRejected, user.Username, "new password equals the old one")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "New password must differ from the current one",
+		})
+	}
+	if err := password.Current().Check(input.NewPassword, user.Username); err != nil {
+		security.Record(c, security.PasswordRejected, user.Username, err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	if err := password.Set(&user, input.NewPassword, time.Now()); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error hashing password",
+		})
+	}
+	if err := database.DB.Model(&user).Updates(map[string]interface{}{
+		"password":            user.Password,
+		"password_changed_at": user.PasswordChangedAt,
+	}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
+	if err := session.RevokeUser(database.DB, user.Id, session.ReasonPassword); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Password changed but failed to end the sessions",
+		})
+	}
+	security.Record(c, security.PasswordChanged, user.Username, "")
+
+	return c.JSON(fiber.Map{
+		"message": "Password changed",
+	})
+}
+
+// loginFailed counts a failed login of username and records it.
+func loginFailed(c *fiber.Ctx, username, detail, message string) error {
+	locked := loginguard.Fail(username, c.IP())
+	security.Record(c, security.LoginFailed, username, detail)
+	if locked {
+		security.Record(c, security.LoginLocked, username, "")
+	}
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"message": message,
+	})
+}
+
+// loginPark resolves the park a user logs in to. Clients used to send the
+// name of an outside camera or a prefix of it, such as "P4-1" or "P4", so
+// when no park has that code the park of the first matching outside camera
+// is used.
+func loginPark(parkNo string) (modelpark.Park, error) {
+	var park modelpark.Park
+	err := database.DB.Where("code = ?", parkNo).First(&park).Error
+	if parkNo == "" || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return park, err
+	}
+
+	var cam camera.CamFix
+	err = database.DB.Where("channel_name LIKE ? AND type = ? AND park_id IS NOT NULL", likePrefix(parkNo), camera.Outside).
+		Order("channel_name").First(&cam).Error
+	if err != nil {
+		return park, err
+	}
+	err = database.DB.First(&park, *cam.ParkId).Error
+	return park, err
+}
+
+// likePrefix escapes the wildcards of a LIKE pattern matching values that
+// start with prefix.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+}
+
+// tooManyAttempts refuses a login that came before the wait of its
+// username or address was over.
+func tooManyAttempts(c *fiber.Ctx, username string, wait time.Duration, locked bool) error {
+	seconds := int(math.Ceil(wait.Seconds()))
+	detail := fmt.Sprintf("retry after %ds", seconds)
+	message := "Too many login attempts"
+	if locked {
+		detail = "locked, " + detail
+		message = "Login temporarily locked"
+	}
+	security.Record(c, security.LoginThrottled, username, detail)
+
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"message":     message,
+		"retry_after": seconds,
+	})
+}
+
+// setTokens sets the access token of a session and its refresh token as
+// cookies. The refresh token is only sent to the auth routes.
+func setTokens(c *fiber.Ctx, user modelsuser.User, s modelsuser.Session, refreshToken string) error {
+	token, err := util.CreateJWT(user.Id, user.Username, user.Role, s.ParkNo, s.Id)
+	if err != nil {
+		return err
+	}
 	c.Cookie(&fiber.Cookie{
 		Name:     "jwt",
 		Value:    token,
 		HTTPOnly: true,
 		SameSite: "Strict",
-		MaxAge:   86400,
+		MaxAge:   int(session.AccessTTL.Seconds()),
 	})
+	c.Cookie(&fiber.Cookie{
+		Name:     "refresh_token",
+		Value:    refreshToken,
+		Path:     "/api/v1/auth",
+		HTTPOnly: true,
+		SameSite: "Strict",
+		MaxAge:   int(session.RefreshTTL.Seconds()),
+	})
+	return nil
+}
 
-	util.LoginMath(user.Username, string(user.Role), loginInput.ParkNo)
+// clearTokens removes the cookies set by setTokens.
+func clearTokens(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     "jwt",
+		Value:    "",
+		HTTPOnly: true,
+		SameSite: "Strict",
+		Expires:  time.Now().Add(-time.Hour),
+		MaxAge:   -1,
+	})
+	c.Cookie(&fiber.Cookie{
+		Name:     "refresh_token",
+		Value:    "",
+		Path:     "/api/v1/auth",
+		HTTPOnly: true,
+		SameSite: "Strict",
+		Expires:  time.Now().Add(-time.Hour),
+		MaxAge:   -1,
+	})
+}
+
+// @Summary      Refresh the access token
+// @Description  Exchanges the refresh_token cookie for a new access token and a new refresh token. Each refresh token can be used once; a replaced token is still accepted for 30 seconds so that concurrent refreshes succeed, and using it later revokes its session. Sessions end 30 days after the login however often they are refreshed. Sessions of users whose role requires two-factor authentication but who have not set it up are ended.
+// @Tags         Auth
+// @Produce      json
+// @Success      200 {object} map[string]interface{} "message: Token refreshed"
+// @Failure      401 {object} map[string]string "message: Invalid refresh token"
+// @Failure      500 {object} map[string]string "message: Internal Server Error"
+// @Router       /api/v1/auth/refresh [post]
+func Refresh(c *fiber.Ctx) error {
+	s, user, refreshToken, err := session.Refresh(database.DB, c.Cookies("refresh_token"), c.Get(fiber.HeaderUserAgent), c.IP())
+	switch {
+	case errors.Is(err, session.ErrInvalid), errors.Is(err, session.ErrExpired),
+		errors.Is(err, session.ErrRevoked), errors.Is(err, session.ErrReused), errors.Is(err, session.ErrInactive):
+		security.Record(c, security.RefreshFailed, s.Username, err.Error())
+		clearTokens(c)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid refresh token",
+			"error":   err.Error(),
+		})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
 
-	role := user.Role
+	// Sessions opened before the role of the user required two-factor
+	// authentication end here; the user logs in again and sets it up.
+	if twofactor.Required(user.Role) {
+		enabled, err := twofactor.Enabled(database.DB, user.Id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Internal Server Error",
+			})
+		}
+		if !enabled {
+			if err := session.Revoke(database.DB, s.Id, session.ReasonTwoFactorMissing); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"message": "Internal Server Error",
+				})
+			}
+			security.Record(c, security.RefreshFailed, user.Username, session.ReasonTwoFactorMissing)
+			clearTokens(c)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message":                   "Two-factor setup required, log in again",
+				"two_factor_setup_required": true,
+			})
+		}
+	}
+
+	if err := setTokens(c, user, s, refreshToken); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error creating JWT",
+		})
+	}
 	return c.JSON(fiber.Map{
-		"message": "Login successful",
-		"role":    role,
+		"message":    "Token refreshed",
+		"role":       user.Role,
+		"expires_in": int(session.AccessTTL.Seconds()),
 	})
 }
 
 // @Summary      Logout User
-// @Description  Ends the session of a logged-in user by deleting the JWT token cookie.
+// @Description  Ends the session of a logged-in user: the session is revoked and the token cookies are deleted.
 // @Tags         Auth
 // @Produce      json
 // @Success      200 {object} map[string]string "message: Logout successful"
@@ -186,14 +475,13 @@ func Logout(c *fiber.Ctx) error {
 	userIDVal := c.Locals("username")
 	roleVal := c.Locals("role")
 	parkno := c.Locals("parkno")
-	c.Cookie(&fiber.Cookie{
-		Name:     "jwt",
-		Value:    "",
-		HTTPOnly: true,
-		SameSite: "Strict",
-		Expires:  time.Now().Add(-time.Hour),
-		MaxAge:   -1,
-	})
+	sessionID, _ := c.Locals("session_id").(string)
+	if err := session.Revoke(database.DB, sessionID, session.ReasonLogout); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error - Failed to end the session",
+		})
+	}
+	clearTokens(c)
 	role, ok := roleVal.(string)
 	if !ok {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -236,7 +524,7 @@ func Logout(c *fiber.Ctx) error {
 }
 
 // @Summary      Get current user information
-// @Description  Retrieves the current user's username, role, and user ID from the JWT token.
+// @Description  Retrieves the current user's username, role, and user ID from the JWT token, and the exit cameras of the park as keys.
 // @Tags         Auth
 // @Accept       json
 // @Produce      json
@@ -250,9 +538,6 @@ func Me(c *fiber.Ctx) error {
 	roleVal := c.Locals("role")
 	userIDVal := c.Locals("user_id")
 	parkno := c.Locals("parkno")
-	macusername := c.Locals("macusername")
-	macpassword := c.Locals("macpassword")
-	keysVal := c.Locals("keys")
 
 	if usernameVal == nil || roleVal == nil || userIDVal == nil || parkno == nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -265,31 +550,73 @@ func Me(c *fiber.Ctx) error {
 	role, _ := roleVal.(string)
 	userID, _ := userIDVal.(string)
 
-	keys, _ := keysVal.(string)
-	var camFixes []camera.CamFix
-	if keys != "N/A" && keys != "" {
-		parsedKeys, err := ParseKeys(keys)
-		if err == nil {
-			camFixes = parsedKeys
-		}
+	camFixes := []camera.CamFix{}
+	if err := database.DB.Joins("JOIN parks ON parks.id = cam_fixes.park_id").
+		Where("parks.code = ? AND cam_fixes.type = ?", park, "outside").Find(&camFixes).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error - Failed to load cameras",
+		})
 	}
 
 	return c.JSON(fiber.Map{
-		"username":    username,
-		"role":        role,
-		"user_id":     userID,
-		"parkno":      park,
-		"macusername": macusername,
-		"macpassword": macpassword,
-		"keys":        camFixes,
+		"username": username,
+		"role":     role,
+		"user_id":  userID,
+		"parkno":   park,
+		"keys":     camFixes,
 	})
 }
 
-func ParseKeys(keysJson string) ([]camera.CamFix, error) {
-	var camFixes []camera.CamFix
-	err := json.Unmarshal([]byte(keysJson), &camFixes)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse keys JSON: %w", err)
+// checkCredentials returns why user may not log in with password, as the
+// reason for the security log and the message for the client, or empty
+// strings when the account is active and the password matches.
+func checkCredentials(user modelsuser.User, password string) (reason, message string) {
+	if !user.IsActive {
+		return "account is not active", "Account is not active"
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return "wrong password", "Invalid username or password"
+	}
+	return "", ""
+}
+
+// firstUser is the local FirstUserOr sets when it lets the first user of a
+// fresh install register.
+const firstUser = "first_user"
+
+// The users table is reached through these so tests can run without a
+// database.
+var (
+	countUsers = func() (int64, error) {
+		var count int64
+		err := database.DB.Model(&modelsuser.User{}).Count(&count).Error
+		return count, err
+	}
+	usernameTaken = func(username string) bool {
+		var existing modelsuser.User
+		return database.DB.Where("username = ?", username).First(&existing).Error == nil
+	}
+	createUser = func(user *modelsuser.User) error {
+		return database.DB.Create(user).Error
+	}
+)
+
+// FirstUserOr lets anyone register while there are no users, so that the
+// first admin can be created, and hands the request to guard otherwise.
+// Nobody could activate the first user, so Register creates it as an active
+// admin.
+func FirstUserOr(guard fiber.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		count, err := countUsers()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Internal Server Error",
+			})
+		}
+		if count == 0 {
+			c.Locals(firstUser, true)
+			return c.Next()
+		}
+		return guard(c)
 	}
-	return camFixes, nil
 }
@@ -0,0 +1,65 @@
+package usercontrol
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	modelsuser "park/models/modelsUser"
+)
+
+func TestFirstRegisteredUserCanLogIn(t *testing.T) {
+	defer func(count func() (int64, error), taken func(string) bool, create func(*modelsuser.User) error) {
+		countUsers, usernameTaken, createUser = count, taken, create
+	}(countUsers, usernameTaken, createUser)
+
+	var users []modelsuser.User
+	countUsers = func() (int64, error) { return int64(len(users)), nil }
+	usernameTaken = func(username string) bool {
+		for _, u := range users {
+			if u.Username == username {
+				return true
+			}
+		}
+		return false
+	}
+	createUser = func(user *modelsuser.User) error {
+		users = append(users, *user)
+		return nil
+	}
+
+	app := fiber.New()
+	// The guard of an admin session, which lets the request through.
+	app.Post("/register", FirstUserOr(func(c *fiber.Ctx) error { return c.Next() }), Register)
+	register := func(body string) {
+		req := httptest.NewRequest(fiber.MethodPost, "/register", strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusCreated {
+			t.Fatalf("register answered %d, want 201", resp.StatusCode)
+		}
+	}
+
+	register(`{"username":"owner","password":"Owner-pass1","role":"operator","isActive":false}`)
+	first := users[0]
+	if !first.IsActive || first.Role != modelsuser.AdminRole {
+		t.Fatalf("first user registered active=%v role=%q, want an active admin", first.IsActive, first.Role)
+	}
+	if reason, _ := checkCredentials(first, "Owner-pass1"); reason != "" {
+		t.Fatalf("first user can not log in: %s", reason)
+	}
+
+	register(`{"username":"clerk","password":"Clerk-pass1","role":"operator","isActive":true}`)
+	second := users[1]
+	if second.IsActive || second.Role != modelsuser.OperatorRole {
+		t.Fatalf("second user registered active=%v role=%q, want an inactive operator", second.IsActive, second.Role)
+	}
+	if reason, _ := checkCredentials(second, "Clerk-pass1"); reason == "" {
+		t.Fatal("a user registered by an admin logged in before being activated")
+	}
+}
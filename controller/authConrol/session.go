@@ -0,0 +1,74 @@
+package usercontrol
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"park/database"
+	modelsuser "park/models/modelsUser"
+	"park/service/audit"
+	"park/service/session"
+)
+
+type SessionResponse struct {
+	modelsuser.Session
+	Current bool `json:"current"`
+}
+
+// @Summary      List my sessions
+// @Description  Lists the sessions of the current user that are neither revoked nor expired. The session of the request is marked as current.
+// @Tags         Auth
+// @Produce      json
+// @Success      200 {array} SessionResponse
+// @Failure      500 {object} map[string]string "message: Internal Server Error"
+// @Router       /api/v1/auth/sessions [get]
+func GetSessions(c *fiber.Ctx) error {
+	id, _ := c.Locals("user_id").(string)
+	userID, _ := strconv.Atoi(id)
+	current, _ := c.Locals("session_id").(string)
+
+	sessions, err := session.List(database.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
+
+	response := make([]SessionResponse, len(sessions))
+	for i, s := range sessions {
+		response[i] = SessionResponse{Session: s, Current: s.Id == current}
+	}
+	return c.JSON(response)
+}
+
+// @Summary      End one of my sessions
+// @Description  Revokes a session of the current user, for example one left open on another computer.
+// @Tags         Auth
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Success      200 {object} map[string]string "message: Session revoked"
+// @Failure      404 {object} map[string]string "message: Session not found"
+// @Failure      500 {object} map[string]string "message: Internal Server Error"
+// @Router       /api/v1/auth/sessions/{id} [delete]
+func DeleteSession(c *fiber.Ctx) error {
+	var s modelsuser.Session
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Params("id"), c.Locals("user_id")).First(&s).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Session not found",
+		})
+	}
+
+	audit.Before(c, "session", s.Id, s)
+
+	if err := session.Revoke(database.DB, s.Id, session.ReasonRevoked); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
+	database.DB.First(&s, "id = ?", s.Id)
+	audit.After(c, "session", s.Id, s)
+	return c.JSON(fiber.Map{
+		"message": "Session revoked",
+	})
+}
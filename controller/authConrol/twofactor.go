@@ -0,0 +1,334 @@
+package usercontrol
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"park/database"
+	modelsuser "park/models/modelsUser"
+	"park/service/loginguard"
+	"park/service/security"
+	"park/service/twofactor"
+)
+
+type ChallengeInput struct {
+	Challenge string `json:"challenge"`
+	Code      string `json:"code" example:"123456"`
+}
+
+type CodeInput struct {
+	Code string `json:"code" example:"123456"`
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool  `json:"enabled"`
+	Required          bool  `json:"required"`
+	RecoveryCodesLeft int64 `json:"recovery_codes_left"`
+}
+
+// @Summary      Set up two-factor authentication during login
+// @Description  For a login answered with two_factor_setup_required: creates the TOTP secret of the user and returns it as an otpauth URI and a QR code PNG. The login is completed by /api/v1/auth/2fa/challenge with a code of the new secret.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        challenge body ChallengeInput true "Login challenge"
+// @Success      200 {object} twofactor.Enrollment
+// @Failure      401 {object} map[string]string "message: Invalid or expired challenge"
+// @Failure      500 {object} map[string]string "message: Internal Server Error"
+// @Router       /api/v1/auth/2fa/challenge/setup [post]
+func ChallengeSetup(c *fiber.Ctx) error {
+	var input ChallengeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Bad Request",
+		})
+	}
+	ch, ok := twofactor.GetChallenge(input.Challenge)
+	if !ok || !ch.Setup {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid or expired challenge",
+		})
+	}
+
+	var user modelsuser.User
+	if err := database.DB.First(&user, ch.UserId).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid or expired challenge",
+		})
+	}
+	return setup(c, user)
+}
+
+// @Summary      Complete a login with a two-factor code
+// @Description  Second step of a login answered with 202: checks a TOTP code or a recovery code and logs in. When the login asked for setup, the code confirms the new secret and the response also holds the recovery codes, shown only this once.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        challenge body ChallengeInput true "Login challenge and code"
+// @Success      200 {object} map[string]interface{} "message: Login successful"
+// @Failure      400 {object} map[string]string "message: Set up two-factor authentication first"
+// @Failure      401 {object} map[string]string "message: Invalid two-factor code"
+// @Failure      429 {object} map[string]interface{} "message: Too many login attempts"
+// @Failure      500 {object} map[string]string "message: Internal Server Error"
+// @Router       /api/v1/auth/2fa/challenge [post]
+func Challenge(c *fiber.Ctx) error {
+	var input ChallengeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Bad Request",
+		})
+	}
+	ch, ok := twofactor.GetChallenge(input.Challenge)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid or expired challenge",
+		})
+	}
+
+	var user modelsuser.User
+	if err := database.DB.First(&user, ch.UserId).Error; err != nil || !user.IsActive {
+		twofactor.EndChallenge(input.Challenge)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid or expired challenge",
+		})
+	}
+	if wait, locked := loginguard.Wait(user.Username, c.IP()); wait > 0 {
+		return tooManyAttempts(c, user.Username, wait, locked)
+	}
+
+	var extra fiber.Map
+	var err error
+	if ch.Setup {
+		var codes []string
+		codes, err = twofactor.Enable(database.DB, user.Id, input.Code, time.Now())
+		extra = fiber.Map{"recovery_codes": codes}
+	} else {
+		err = twofactor.Verify(database.DB, user.Id, input.Code, time.Now())
+	}
+	if err != nil {
+		if errors.Is(err, twofactor.ErrInvalidCode) {
+			twofactor.FailChallenge(input.Challenge)
+		}
+		return codeFailed(c, user.Username, err)
+	}
+
+	twofactor.EndChallenge(input.Challenge)
+	loginguard.Succeed(user.Username)
+	return startSession(c, user, ch.ParkNo, extra)
+}
+
+// @Summary      Two-factor status
+// @Description  Tells whether the current user has two-factor authentication enabled, whether the role requires it and how many recovery codes are left.
+// @Tags         Auth
+// @Produce      json
+// @Success      200 {object} TwoFactorStatus
+// @Failure      500 {object} map[string]string "message: Internal Server Error"
+// @Router       /api/v1/auth/2fa [get]
+func GetTwoFactor(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
+	enabled, err := twofactor.Enabled(database.DB, user.Id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
+	left, err := twofactor.RecoveryCodesLeft(database.DB, user.Id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
+	return c.JSON(TwoFactorStatus{
+		Enabled:           enabled,
+		Required:          twofactor.Required(user.Role),
+		RecoveryCodesLeft: left,
+	})
+}
+
+// @Summary      Set up two-factor authentication
+// @Description  Creates a new TOTP secret for the current user and returns it as an otpauth URI and a QR code PNG. It is enabled by /api/v1/auth/2fa/enable.
+// @Tags         Auth
+// @Produce      json
+// @Success      200 {object} twofactor.Enrollment
+// @Failure      409 {object} map[string]string "message: Two-factor authentication is already enabled"
+// @Failure      500 {object} map[string]string "message: Internal Server Error"
+// @Router       /api/v1/auth/2fa/setup [post]
+func SetupTwoFactor(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
+	return setup(c, user)
+}
+
+// @Summary      Enable two-factor authentication
+// @Description  Confirms the secret created by /api/v1/auth/2fa/setup with a code from the authenticator and returns the recovery codes, shown only this once.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        code body CodeInput true "TOTP code"
+// @Success      200 {object} map[string]interface{} "recovery_codes"
+// @Failure      400 {object} map[string]string "message: Set up two-factor authentication first"
+// @Failure      401 {object} map[string]string "message: Invalid two-factor code"
+// @Failure      409 {object} map[string]string "message: Two-factor authentication is already enabled"
+// @Router       /api/v1/auth/2fa/enable [post]
+func EnableTwoFactor(c *fiber.Ctx) error {
+	var input CodeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Bad Request",
+		})
+	}
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
+
+	codes, err := twofactor.Enable(database.DB, user.Id, input.Code, time.Now())
+	if err != nil {
+		return codeFailed(c, user.Username, err)
+	}
+	return c.JSON(fiber.Map{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// @Summary      Disable two-factor authentication
+// @Description  Removes the TOTP secret and the recovery codes of the current user after checking a code. Not allowed for roles that require two-factor authentication.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        code body CodeInput true "TOTP or recovery code"
+// @Success      200 {object} map[string]string "message: Two-factor authentication disabled"
+// @Failure      401 {object} map[string]string "message: Invalid two-factor code"
+// @Failure      409 {object} map[string]string "message: Two-factor authentication is required for the role"
+// @Router       /api/v1/auth/2fa/disable [post]
+func DisableTwoFactor(c *fiber.Ctx) error {
+	var input CodeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Bad Request",
+		})
+	}
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
+	if twofactor.Required(user.Role) {
+		return codeFailed(c, user.Username, twofactor.ErrRequired)
+	}
+
+	if err := twofactor.Verify(database.DB, user.Id, input.Code, time.Now()); err != nil {
+		return codeFailed(c, user.Username, err)
+	}
+	if err := twofactor.Disable(database.DB, user.Id); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
+	security.Record(c, security.TwoFactorDisabled, user.Username, "")
+	return c.JSON(fiber.Map{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// @Summary      Replace the recovery codes
+// @Description  Checks a code and replaces the recovery codes of the current user. The new codes are shown only this once.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        code body CodeInput true "TOTP or recovery code"
+// @Success      200 {object} map[string]interface{} "recovery_codes"
+// @Failure      401 {object} map[string]string "message: Invalid two-factor code"
+// @Router       /api/v1/auth/2fa/recovery-codes [post]
+func RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	var input CodeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Bad Request",
+		})
+	}
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := twofactor.Verify(database.DB, user.Id, input.Code, time.Now()); err != nil {
+		return codeFailed(c, user.Username, err)
+	}
+	codes, err := twofactor.RegenerateRecoveryCodes(database.DB, user.Id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
+	return c.JSON(fiber.Map{
+		"recovery_codes": codes,
+	})
+}
+
+func setup(c *fiber.Ctx, user modelsuser.User) error {
+	enrollment, err := twofactor.Setup(database.DB, user)
+	if errors.Is(err, twofactor.ErrAlreadyEnabled) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Two-factor authentication is already enabled",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
+	return c.JSON(enrollment)
+}
+
+// codeFailed responds to an error of checking a two-factor code. Wrong
+// codes count as failed logins.
+func codeFailed(c *fiber.Ctx, username string, err error) error {
+	switch {
+	case errors.Is(err, twofactor.ErrInvalidCode):
+		loginguard.Fail(username, c.IP())
+		security.Record(c, security.TwoFactorFailed, username, "")
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid two-factor code",
+		})
+	case errors.Is(err, twofactor.ErrNotEnrolled):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Set up two-factor authentication first",
+		})
+	case errors.Is(err, twofactor.ErrAlreadyEnabled):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Two-factor authentication is already enabled",
+		})
+	case errors.Is(err, twofactor.ErrRequired):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Two-factor authentication is required for the role",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"message": "Internal Server Error",
+	})
+}
+
+// currentUser loads the user of the request.
+func currentUser(c *fiber.Ctx) (modelsuser.User, error) {
+	var user modelsuser.User
+	err := database.DB.First(&user, "id = ?", c.Locals("user_id")).Error
+	return user, err
+}
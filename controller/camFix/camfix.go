@@ -4,13 +4,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+
 	"park/database"
 	"park/models/camera"
-	modelsuser "park/models/modelsUser"
+	"park/service/audit"
+	"park/service/secrets"
 	"park/util"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 // Camfix godoc
@@ -44,6 +50,7 @@ func AddCam(c *fiber.Ctx) error {
 	}
 
 	// fmt.Println(fix)
+	audit.After(c, "camfix", strconv.Itoa(fix.Id), fix)
 	return c.Status(201).JSON(fix)
 }
 
@@ -72,13 +79,18 @@ func GetAllCams(c *fiber.Ctx) error {
 
 	offset := (page - 1) * limit
 
+	query := database.DB.Model(&camera.CamFix{})
+	if parkID := c.QueryInt("park_id"); parkID != 0 {
+		query = query.Where("park_id = ?", parkID)
+	}
+
 	var total int64
-	if err := database.DB.Model(&camera.CamFix{}).Count(&total).Error; err != nil {
+	if err := query.Count(&total).Error; err != nil {
 		return c.Status(500).JSON(ErrorResponse{Error: "Cannot fetch total count"})
 	}
 
 	var cams []camera.CamFix
-	if err := database.DB.Order("id DESC").Limit(limit).Offset(offset).Find(&cams).Error; err != nil {
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&cams).Error; err != nil {
 		return c.Status(500).JSON(ErrorResponse{Error: "Cannot fetch cameras"})
 	}
 
@@ -115,15 +127,26 @@ func UpdateChannelIdsByChannelName(c *fiber.Ctx) error {
 		})
 	}
 
+	names := make([]string, 0, len(updates))
 	for _, update := range updates {
-		channelName, nameExists := update["ChannelName"]
-		channelId, idExists := update["ChannelId"]
+		_, nameExists := update["ChannelName"]
+		_, idExists := update["ChannelId"]
 
 		if !nameExists || !idExists {
 			return c.Status(400).JSON(fiber.Map{
 				"message": "Each update must contain 'ChannelName' and 'ChannelId'",
 			})
 		}
+		names = append(names, update["ChannelName"])
+	}
+
+	var before []camera.CamFix
+	database.DB.Where("channel_name IN ?", names).Order("id").Find(&before)
+	audit.Before(c, "camfix", strings.Join(names, ","), before)
+
+	for _, update := range updates {
+		channelName := update["ChannelName"]
+		channelId := update["ChannelId"]
 
 		result := database.DB.Model(&camera.CamFix{}).
 			Where("channel_name = ?", channelName).
@@ -142,6 +165,10 @@ func UpdateChannelIdsByChannelName(c *fiber.Ctx) error {
 		}
 	}
 
+	var after []camera.CamFix
+	database.DB.Where("channel_name IN ?", names).Order("id").Find(&after)
+	audit.After(c, "camfix", strings.Join(names, ","), after)
+
 	return c.JSON(fiber.Map{
 		"message": "ChannelIds updated successfully",
 		"updated": len(updates),
@@ -149,49 +176,39 @@ func UpdateChannelIdsByChannelName(c *fiber.Ctx) error {
 }
 
 // UpdateMacUser godoc
-// @Summary Update an existing MacUser
-// @Description Update an existing MacUser's details such as MacUsername and MacPassword
+// @Summary Update the Macroscop login
+// @Description Stores the username and password of the Macroscop server encrypted in the secret store. They are never returned.
 // @Tags CamFix
 // @Accept json
 // @Produce json
-// @Param macuser body modelsuser.MacUser true "MacUser object"
-// @Success 200 {object} SuccessResponse
+// @Param macuser body MacroscopLogin true "Macroscop login"
+// @Success 200 {object} Response
 // @Failure 400 {object} ErrorResponse
-// @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/updatemac [put]
 func UpdateMacUser(c *fiber.Ctx) error {
-	var macuser modelsuser.MacUser
+	var login MacroscopLogin
 
-	if err := c.BodyParser(&macuser); err != nil {
+	if err := c.BodyParser(&login); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Failed to parse request body",
 		})
 	}
 
-	id := 1
-
-	var existingUser modelsuser.MacUser
-	err := database.DB.Where("id = ?", id).First(&existingUser).Error
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "User not found",
-		})
-	}
-
-	existingUser.MacUsername = macuser.MacUsername
-	existingUser.MacPassword = macuser.MacPassword
-
-	err = database.DB.Save(&existingUser).Error
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := secrets.Set(tx, secrets.MacroscopUsername, login.MacUsername); err != nil {
+			return err
+		}
+		return secrets.Set(tx, secrets.MacroscopPassword, login.MacPassword)
+	})
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update user",
 		})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message": "User updated successfully",
-		"user":    existingUser,
+	return c.Status(fiber.StatusOK).JSON(Response{
+		Message: "User updated successfully",
 	})
 }
 
@@ -215,6 +232,7 @@ func UpdateCamera(c *fiber.Ctx) error {
 	if err := database.DB.Where("id = ?", id).First(&datacam).Error; err != nil {
 		return c.Status(404).JSON(ErrorResponse{Error: "Camera not found"})
 	}
+	audit.Before(c, "camfix", strconv.Itoa(datacam.Id), datacam)
 
 	var updateReq UpdateCameraTypeRequest
 	if err := c.BodyParser(&updateReq); err != nil {
@@ -230,6 +248,7 @@ func UpdateCamera(c *fiber.Ctx) error {
 		return c.Status(500).JSON(ErrorResponse{Error: "Internal server error while updating camera"})
 	}
 
+	audit.After(c, "camfix", strconv.Itoa(datacam.Id), datacam)
 	return c.Status(200).JSON(datacam)
 }
 
@@ -244,9 +263,9 @@ type Response struct {
 	Message string `json:"message"`
 }
 
-type SuccessResponse struct {
-	Message string             `json:"message"`
-	User    modelsuser.MacUser `json:"user"`
+type MacroscopLogin struct {
+	MacUsername string `json:"macusername"`
+	MacPassword string `json:"macpassword"`
 }
 type DeleteResponse struct {
 	Message string `json:"message"`
@@ -272,6 +291,7 @@ func DeleteCam(c *fiber.Ctx) error {
 			Message: fmt.Sprintf("Camera with ID '%s' not found", id),
 		})
 	}
+	audit.Before(c, "camfix", strconv.Itoa(cam.Id), cam)
 
 	if err := database.DB.Delete(&cam).Error; err != nil {
 		return c.Status(500).JSON(Response{
@@ -307,16 +327,14 @@ type ConfigResponse struct {
 // // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /api/v1/sync-camfix [get]
 func SyncCamFixWithConfig(c *fiber.Ctx) error {
-	var user modelsuser.MacUser
-	if err := database.DB.First(&user).Error; err != nil {
+	username, password, err := secrets.Macroscop(database.DB)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
-			Error: "Failed to fetch MacUser data: " + err.Error(),
+			Error: "Failed to fetch the Macroscop login: " + err.Error(),
 		})
 	}
-	macroscope := os.Getenv("MACROSCOP_URL")
-	url := fmt.Sprintf("http://%s/configex?login=%s&password=%s&responsetype=json", macroscope, user.MacUsername, user.MacPassword)
-	fmt.Println(url)
-	resp, err := http.Get(url)
+	query := url.Values{"login": {username}, "password": {password}, "responsetype": {"json"}}
+	resp, err := http.Get(fmt.Sprintf("http://%s/configex?%s", os.Getenv("MACROSCOP_URL"), query.Encode()))
 
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
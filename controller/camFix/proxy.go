@@ -0,0 +1,92 @@
+package camfix
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"park/database"
+	"park/models/camera"
+	"park/service/secrets"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// proxyActions maps the camera actions of the frontend to the parameters
+// of the Macroscop /site request.
+var proxyActions = map[string]url.Values{
+	"snapshot": {"oneframeonly": {"true"}},
+	"live":     {},
+}
+
+// snapshotClient fetches single frames. Live streams are not limited in
+// time and use http.DefaultClient.
+var snapshotClient = &http.Client{Timeout: 10 * time.Second}
+
+// CameraProxy godoc
+// @Summary Proxy a camera image
+// @Description Fetches a snapshot or the live MJPEG stream of a camera from the Macroscop server with the login kept on the server. Only cameras added to CamFix can be requested, and users bound to a park only get the cameras of their park.
+// @Tags CamFix
+// @Produce image/jpeg
+// @Param channelId path string true "Macroscop channel ID"
+// @Param action path string true "Camera action" Enums(snapshot, live)
+// @Success 200 {file} binary
+// @Failure 400 {object} ErrorResponse "Unknown action"
+// @Failure 404 {object} ErrorResponse "Camera not found"
+// @Failure 502 {object} ErrorResponse "Macroscop server error"
+// @Router /api/v1/camera-proxy/{channelId}/{action} [get]
+func CameraProxy(c *fiber.Ctx) error {
+	params, ok := proxyActions[c.Params("action")]
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Unknown camera action"})
+	}
+
+	// Users bound to a park only see the cameras of that park.
+	cams := database.DB.Where("cam_fixes.channel_id = ?", c.Params("channelId"))
+	if parkNo, _ := c.Locals("parkno").(string); parkNo != "" {
+		cams = cams.Joins("JOIN parks ON parks.id = cam_fixes.park_id").Where("parks.code = ?", parkNo)
+	}
+	var cam camera.CamFix
+	if err := cams.First(&cam).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: "Camera not found"})
+	}
+
+	username, password, err := secrets.Macroscop(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: "Macroscop login is not available"})
+	}
+
+	query := url.Values{
+		"login":           {username},
+		"password":        {password},
+		"channelid":       {cam.ChannelId},
+		"withcontenttype": {"true"},
+		"mode":            {"realtime"},
+	}
+	for key, values := range params {
+		query[key] = values
+	}
+
+	client := http.DefaultClient
+	if c.Params("action") == "snapshot" {
+		client = snapshotClient
+	}
+	resp, err := client.Get(fmt.Sprintf("http://%s/site?%s", os.Getenv("MACROSCOP_URL"), query.Encode()))
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(ErrorResponse{Error: "Failed to reach the Macroscop server"})
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return c.Status(fiber.StatusBadGateway).JSON(ErrorResponse{
+			Error: fmt.Sprintf("Macroscop server answered %d", resp.StatusCode),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, resp.Header.Get(fiber.HeaderContentType))
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	// fasthttp closes the body once it has been sent or the client is gone.
+	c.Context().SetBodyStream(resp.Body, int(resp.ContentLength))
+	return nil
+}
@@ -0,0 +1,134 @@
+package exemptioncontrol
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"park/database"
+	modelexemption "park/models/exemptionModel"
+	"park/service/audit"
+	"park/util/sitetime"
+)
+
+type ReasonInput struct {
+	Code             string `json:"code" example:"ambulance"`
+	Name             string `json:"name" example:"Ambulance"`
+	RequiresApproval *bool  `json:"requires_approval" example:"false"`
+	Active           *bool  `json:"active" example:"true"`
+}
+
+// GetReasons lists the exemption catalog
+// @Summary List the exemption reasons
+// @Description Lists the reasons an operator may let a car out without payment. Inactive reasons are listed with all=true.
+// @Tags Exemptions
+// @Produce json
+// @Param all query bool false "Include inactive reasons"
+// @Success 200 {array} modelexemption.Reason
+// @Failure 500 {object} map[string]string "Can not retrieve reasons"
+// @Router /api/v1/exemptions/reasons [get]
+func GetReasons(c *fiber.Ctx) error {
+	query := database.DB.Order("name")
+	if !c.QueryBool("all") {
+		query = query.Where("active")
+	}
+	reasons := []modelexemption.Reason{}
+	if err := query.Find(&reasons).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Can not retrieve reasons"})
+	}
+	return c.JSON(reasons)
+}
+
+// CreateReason adds a reason to the exemption catalog
+// @Summary Add an exemption reason
+// @Description Adds a reason to the catalog. The code is what exits and reports refer to and can not be changed later.
+// @Tags Exemptions
+// @Accept json
+// @Produce json
+// @Param reason body ReasonInput true "Reason"
+// @Success 201 {object} modelexemption.Reason
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 409 {object} map[string]string "Code already exists"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/exemptions/reasons [post]
+func CreateReason(c *fiber.Ctx) error {
+	var input ReasonInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"message": "Invalid request"})
+	}
+	reason := modelexemption.Reason{
+		Code:      strings.TrimSpace(input.Code),
+		Name:      strings.TrimSpace(input.Name),
+		Active:    true,
+		CreatedAt: sitetime.From(sitetime.Now()),
+	}
+	if reason.Code == "" || reason.Name == "" || len(reason.Code) > 32 {
+		return c.Status(400).JSON(fiber.Map{"message": "Code of at most 32 characters and name are required"})
+	}
+	if input.RequiresApproval != nil {
+		reason.RequiresApproval = *input.RequiresApproval
+	}
+	if input.Active != nil {
+		reason.Active = *input.Active
+	}
+
+	var count int64
+	if err := database.DB.Model(&modelexemption.Reason{}).Where("code = ?", reason.Code).Count(&count).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Internal server error"})
+	}
+	if count > 0 {
+		return c.Status(409).JSON(fiber.Map{"message": "Code already exists"})
+	}
+	if err := database.DB.Create(&reason).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Internal server error"})
+	}
+	audit.After(c, "exemption_reason", reason.Code, reason)
+	return c.Status(201).JSON(reason)
+}
+
+// UpdateReason changes an exemption reason
+// @Summary Update an exemption reason
+// @Description Changes the name of a reason, whether it needs a supervisor, and whether operators may use it. Reasons are deactivated rather than deleted, so past exits keep their reason.
+// @Tags Exemptions
+// @Accept json
+// @Produce json
+// @Param code path string true "Reason code"
+// @Param reason body ReasonInput true "Fields to change"
+// @Success 200 {object} modelexemption.Reason
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 404 {object} map[string]string "Reason not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/exemptions/reasons/{code} [put]
+func UpdateReason(c *fiber.Ctx) error {
+	var reason modelexemption.Reason
+	err := database.DB.Where("code = ?", c.Params("code")).First(&reason).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(404).JSON(fiber.Map{"message": "Reason not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Internal server error"})
+	}
+
+	var input ReasonInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"message": "Invalid request"})
+	}
+	audit.Before(c, "exemption_reason", reason.Code, reason)
+
+	if name := strings.TrimSpace(input.Name); name != "" {
+		reason.Name = name
+	}
+	if input.RequiresApproval != nil {
+		reason.RequiresApproval = *input.RequiresApproval
+	}
+	if input.Active != nil {
+		reason.Active = *input.Active
+	}
+	if err := database.DB.Model(&reason).Select("name", "requires_approval", "active").Updates(&reason).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Internal server error"})
+	}
+	audit.After(c, "exemption_reason", reason.Code, reason)
+	return c.JSON(reason)
+}
@@ -0,0 +1,251 @@
+package exemptioncontrol
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"park/database"
+	modelexemption "park/models/exemptionModel"
+	modelscar "park/models/modelsCar"
+	modelsuser "park/models/modelsUser"
+	"park/service/audit"
+	"park/service/exemption"
+	"park/service/ledger"
+	platenorm "park/util/plate"
+	"park/util/sitetime"
+)
+
+type RequestInput struct {
+	Plate  string `json:"plate" example:"AG1234"`
+	Reason string `json:"reason" example:"staff"`
+	Note   string `json:"note"`
+}
+
+type PinInput struct {
+	Password string `json:"password"`
+	Pin      string `json:"pin" example:"482913"`
+}
+
+// RequestExemption asks the supervisors to approve a free exit
+// @Summary Ask a supervisor to approve a free exit
+// @Description Sends a request for a free exit of a car to the supervisors over WebSocket. Once approved, the exit is made with the id of the request as approval_id before the approval expires.
+// @Tags Exemptions
+// @Accept json
+// @Produce json
+// @Param request body RequestInput true "Car and reason"
+// @Success 201 {object} modelexemption.Exemption
+// @Failure 400 {object} map[string]string "Unknown exemption reason"
+// @Failure 404 {object} map[string]string "Car not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/exemptions/requests [post]
+func RequestExemption(c *fiber.Ctx) error {
+	var input RequestInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"message": "Invalid request"})
+	}
+
+	var car modelscar.Car_Model
+	if err := database.DB.Order("id desc").Where("car_number = ? AND status <> ?", platenorm.Normalize(input.Plate), "Exited").First(&car).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"message": "Car not found"})
+	}
+	reason, err := exemption.FindReason(database.DB, input.Reason)
+	if errors.Is(err, exemption.ErrUnknownReason) {
+		return c.Status(400).JSON(fiber.Map{"message": "Unknown exemption reason", "reason": input.Reason})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Internal server error"})
+	}
+
+	username, _ := c.Locals("username").(string)
+	ex := modelexemption.Exemption{
+		CarId:       car.ID,
+		CarNumber:   car.Car_number,
+		ParkNo:      car.ParkNo,
+		ReasonCode:  reason.Code,
+		Note:        input.Note,
+		Amount:      ledger.ToMinor(car.Total_payment),
+		RequestedBy: username,
+	}
+	if err := exemption.Request(database.DB, &ex, sitetime.Now()); err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Internal server error"})
+	}
+	audit.After(c, "exemption", strconv.FormatInt(ex.Id, 10), ex)
+	notifySupervisors(ex)
+	return c.Status(201).JSON(ex)
+}
+
+// GetExemptions lists free exits and exemption requests
+// @Summary List exemptions
+// @Description Lists free exits and exemption requests, newest first. total_amount is the fee waived by the applied exemptions that match the filters, in minor units.
+// @Tags Exemptions
+// @Produce json
+// @Param page query int false "Page number"
+// @Param limit query int false "Limit per page"
+// @Param status query string false "Status" Enums(pending, approved, rejected, applied)
+// @Param reason query string false "Reason code"
+// @Param park_no query string false "Park"
+// @Param requested_by query string false "Operator"
+// @Param start query string false "From" example("2025-01-29 00:00:00")
+// @Param end query string false "Until" example("2025-01-29 23:59:59")
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string "Invalid time format"
+// @Failure 500 {object} map[string]string "Can not retrieve exemptions"
+// @Router /api/v1/exemptions [get]
+func GetExemptions(c *fiber.Ctx) error {
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "20"))
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+
+	query := database.DB.Model(&modelexemption.Exemption{})
+	for param, column := range map[string]string{
+		"status":       "status",
+		"reason":       "reason_code",
+		"park_no":      "park_no",
+		"requested_by": "requested_by",
+	} {
+		if value := c.Query(param); value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+	if start := c.Query("start"); start != "" {
+		startTime, err := sitetime.Parse(start)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"message": "Invalid start time format"})
+		}
+		query = query.Where("created_at >= ?", startTime)
+	}
+	if end := c.Query("end"); end != "" {
+		endTime, err := sitetime.Parse(end)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"message": "Invalid end time format"})
+		}
+		query = query.Where("created_at <= ?", endTime)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Can not retrieve exemptions"})
+	}
+	var waived int64
+	if err := query.Session(&gorm.Session{}).Where("status = ?", modelexemption.Applied).
+		Select("COALESCE(SUM(amount), 0)").Scan(&waived).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Can not retrieve exemptions"})
+	}
+
+	exemptions := []modelexemption.Exemption{}
+	if err := query.Order("id DESC").Limit(limit).Offset((page - 1) * limit).Find(&exemptions).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Can not retrieve exemptions"})
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+	return c.JSON(fiber.Map{
+		"exemptions":   exemptions,
+		"total_amount": waived,
+		"page":         page,
+		"limit":        limit,
+		"total":        total,
+		"totalPages":   totalPages,
+		"hasNext":      page < totalPages,
+		"hasPrev":      page > 1,
+	})
+}
+
+// ApproveExemption approves a request for a free exit
+// @Summary Approve an exemption request
+// @Description Approves a pending request of another operator. The operator is told over WebSocket and can let the car out until the approval expires.
+// @Tags Exemptions
+// @Produce json
+// @Param id path int true "Request ID"
+// @Success 200 {object} modelexemption.Exemption
+// @Failure 403 {object} map[string]string "Own request"
+// @Failure 404 {object} map[string]string "Request not found"
+// @Failure 409 {object} map[string]string "Request already decided or expired"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/exemptions/requests/{id}/approve [put]
+func ApproveExemption(c *fiber.Ctx) error {
+	return decide(c, true)
+}
+
+// RejectExemption rejects a request for a free exit
+// @Summary Reject an exemption request
+// @Description Rejects a pending request of another operator, who is told over WebSocket.
+// @Tags Exemptions
+// @Produce json
+// @Param id path int true "Request ID"
+// @Success 200 {object} modelexemption.Exemption
+// @Failure 403 {object} map[string]string "Own request"
+// @Failure 404 {object} map[string]string "Request not found"
+// @Failure 409 {object} map[string]string "Request already decided or expired"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/exemptions/requests/{id}/reject [put]
+func RejectExemption(c *fiber.Ctx) error {
+	return decide(c, false)
+}
+
+func decide(c *fiber.Ctx, approve bool) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"message": "Request not found"})
+	}
+	username, _ := c.Locals("username").(string)
+
+	ex, err := exemption.Decide(database.DB, id, approve, username, sitetime.Now())
+	switch {
+	case errors.Is(err, exemption.ErrNotFound):
+		return c.Status(404).JSON(fiber.Map{"message": "Request not found"})
+	case errors.Is(err, exemption.ErrOwnRequest):
+		return c.Status(403).JSON(fiber.Map{"message": "A supervisor can not decide their own request"})
+	case errors.Is(err, exemption.ErrNotPending), errors.Is(err, exemption.ErrExpired):
+		return c.Status(409).JSON(fiber.Map{"message": err.Error()})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"message": "Internal server error"})
+	}
+	audit.After(c, "exemption", strconv.FormatInt(ex.Id, 10), ex)
+	notifyRequester(ex)
+	return c.JSON(ex)
+}
+
+// SetPin sets the approval PIN of the current user
+// @Summary Set the supervisor PIN
+// @Description Sets the PIN the current user enters at an operator terminal to approve a free exit. The password of the user confirms the change.
+// @Tags Exemptions
+// @Accept json
+// @Produce json
+// @Param request body PinInput true "Password and new PIN"
+// @Success 200 {object} map[string]string "PIN set"
+// @Failure 400 {object} map[string]string "PIN does not follow the policy"
+// @Failure 401 {object} map[string]string "Wrong password"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/exemptions/pin [put]
+func SetPin(c *fiber.Ctx) error {
+	var input PinInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"message": "Invalid request"})
+	}
+
+	var user modelsuser.User
+	if err := database.DB.First(&user, "id = ?", c.Locals("user_id")).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Internal server error"})
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)) != nil {
+		return c.Status(401).JSON(fiber.Map{"message": "Wrong password"})
+	}
+	if err := exemption.CheckPinFormat(input.Pin); err != nil {
+		return c.Status(400).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err := exemption.SetPin(database.DB, user.Id, input.Pin, sitetime.Now()); err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Internal server error"})
+	}
+	return c.JSON(fiber.Map{"message": "PIN set"})
+}
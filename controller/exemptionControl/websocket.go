@@ -0,0 +1,96 @@
+package exemptioncontrol
+
+import (
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+
+	"park/controller/operator"
+	"park/middleware"
+	modelexemption "park/models/exemptionModel"
+	modelsuser "park/models/modelsUser"
+)
+
+// Event is pushed to the websocket clients: new requests go to the
+// supervisors, decisions to the operator who asked.
+type Event struct {
+	Type      string                   `json:"type" enums:"exemption_request,exemption_decision"`
+	Exemption modelexemption.Exemption `json:"exemption"`
+}
+
+type client struct {
+	username string
+	role     modelsuser.RoleType
+}
+
+var (
+	clients      = make(map[*websocket.Conn]client)
+	clientsMutex sync.Mutex
+)
+
+// Upgrade godoc
+// @Summary Exemption requests and decisions over WebSocket
+// @Description Supervisors receive new exemption requests; operators receive the decisions on their own requests.
+// @Tags Exemptions
+// @Success 101 {object} nil "WebSocket upgrade"
+// @Router /api/v1/exemptions/ws [get]
+func Upgrade(c *fiber.Ctx) error {
+	if websocket.IsWebSocketUpgrade(c) {
+		return c.Next()
+	}
+	return fiber.ErrUpgradeRequired
+}
+
+func Ws(c *websocket.Conn) {
+	username, _ := c.Locals("username").(string)
+	role, _ := c.Locals("role").(string)
+
+	clientsMutex.Lock()
+	clients[c] = client{username: username, role: modelsuser.RoleType(role)}
+	clientsMutex.Unlock()
+
+	defer func() {
+		clientsMutex.Lock()
+		delete(clients, c)
+		clientsMutex.Unlock()
+		c.Close()
+	}()
+
+	for {
+		if _, _, err := c.ReadMessage(); err != nil {
+			break
+		}
+	}
+}
+
+// notifySupervisors sends a new request to everyone who may approve it,
+// except the operator who asked.
+func notifySupervisors(ex modelexemption.Exemption) {
+	send(Event{Type: "exemption_request", Exemption: ex}, func(cl client) bool {
+		return cl.username != ex.RequestedBy && middleware.Allowed(operator.ApprovePermission, cl.role)
+	})
+}
+
+// notifyRequester sends the decision on a request to the operator who
+// asked.
+func notifyRequester(ex modelexemption.Exemption) {
+	send(Event{Type: "exemption_decision", Exemption: ex}, func(cl client) bool {
+		return cl.username == ex.RequestedBy
+	})
+}
+
+func send(event Event, to func(client) bool) {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+
+	for conn, cl := range clients {
+		if !to(cl) {
+			continue
+		}
+		if err := conn.WriteJSON(event); err != nil {
+			conn.Close()
+			delete(clients, conn)
+		}
+	}
+}
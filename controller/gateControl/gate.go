@@ -0,0 +1,289 @@
+package gatecontrol
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	resmodel "park/controller/getdata/resModel"
+	"park/database"
+	modelgate "park/models/gateModel"
+	modelscar "park/models/modelsCar"
+	"park/service/audit"
+	"park/service/gate"
+	"park/service/secrets"
+)
+
+type OpenRequest struct {
+	Reason string `json:"reason" example:"Driver lost the ticket"`
+	CarId  *int   `json:"car_id"`
+}
+
+var (
+	// Enabled turns off automatic opens while a journal is being replayed.
+	Enabled   = true
+	simulator = gate.NewSimulator()
+)
+
+func controller() *gate.Controller {
+	client := &http.Client{Timeout: 5 * time.Second}
+	return &gate.Controller{
+		Drivers: map[string]gate.Driver{
+			"macroscop": &gate.Macroscop{
+				Server:      os.Getenv("MACROSCOP_URL"),
+				Credentials: macroscopCredentials,
+				Client:      client,
+			},
+			"http":      &gate.HTTPRelay{Client: client},
+			"simulator": simulator,
+		},
+		Log: logEvent,
+	}
+}
+
+var logEvent = func(event *modelgate.Event) error {
+	return database.DB.Create(event).Error
+}
+
+// gateForChannel finds the gate that exits seen on a camera channel open.
+var gateForChannel = func(channelID string) (modelgate.Gate, error) {
+	var g modelgate.Gate
+	err := database.DB.Where("channel_id = ?", channelID).First(&g).Error
+	return g, err
+}
+
+func macroscopCredentials() (string, string, error) {
+	return secrets.Macroscop(database.DB)
+}
+
+// OpenForCar opens the gate of the camera that saw the exit of car. The
+// camera is found by its channel id, which the exit stores in CamToken.
+// Cars whose exit camera has no gate are left alone and nil is returned.
+func OpenForCar(car modelscar.Car_Model, trigger gate.Trigger, user string) (*modelgate.Event, error) {
+	if !Enabled || car.CamToken == "" {
+		return nil, nil
+	}
+
+	g, err := gateForChannel(car.CamToken)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	carID := car.ID
+	event, err := controller().Open(g, gate.Command{Trigger: trigger, CarId: &carID, User: user})
+	return &event, err
+}
+
+func validDriver(name string) bool {
+	_, ok := controller().Drivers[name]
+	return ok
+}
+
+// CreateGate godoc
+// @Summary Create a gate
+// @Description Registers a barrier with the driver that controls it: macroscop, http or simulator.
+// @Tags Gates
+// @Accept json
+// @Produce json
+// @Param gate body modelgate.Gate true "Gate"
+// @Success 201 {object} modelgate.Gate
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid request data"
+// @Failure 500 {object} resmodel.ErrorResponse "Failed to save data to the database"
+// @Router /api/v1/gates [post]
+func CreateGate(c *fiber.Ctx) error {
+	var g modelgate.Gate
+	if err := c.BodyParser(&g); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to parse request body",
+			Details: err.Error(),
+		})
+	}
+	if !validDriver(g.Driver) {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{Error: "Unknown gate driver"})
+	}
+
+	g.Id = 0
+	if err := database.DB.Create(&g).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to save data to the database",
+			Details: err.Error(),
+		})
+	}
+	audit.After(c, "gate", strconv.Itoa(g.Id), g)
+	return c.Status(201).JSON(g)
+}
+
+// GetGates godoc
+// @Summary Get all gates
+// @Tags Gates
+// @Produce json
+// @Param park_id query int false "Only gates of this park"
+// @Success 200 {array} modelgate.Gate
+// @Failure 500 {object} resmodel.ErrorResponse "Database error"
+// @Router /api/v1/gates [get]
+func GetGates(c *fiber.Ctx) error {
+	query := database.DB.Order("id")
+	if parkID := c.QueryInt("park_id"); parkID != 0 {
+		query = query.Where("park_id = ?", parkID)
+	}
+
+	var gates []modelgate.Gate
+	if err := query.Find(&gates).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to retrieve gates",
+			Details: err.Error(),
+		})
+	}
+	return c.Status(200).JSON(gates)
+}
+
+// UpdateGate godoc
+// @Summary Update a gate
+// @Tags Gates
+// @Accept json
+// @Produce json
+// @Param id path int true "Gate ID"
+// @Param gate body modelgate.Gate true "Gate"
+// @Success 200 {object} modelgate.Gate
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid request data"
+// @Failure 404 {object} resmodel.ErrorResponse "Gate not found"
+// @Failure 500 {object} resmodel.ErrorResponse "Database error"
+// @Router /api/v1/gates/{id} [put]
+func UpdateGate(c *fiber.Ctx) error {
+	var existing modelgate.Gate
+	if err := database.DB.First(&existing, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(resmodel.ErrorResponse{
+			Error:   "Gate not found",
+			Details: err.Error(),
+		})
+	}
+	audit.Before(c, "gate", strconv.Itoa(existing.Id), existing)
+
+	var g modelgate.Gate
+	if err := c.BodyParser(&g); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to parse request body",
+			Details: err.Error(),
+		})
+	}
+	if !validDriver(g.Driver) {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{Error: "Unknown gate driver"})
+	}
+
+	g.Id = existing.Id
+	g.CreatedAt = existing.CreatedAt
+	if err := database.DB.Save(&g).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to update gate",
+			Details: err.Error(),
+		})
+	}
+	audit.After(c, "gate", strconv.Itoa(g.Id), g)
+	return c.Status(200).JSON(g)
+}
+
+// DeleteGate godoc
+// @Summary Delete a gate
+// @Description Deletes a gate. Its event log is kept.
+// @Tags Gates
+// @Param id path int true "Gate ID"
+// @Success 200 {string} string "Gate successfully deleted"
+// @Failure 404 {object} resmodel.ErrorResponse "Gate not found"
+// @Failure 500 {object} resmodel.ErrorResponse "Database error"
+// @Router /api/v1/gates/{id} [delete]
+func DeleteGate(c *fiber.Ctx) error {
+	var g modelgate.Gate
+	if err := database.DB.First(&g, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(resmodel.ErrorResponse{
+			Error:   "Gate not found",
+			Details: err.Error(),
+		})
+	}
+	audit.Before(c, "gate", strconv.Itoa(g.Id), g)
+	if err := database.DB.Delete(&g).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to delete gate",
+			Details: err.Error(),
+		})
+	}
+	return c.Status(200).JSON(fiber.Map{"message": "Gate successfully deleted"})
+}
+
+// OpenGate godoc
+// @Summary Open a gate manually
+// @Description Opens a barrier on behalf of the logged in operator. The open is logged with the operator and reason.
+// @Tags Gates
+// @Accept json
+// @Produce json
+// @Param id path int true "Gate ID"
+// @Param request body OpenRequest false "Reason and related visit"
+// @Success 200 {object} modelgate.Event "Gate opened"
+// @Failure 404 {object} resmodel.ErrorResponse "Gate not found"
+// @Failure 502 {object} modelgate.Event "The gate did not open"
+// @Router /api/v1/gates/{id}/open [post]
+func OpenGate(c *fiber.Ctx) error {
+	var g modelgate.Gate
+	if err := database.DB.First(&g, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(resmodel.ErrorResponse{
+			Error:   "Gate not found",
+			Details: err.Error(),
+		})
+	}
+
+	var req OpenRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+				Error:   "Failed to parse request body",
+				Details: err.Error(),
+			})
+		}
+	}
+
+	user, _ := c.Locals("username").(string)
+	event, err := controller().Open(g, gate.Command{
+		Trigger: gate.TriggerManual,
+		CarId:   req.CarId,
+		User:    user,
+		Reason:  req.Reason,
+	})
+	audit.After(c, "gate_event", strconv.Itoa(event.Id), event)
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(event)
+	}
+	return c.Status(200).JSON(event)
+}
+
+// GetGateEvents godoc
+// @Summary Get the event log of a gate
+// @Description Lists open and close commands of a gate with their result, newest first.
+// @Tags Gates
+// @Produce json
+// @Param id path int true "Gate ID"
+// @Param limit query int false "Number of events" default(50)
+// @Success 200 {array} modelgate.Event
+// @Failure 500 {object} resmodel.ErrorResponse "Database error"
+// @Router /api/v1/gates/{id}/events [get]
+func GetGateEvents(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 {
+		limit = 50
+	}
+
+	var events []modelgate.Event
+	if err := database.DB.Where("gate_id = ?", c.Params("id")).Order("id desc").Limit(limit).Find(&events).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to retrieve gate events",
+			Details: err.Error(),
+		})
+	}
+	return c.Status(200).JSON(events)
+}
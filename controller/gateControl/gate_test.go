@@ -0,0 +1,81 @@
+package gatecontrol
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	modelgate "park/models/gateModel"
+	modelscar "park/models/modelsCar"
+	"park/models/tarif"
+	"park/service/carexit"
+	"park/service/gate"
+	"park/util/sitetime"
+)
+
+func TestExitOpensGateOfExitCamera(t *testing.T) {
+	now := time.Date(2025, 1, 29, 14, 0, 0, 0, time.UTC)
+	store := carexit.NewMemoryStore(modelscar.Car_Model{
+		ID:         1,
+		Car_number: "BE5084AG",
+		Status:     carexit.StatusInside,
+		ParkNo:     "P4",
+		Start_time: sitetime.From(now.Add(-time.Hour)),
+	})
+	svc := &carexit.Service{
+		Store: store,
+		Price: func(start, end time.Time) (float64, error) { return 0, nil },
+		Subscriptions: func(string, time.Time) (tarif.Tarif, bool) {
+			return tarif.Tarif{}, false
+		},
+		Now: func() time.Time { return now },
+	}
+
+	exitGate := modelgate.Gate{Id: 7, Name: "P4 exit", ChannelId: "exit-channel", Driver: "simulator"}
+	var logged []modelgate.Event
+	defer func(find func(string) (modelgate.Gate, error), log func(*modelgate.Event) error) {
+		gateForChannel, logEvent = find, log
+	}(gateForChannel, logEvent)
+	gateForChannel = func(channelID string) (modelgate.Gate, error) {
+		if channelID != exitGate.ChannelId {
+			return modelgate.Gate{}, gorm.ErrRecordNotFound
+		}
+		return exitGate, nil
+	}
+	logEvent = func(event *modelgate.Event) error {
+		logged = append(logged, *event)
+		return nil
+	}
+
+	res, err := svc.Exit(carexit.Request{
+		Plate:       "BE5084AG",
+		ChannelName: "P4-6",
+		ChannelId:   exitGate.ChannelId,
+		ParkNo:      "P4",
+	})
+	if err != nil {
+		t.Fatalf("Exit returned error: %v", err)
+	}
+
+	event, err := OpenForCar(res.Car, gate.TriggerPayment, "operator")
+	if err != nil {
+		t.Fatalf("OpenForCar returned error: %v", err)
+	}
+	if event == nil || event.GateId != exitGate.Id || event.Action != string(gate.Open) || !event.Success {
+		t.Fatalf("got event %+v, want a successful open of gate %d", event, exitGate.Id)
+	}
+	if event.CarId == nil || *event.CarId != 1 {
+		t.Fatalf("event is not linked to the visit: %+v", event)
+	}
+	if !simulator.IsOpen(exitGate.Id) {
+		t.Fatal("simulated gate was not opened")
+	}
+	if len(logged) != 1 {
+		t.Fatalf("logged %d events, want 1", len(logged))
+	}
+
+	res.Car.CamToken = "other-channel"
+	if event, err := OpenForCar(res.Car, gate.TriggerPayment, "operator"); event != nil || err != nil {
+		t.Fatalf("camera without gate opened %+v, %v", event, err)
+	}
+}
@@ -1,20 +1,28 @@
 package getdata
 
 import (
+	"errors"
 	"fmt"
 	"log"
-	"math"
 	"os"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 
+	"park/config"
+	gatecontrol "park/controller/gateControl"
 	resmodel "park/controller/getdata/resModel"
+	"park/controller/occupancy"
 	"park/controller/operator"
 	"park/database"
 	"park/models/camera"
+	modelgate "park/models/gateModel"
 	modelscar "park/models/modelsCar"
+	"park/service/carexit"
+	"park/service/gate"
 	"park/util"
+	"park/util/plate"
+	"park/util/sitetime"
 )
 
 const (
@@ -22,7 +30,6 @@ const (
 	statusExited    = "Exited"
 	statusPending   = "Pending"
 	statusUnpaid    = "Unpaid"
-	timeFormat      = "2006-01-02 15:04:05"
 	defaultImageURL = "testPhoto.png"
 )
 
@@ -34,8 +41,11 @@ const (
 // @Produce json
 // @Param request body camera.CapturedEventData true "Captured data from the camera"
 // @Success 201 {object} resmodel.Response "Car entry created successfully"
-// @Failure 400 {object} resmodel.ErrorResponse "Bad request, car is already inside"
+// @Failure 400 {object} resmodel.ErrorResponse "Bad request, car is already inside or camera has no park"
+// @Failure 409 {object} map[string]interface{} "Lot is full"
 // @Failure 500 {object} resmodel.ErrorResponse "Internal server error, failed to save data"
+// @Param X-Webhook-Token header string false "Camera token set in CAMERA_WEBHOOK_TOKEN, or send it as the token query parameter"
+// @Failure 401 {object} map[string]interface{} "Invalid camera token"
 // @Router /api/v1/camera/getdata [post]
 func CreateCarEntry(c *fiber.Ctx) error {
 	var capturedData camera.CapturedEventData
@@ -48,32 +58,59 @@ func CreateCarEntry(c *fiber.Ctx) error {
 		})
 	}
 
-	now := time.Now().Format(timeFormat)
-	carData.ParkNo = capturedData.ChannelName[:2]
-	carData.Car_number = capturedData.EventComment
+	park, err := util.ParkByChannel(capturedData.ChannelId)
+	if err != nil {
+		return unknownChannel(c, capturedData.ChannelId, err)
+	}
+
+	enteredAt := eventTime(capturedData.CapturedTime, capturedData.Timestamp)
+	carData.ParkNo = park.Code
+	carData.Car_number = plate.Normalize(capturedData.EventComment)
 	carData.Status = statusInside
-	carData.Start_time = now
+	carData.Start_time = sitetime.From(enteredAt)
 	carData.Image_Url = defaultImageURL
 	carData.Reason = "entry"
 	carData.PayStatus = true
 	var existingCar modelscar.Car_Model
-	err := database.DB.Order("id desc").First(&existingCar,
+	err = database.DB.Order("id desc").First(&existingCar,
 		"car_number = ? AND (status = ? OR status = ?)",
 		carData.Car_number, statusInside, statusPending).Error
 
+	if err == nil && existingCar.NoEntry && existingCar.ParkNo == park.Code && !existingCar.End_time.Before(enteredAt) {
+		return matchLostTicket(c, existingCar, enteredAt)
+	}
 	if err == nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"message": "Car is already inside or pending entry",
 		})
 	}
 
-	if err := database.DB.Create(&carData).Error; err != nil {
+	o, err := occupancy.Admit(park.Code, func(tx *gorm.DB) error {
+		return tx.Create(&carData).Error
+	})
+	if errors.Is(err, occupancy.ErrFull) {
+		log.Println("Park", park.Code, "is full, refusing", carData.Car_number)
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message":   "Lot is full",
+			"lot_full":  true,
+			"occupancy": o,
+		})
+	}
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
 			Error:   "Failed to save data to the database",
 			Details: err.Error(),
 		})
 	}
 	operator.Refresh <- struct{}{}
+	occupancy.Update()
+
+	if exited, ok := reconcileOrphanExit(carData, enteredAt); ok {
+		return c.Status(fiber.StatusCreated).JSON(resmodel.Response{
+			Message: "Car entry created and its earlier exit applied",
+			Data:    exited,
+		})
+	}
 
 	return c.Status(fiber.StatusCreated).JSON(resmodel.Response{
 		Message: "Car entry created successfully",
@@ -97,9 +134,10 @@ type BroadcastMessage struct {
 // @Failure 400 {object} resmodel.ErrorResponse "Bad request, car already exited"
 // @Failure 404 {object} resmodel.ErrorResponse "Car not found"
 // @Failure 500 {object} resmodel.ErrorResponse "Internal server error, failed to update data"
+// @Param X-Webhook-Token header string false "Camera token set in CAMERA_WEBHOOK_TOKEN, or send it as the token query parameter"
+// @Failure 401 {object} map[string]interface{} "Invalid camera token"
 // @Router /api/v1/camera/getdata [put]
 func CreateCarExit(c *fiber.Ctx) error {
-
 	var capturedData camera.CapturedEventDataE
 
 	if err := c.BodyParser(&capturedData); err != nil {
@@ -109,73 +147,20 @@ func CreateCarExit(c *fiber.Ctx) error {
 		})
 	}
 
-	var carData modelscar.Car_Model
-	if err := database.DB.Where("car_number = ?", capturedData.EventComment).Order("id desc").First(&carData).Error; err != nil {
-		log.Println("Error: Car not found -", capturedData.EventComment)
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"message": "Car not found",
-			"error":   err.Error(),
-		})
-	}
-
-	if carData.Status == statusExited {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Car already exited",
-		})
-	}
-
-	startTimeStr := carData.Start_time
-	endTimeStr := time.Now().Format(timeFormat)
-	startTime, _ := time.Parse(timeFormat, startTimeStr)
-	endTime, _ := time.Parse(timeFormat, endTimeStr)
-	duration := endTime.Sub(startTime)
-	minutes := duration.Minutes()
-	carData.Duration = int(minutes)
-
-	if minutes <= 360 {
-		carData.Total_payment = 2
-	} else if minutes <= 1440 {
-		carData.Total_payment = 3
-	} else {
-		days := int(math.Ceil(minutes / 1440))
-		carData.Total_payment = float64(3 * days)
-	}
-
-	if util.IsVIPPlate(capturedData.EventComment) {
-		carData.Total_payment = 0
-	}
-	carData.Status = statusPending
-	carData.End_time = endTimeStr
-	carData.Reason = "waiting"
-	carData.CameraID = string(capturedData.ChannelName)
-	if carData.ParkNo != capturedData.ChannelName[:2] {
-		fmt.Println("Park NO", carData.ParkNo)
-		fmt.Println(capturedData.ChannelName[:2])
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Car is not in the right park",
-			"car":     carData,
-		})
-	}
-	if err := database.DB.Model(&carData).Updates(carData).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Database update failed",
-			"error":   err.Error(),
-		})
+	park, err := util.ParkByChannel(capturedData.ChannelId)
+	if err != nil {
+		return unknownChannel(c, capturedData.ChannelId, err)
 	}
 
-	carData.CamToken = capturedData.ChannelId
-
-	ip := os.Getenv("HOST")
-	port := os.Getenv("PORT")
-	carData.Image_Url = fmt.Sprintf("http://%s:%s/plate/%s", ip, port, carData.Image_Url)
-
-	operator.Broadcast <- carData
-
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message":     "Car exit updated successfully",
-		"car":         carData,
-		"openCommand": capturedData,
-	})
+	return exitCar(c, capturedData, carexit.Request{
+		Plate:       capturedData.EventComment,
+		ChannelName: capturedData.ChannelName,
+		ChannelId:   capturedData.ChannelId,
+		Reason:      "waiting",
+		ParkNo:      park.Code,
+		CheckPark:   true,
+		At:          eventTime(capturedData.CapturedTime, capturedData.Timestamp),
+	}, true)
 }
 
 // CreateCarExit handles the car exit process from the parking lot
@@ -189,9 +174,10 @@ func CreateCarExit(c *fiber.Ctx) error {
 // @Failure 400 {object} resmodel.ErrorResponse "Bad request, car already exited"
 // @Failure 404 {object} resmodel.ErrorResponse "Car not found"
 // @Failure 500 {object} resmodel.ErrorResponse "Internal server error, failed to update data"
+// @Param X-Webhook-Token header string false "Camera token set in CAMERA_WEBHOOK_TOKEN, or send it as the token query parameter"
+// @Failure 401 {object} map[string]interface{} "Invalid camera token"
 // @Router /api/v1/camera/getdata/nows [put]
 func CreateCarExitNoWs(c *fiber.Ctx) error {
-
 	var capturedData camera.CapturedEventDataE
 
 	if err := c.BodyParser(&capturedData); err != nil {
@@ -202,62 +188,130 @@ func CreateCarExitNoWs(c *fiber.Ctx) error {
 		})
 	}
 
-	var carData modelscar.Car_Model
-	if err := database.DB.Where("car_number = ?", capturedData.EventComment).Order("id desc").First(&carData).Error; err != nil {
-		log.Println("Error: Car not found -", capturedData.EventComment)
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"message": "Car not found",
-			"error":   err.Error(),
-		})
+	park, err := util.ParkByChannel(capturedData.ChannelId)
+	if err != nil {
+		return unknownChannel(c, capturedData.ChannelId, err)
 	}
 
-	if carData.Status == statusExited {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Car already exited",
-		})
+	return exitCar(c, capturedData, carexit.Request{
+		Plate:       capturedData.EventComment,
+		ChannelName: capturedData.ChannelName,
+		ChannelId:   capturedData.ChannelId,
+		Reason:      "Garasylyar",
+		ParkNo:      park.Code,
+		At:          eventTime(capturedData.CapturedTime, capturedData.Timestamp),
+	}, false)
+}
+
+func exitService() *carexit.Service {
+	return &carexit.Service{
+		Store:            carexit.NewGormStore(database.DB),
+		Price:            util.QuoteFee,
+		Subscriptions:    util.FindSubscription,
+		Now:              Now,
+		MaxPlateDistance: config.Int("PLATE_MAX_DISTANCE", 2),
+		LostTicketFee:    util.LostTicketFee,
 	}
+}
 
-	startTimeStr := carData.Start_time
-	endTimeStr := time.Now().Format(timeFormat)
-	startTime, _ := time.Parse(timeFormat, startTimeStr)
-	endTime, _ := time.Parse(timeFormat, endTimeStr)
-	duration := endTime.Sub(startTime)
-	minutes := duration.Minutes()
-	carData.Duration = int(minutes)
-
-	if minutes <= 360 {
-		carData.Total_payment = 2
-	} else if minutes <= 1440 {
-		carData.Total_payment = 3
-	} else {
-		days := int(math.Ceil(minutes / 1440))
-		carData.Total_payment = float64(3 * days)
+var broadcastExit = carexit.PostActionFunc(func(res *carexit.Result) error {
+	car := res.Car
+	car.Image_Url = plateImageURL(car.Image_Url)
+	operator.Broadcast <- car
+	return nil
+})
+
+// broadcastLostTicket pushes lost tickets to the operators even from exit
+// cameras that do not notify them otherwise.
+var broadcastLostTicket = carexit.PostActionFunc(func(res *carexit.Result) error {
+	if !res.Car.NoEntry {
+		return nil
 	}
+	return broadcastExit(res)
+})
 
-	if util.IsVIPPlate(capturedData.EventComment) {
-		carData.Total_payment = 0
+func exitCar(c *fiber.Ctx, capturedData camera.CapturedEventDataE, req carexit.Request, broadcast bool) error {
+	actions := []carexit.PostAction{broadcastLostTicket}
+	if broadcast {
+		actions = []carexit.PostAction{broadcastExit}
 	}
-	carData.Status = statusPending
-	carData.End_time = endTimeStr
-	carData.Reason = "Garasylyar"
-	carData.CameraID = string(capturedData.ChannelName)
 
-	if err := database.DB.Model(&carData).Updates(carData).Error; err != nil {
+	res, err := exitService().Exit(req, actions...)
+	switch {
+	case errors.Is(err, carexit.ErrBeforeEntry):
+		// The exit belongs to an entry that has not arrived yet.
+		log.Println("Exit of", req.Plate, "is earlier than its entry")
+		if err := recordOrphanExit(req, broadcast); err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Car not found",
+				"error":   err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"message": "Car not found, exit kept until its entry arrives",
+		})
+	case errors.Is(err, carexit.ErrAlreadyExited):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Car already exited",
+		})
+	case errors.Is(err, carexit.ErrWrongPark):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Car is not in the right park",
+			"car":     res.Car,
+		})
+	case err != nil:
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Database update failed",
 			"error":   err.Error(),
 		})
 	}
 
-	carData.CamToken = capturedData.ChannelId
+	// VIP visits need no payment, so their barrier is opened right away.
+	// Everyone else waits for the operator to take the payment.
+	var gateEvent *modelgate.Event
+	if res.Subscription != nil {
+		gateEvent, err = gatecontrol.OpenForCar(res.Car, gate.TriggerVIP, "")
+		if err != nil {
+			log.Println("Failed to open the gate for", res.Car.Car_number, "-", err)
+		}
+	}
 
-	ip := os.Getenv("HOST")
-	port := os.Getenv("PORT")
-	carData.Image_Url = fmt.Sprintf("http://%s:%s/plate/%s", ip, port, carData.Image_Url)
+	car := res.Car
+	car.Image_Url = plateImageURL(car.Image_Url)
+
+	message := "Car exit updated successfully"
+	if car.NoEntry {
+		log.Println("No entry found for", car.Car_number, "- lost ticket visit", car.ID)
+		message = "No entry found, lost ticket fee applied"
+		occupancy.Update()
+	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message":     "Car exit updated successfully",
-		"car":         carData,
+		"message":     message,
+		"car":         car,
 		"openCommand": capturedData,
+		"gate":        gateEvent,
 	})
 }
+
+// unknownChannel answers events from cameras that are not assigned to a
+// park, or the database error that occurred while resolving the park.
+func unknownChannel(c *fiber.Ctx, channelId string, err error) error {
+	if errors.Is(err, util.ErrUnknownChannel) {
+		log.Println("Error: Camera is not assigned to a park -", channelId)
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Camera is not assigned to a park",
+			Details: channelId,
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+		Error:   "Failed to resolve the park of the camera",
+		Details: err.Error(),
+	})
+}
+
+func plateImageURL(image string) string {
+	ip := os.Getenv("HOST")
+	port := os.Getenv("PORT")
+	return fmt.Sprintf("http://%s:%s/plate/%s", ip, port, image)
+}
@@ -0,0 +1,85 @@
+package getdata
+
+import (
+	"log"
+	"time"
+
+	"park/config"
+	"park/database"
+	modelscar "park/models/modelsCar"
+	"park/service/carexit"
+	"park/util/plate"
+	"park/util/sitetime"
+)
+
+// eventTime returns when the camera saw the car. The first non-zero camera
+// time is used unless it is ahead of the server clock by more than
+// MAX_CLOCK_SKEW or older than MAX_EVENT_DELAY, in which case the server
+// time is used instead.
+func eventTime(captured ...time.Time) time.Time {
+	now := Now()
+	maxSkew := config.Duration("MAX_CLOCK_SKEW", 2*time.Minute)
+	maxDelay := config.Duration("MAX_EVENT_DELAY", 24*time.Hour)
+
+	for _, at := range captured {
+		if at.IsZero() {
+			continue
+		}
+		if at.After(now.Add(maxSkew)) || at.Before(now.Add(-maxDelay)) {
+			log.Println("Camera time", at, "is out of the allowed range, using server time", now)
+			return now
+		}
+		return at.In(sitetime.Location())
+	}
+	return now
+}
+
+// recordOrphanExit keeps an exit that has no entry yet so that it can be
+// applied when the late entry arrives.
+func recordOrphanExit(req carexit.Request, broadcast bool) error {
+	return database.DB.Create(&modelscar.OrphanExit{
+		Car_number:  plate.Normalize(req.Plate),
+		ExitPlate:   req.Plate,
+		ParkNo:      req.ParkNo,
+		ChannelName: req.ChannelName,
+		ChannelId:   req.ChannelId,
+		Reason:      req.Reason,
+		CheckPark:   req.CheckPark,
+		Broadcast:   broadcast,
+		ExitAt:      req.At,
+	}).Error
+}
+
+// reconcileOrphanExit applies the earliest exit that was recorded for the
+// plate of a new visit after its entry time.
+func reconcileOrphanExit(car modelscar.Car_Model, enteredAt time.Time) (modelscar.Car_Model, bool) {
+	var orphan modelscar.OrphanExit
+	err := database.DB.Where("car_number = ? AND park_no = ? AND visit_id IS NULL AND exit_at >= ?",
+		car.Car_number, car.ParkNo, enteredAt).Order("exit_at").First(&orphan).Error
+	if err != nil {
+		return car, false
+	}
+
+	var actions []carexit.PostAction
+	if orphan.Broadcast {
+		actions = append(actions, broadcastExit)
+	}
+	res, err := exitService().Exit(carexit.Request{
+		Plate:       orphan.Car_number,
+		ChannelName: orphan.ChannelName,
+		ChannelId:   orphan.ChannelId,
+		Reason:      orphan.Reason,
+		ParkNo:      orphan.ParkNo,
+		CheckPark:   orphan.CheckPark,
+		At:          orphan.ExitAt,
+	}, actions...)
+	if err != nil {
+		log.Println("Failed to apply early exit of", car.Car_number, "-", err)
+		return car, false
+	}
+
+	if err := database.DB.Model(&orphan).Update("visit_id", res.Car.ID).Error; err != nil {
+		log.Println("Failed to mark early exit", orphan.ID, "as applied -", err)
+	}
+	return res.Car, true
+}
@@ -0,0 +1,144 @@
+package getdata
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm/clause"
+
+	"park/database"
+	"park/models/camera"
+)
+
+type eventKey struct {
+	EventID   string `json:"EventId"`
+	ChannelId string `json:"ChannelId"`
+}
+
+type eventLock struct {
+	sync.Mutex
+	refs int
+}
+
+var (
+	eventLocks      = make(map[eventKey]*eventLock)
+	eventLocksMutex sync.Mutex
+)
+
+// EventCache keeps the first response to every camera event.
+type EventCache interface {
+	Get(eventID, channelID string) (camera.EventLog, bool)
+	Put(entry camera.EventLog) error
+}
+
+// Events is the cache used by Idempotent. Replay swaps in a MemoryCache so
+// that the events of a journal are applied again, while redeliveries
+// within the journal are still dropped.
+var Events EventCache = DBCache{}
+
+// DBCache keeps the responses in the event_logs table.
+type DBCache struct{}
+
+func (DBCache) Get(eventID, channelID string) (camera.EventLog, bool) {
+	var logged camera.EventLog
+	err := database.DB.Where("event_id = ? AND channel_id = ?", eventID, channelID).First(&logged).Error
+	return logged, err == nil
+}
+
+func (DBCache) Put(entry camera.EventLog) error {
+	return database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry).Error
+}
+
+// MemoryCache keeps the responses of one replay run.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[eventKey]camera.EventLog
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[eventKey]camera.EventLog)}
+}
+
+func (m *MemoryCache) Get(eventID, channelID string) (camera.EventLog, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	logged, ok := m.entries[eventKey{eventID, channelID}]
+	return logged, ok
+}
+
+func (m *MemoryCache) Put(entry camera.EventLog) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := eventKey{entry.EventID, entry.ChannelId}
+	if _, ok := m.entries[key]; !ok {
+		m.entries[key] = entry
+	}
+	return nil
+}
+
+// Idempotent makes camera ingestion safe to retry. The first response to an
+// EventId and ChannelId pair is stored, and every redelivery of the same
+// event gets that response back without touching the visit again.
+// Server errors are not stored so that a retry can still succeed.
+func Idempotent(c *fiber.Ctx) error {
+	var key eventKey
+	if err := json.Unmarshal(c.Body(), &key); err != nil || key.EventID == "" {
+		return c.Next()
+	}
+
+	unlock := lockEvent(key)
+	defer unlock()
+
+	if logged, ok := Events.Get(key.EventID, key.ChannelId); ok {
+		c.Set("Idempotent-Replayed", "true")
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+		return c.Status(logged.StatusCode).SendString(logged.Response)
+	}
+
+	if err := c.Next(); err != nil {
+		return err
+	}
+
+	status := c.Response().StatusCode()
+	if status >= fiber.StatusInternalServerError {
+		return nil
+	}
+
+	entry := camera.EventLog{
+		EventID:    key.EventID,
+		ChannelId:  key.ChannelId,
+		Method:     c.Method(),
+		Path:       c.Path(),
+		StatusCode: status,
+		Response:   string(c.Response().Body()),
+	}
+	if err := Events.Put(entry); err != nil {
+		log.Println("Failed to store camera event", key.EventID, "-", err)
+	}
+	return nil
+}
+
+// lockEvent serializes concurrent deliveries of the same event.
+func lockEvent(key eventKey) func() {
+	eventLocksMutex.Lock()
+	lock, ok := eventLocks[key]
+	if !ok {
+		lock = &eventLock{}
+		eventLocks[key] = lock
+	}
+	lock.refs++
+	eventLocksMutex.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		eventLocksMutex.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(eventLocks, key)
+		}
+		eventLocksMutex.Unlock()
+	}
+}
@@ -0,0 +1,131 @@
+package getdata
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"park/database"
+	"park/models/camera"
+	"park/util/sitetime"
+)
+
+var (
+	// JournalEnabled turns off journaling while a journal is being replayed.
+	JournalEnabled = true
+	// Now is the clock of the camera pipeline. Replay points it at the
+	// receive time of the request being replayed.
+	Now = time.Now
+)
+
+var saveJournal = func(entry *camera.JournalEntry) error {
+	if database.DB == nil {
+		return nil
+	}
+	return database.DB.Create(entry).Error
+}
+
+// Journal stores every camera request verbatim together with its receive
+// time, source address and the outcome of processing it. It runs before
+// Webhook, so requests refused for their camera token are kept as well.
+func Journal(c *fiber.Ctx) error {
+	if !JournalEnabled {
+		return c.Next()
+	}
+
+	entry := camera.JournalEntry{
+		ReceivedAt: time.Now(),
+		SourceIP:   c.IP(),
+		Method:     c.Method(),
+		Path:       c.Path(),
+		Body:       string(c.Body()),
+	}
+
+	err := c.Next()
+	if err != nil {
+		entry.StatusCode = fiber.StatusInternalServerError
+		entry.Outcome = err.Error()
+	} else {
+		entry.StatusCode = c.Response().StatusCode()
+		entry.Outcome = journalOutcome(c)
+	}
+
+	if dbErr := saveJournal(&entry); dbErr != nil {
+		log.Println("Failed to journal camera request -", dbErr)
+	}
+	return err
+}
+
+func journalOutcome(c *fiber.Ctx) string {
+	var body struct {
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}
+	json.Unmarshal(c.Response().Body(), &body)
+
+	outcome := body.Message
+	if outcome == "" {
+		outcome = body.Error
+	}
+	if string(c.Response().Header.Peek("Idempotent-Replayed")) == "true" {
+		outcome = "replayed: " + outcome
+	}
+	return outcome
+}
+
+// ExportJournal godoc
+// @Summary Export the camera journal
+// @Description Streams journal entries received in a time range as JSON lines, oldest first. The output can be fed to the replay command.
+// @Tags Car Entry
+// @Produce plain
+// @Param from query string false "Received from" example("2025-01-29 00:00:00")
+// @Param to query string false "Received until" example("2025-01-29 23:59:59")
+// @Success 200 {string} string "JSON lines"
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid time format"
+// @Router /api/v1/camera/journal/export [get]
+func ExportJournal(c *fiber.Ctx) error {
+	query := database.DB.Model(&camera.JournalEntry{})
+
+	for param, condition := range map[string]string{"from": "received_at >= ?", "to": "received_at <= ?"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		at, err := sitetime.Parse(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid " + param + " format. Use YYYY-MM-DD HH:MM:SS.",
+			})
+		}
+		query = query.Where(condition, at)
+	}
+
+	rows, err := query.Order("id").Rows()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to read journal",
+			"error":   err.Error(),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	c.Set(fiber.HeaderContentDisposition, "attachment; filename=camera-journal.jsonl")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer rows.Close()
+		encoder := json.NewEncoder(w)
+		for rows.Next() {
+			var entry camera.JournalEntry
+			if err := database.DB.ScanRows(rows, &entry); err != nil {
+				log.Println("Failed to read journal entry -", err)
+				return
+			}
+			if err := encoder.Encode(entry); err != nil {
+				return
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,102 @@
+package getdata
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	resmodel "park/controller/getdata/resModel"
+	"park/controller/operator"
+	"park/database"
+	modelscar "park/models/modelsCar"
+	"park/service/audit"
+	"park/service/carexit"
+	"park/util/sitetime"
+)
+
+type LostTicketEntry struct {
+	StartTime string `json:"start_time" example:"2025-01-29 10:13:51"`
+}
+
+// matchLostTicket completes a lost ticket visit with an entry that the
+// camera reported after the exit.
+func matchLostTicket(c *fiber.Ctx, car modelscar.Car_Model, enteredAt time.Time) error {
+	car, err := exitService().SetEntry(car, enteredAt, false)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to apply the entry to the lost ticket",
+			Details: err.Error(),
+		})
+	}
+	operator.Refresh <- struct{}{}
+
+	return c.Status(fiber.StatusCreated).JSON(resmodel.Response{
+		Message: "Car entry matched a lost ticket",
+		Data:    car,
+	})
+}
+
+// SetLostTicketEntry godoc
+// @Summary Estimate the entry of a lost ticket
+// @Description Replaces the lost ticket fee of a visit without entry by the fee of a stay from the estimated entry time to its exit.
+// @Tags Car Entry
+// @Accept json
+// @Produce json
+// @Param id path int true "Car ID"
+// @Param request body LostTicketEntry true "Estimated entry time"
+// @Success 200 {object} resmodel.Response "Visit repriced"
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid time or the visit has an entry"
+// @Failure 404 {object} resmodel.ErrorResponse "Car not found"
+// @Failure 500 {object} resmodel.ErrorResponse "Database error"
+// @Router /api/v1/camera/lostticket/{id} [put]
+func SetLostTicketEntry(c *fiber.Ctx) error {
+	var car modelscar.Car_Model
+	if err := database.DB.First(&car, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(resmodel.ErrorResponse{
+			Error:   "Car not found",
+			Details: err.Error(),
+		})
+	}
+	audit.Before(c, "car", strconv.Itoa(car.ID), car)
+
+	var input LostTicketEntry
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to parse request body",
+			Details: err.Error(),
+		})
+	}
+	start, err := sitetime.Parse(input.StartTime)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Invalid start_time format. Use YYYY-MM-DD HH:MM:SS.",
+			Details: err.Error(),
+		})
+	}
+
+	car, err = exitService().SetEntry(car, start, true)
+	switch {
+	case errors.Is(err, carexit.ErrHasEntry):
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{Error: "Car has an entry"})
+	case errors.Is(err, carexit.ErrAlreadyExited):
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{Error: "Car already exited"})
+	case errors.Is(err, carexit.ErrBeforeEntry):
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{Error: "Entry time is after the exit"})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Database update failed",
+			Details: err.Error(),
+		})
+	}
+	audit.After(c, "car", strconv.Itoa(car.ID), car)
+
+	car.Image_Url = plateImageURL(car.Image_Url)
+	operator.Broadcast <- car
+
+	return c.Status(fiber.StatusOK).JSON(resmodel.Response{
+		Message: "Lost ticket repriced with the estimated entry",
+		Data:    car,
+	})
+}
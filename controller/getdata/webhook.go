@@ -0,0 +1,47 @@
+package getdata
+
+import (
+	"crypto/subtle"
+	"log"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+
+	"park/service/security"
+)
+
+// WebhookTokenHeader carries the shared camera token. Cameras that can not
+// send headers may pass it as the token query parameter instead.
+const WebhookTokenHeader = "X-Webhook-Token"
+
+// WebhookAuthEnabled turns off the token check while a journal is being
+// replayed; the journal holds requests that were checked when received.
+var WebhookAuthEnabled = true
+
+// Webhook lets camera events through only when they carry the token set in
+// CAMERA_WEBHOOK_TOKEN. Without a configured token every event is refused.
+func Webhook(c *fiber.Ctx) error {
+	if !WebhookAuthEnabled {
+		return c.Next()
+	}
+
+	expected := os.Getenv("CAMERA_WEBHOOK_TOKEN")
+	if expected == "" {
+		log.Println("CAMERA_WEBHOOK_TOKEN is not set, refusing camera event from", c.IP())
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"message": "Camera webhooks are not configured",
+		})
+	}
+
+	token := c.Get(WebhookTokenHeader)
+	if token == "" {
+		token = c.Query("token")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		security.Record(c, security.WebhookRejected, "", "invalid camera token")
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Unauthorized - invalid camera token",
+		})
+	}
+	return c.Next()
+}
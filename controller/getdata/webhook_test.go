@@ -0,0 +1,77 @@
+package getdata
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"park/models/camera"
+)
+
+func TestWebhook(t *testing.T) {
+	t.Setenv("CAMERA_WEBHOOK_TOKEN", "camera-secret")
+	app := fiber.New()
+	app.Post("/", Webhook, func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusCreated) })
+
+	cases := []struct {
+		name   string
+		target string
+		header string
+		auth   bool
+		want   int
+	}{
+		{"header", "/", "camera-secret", true, fiber.StatusCreated},
+		{"query", "/?token=camera-secret", "", true, fiber.StatusCreated},
+		{"wrong", "/", "camera-secrex", true, fiber.StatusUnauthorized},
+		{"missing", "/", "", true, fiber.StatusUnauthorized},
+		{"replay", "/", "", false, fiber.StatusCreated},
+	}
+	for _, tc := range cases {
+		WebhookAuthEnabled = tc.auth
+		req := httptest.NewRequest("POST", tc.target, nil)
+		if tc.header != "" {
+			req.Header.Set(WebhookTokenHeader, tc.header)
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.name, resp.StatusCode, tc.want)
+		}
+	}
+	WebhookAuthEnabled = true
+}
+
+func TestJournalKeepsRefusedRequests(t *testing.T) {
+	t.Setenv("CAMERA_WEBHOOK_TOKEN", "camera-secret")
+	defer func(save func(*camera.JournalEntry) error) { saveJournal = save }(saveJournal)
+	var journaled []camera.JournalEntry
+	saveJournal = func(entry *camera.JournalEntry) error {
+		journaled = append(journaled, *entry)
+		return nil
+	}
+
+	app := fiber.New()
+	app.Post("/api/v1/camera/getdata", Journal, Webhook, func(c *fiber.Ctx) error {
+		t.Fatal("a request without a camera token reached the handler")
+		return nil
+	})
+
+	body := `{"EventId":"e1","ChannelId":"c1"}`
+	resp, err := app.Test(httptest.NewRequest("POST", "/api/v1/camera/getdata", strings.NewReader(body)), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusUnauthorized {
+		t.Fatalf("got %d, want 401", resp.StatusCode)
+	}
+	if len(journaled) != 1 {
+		t.Fatalf("journaled %d entries, want 1", len(journaled))
+	}
+	if entry := journaled[0]; entry.Body != body || entry.StatusCode != fiber.StatusUnauthorized || entry.Path != "/api/v1/camera/getdata" {
+		t.Fatalf("journal entry = %+v", entry)
+	}
+}
@@ -0,0 +1,348 @@
+package occupancy
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	resmodel "park/controller/getdata/resModel"
+	"park/database"
+	modelscar "park/models/modelsCar"
+	modelpark "park/models/parkModel"
+	"park/service/audit"
+)
+
+// Occupancy is the number of cars in a park. Inside and Pending visits are
+// counted, plus the manual adjustment of the park.
+type Occupancy struct {
+	ParkNo     string `json:"park_no"`
+	Capacity   int    `json:"capacity"`
+	Occupied   int    `json:"occupied"`
+	Free       int    `json:"free"`
+	Full       bool   `json:"full"`
+	Adjustment int    `json:"adjustment"`
+}
+
+type AdjustRequest struct {
+	Occupied int `json:"occupied" example:"57"`
+}
+
+// ErrFull is returned by Admit when the park has no free place.
+var ErrFull = errors.New("park is full")
+
+var openStatuses = []string{"Inside", "Pending"}
+
+var (
+	counts      = make(map[string]Occupancy)
+	countsMutex sync.RWMutex
+	// recountMutex serializes recounts, so counts never go back to an
+	// older state.
+	recountMutex sync.Mutex
+	// updates holds at most one pending recount for Run. Updates asked for
+	// while one is pending are covered by it.
+	updates = make(chan struct{}, 1)
+	// clients maps every websocket connection to the mutex that serializes
+	// its writes, so a slow client does not hold up the others.
+	clients      = make(map[*websocket.Conn]*sync.Mutex)
+	clientsMutex sync.Mutex
+)
+
+// newOccupancy combines a park with its open visits. A capacity of 0 means
+// the park is never full.
+func newOccupancy(park modelpark.Park, visits int) Occupancy {
+	o := Occupancy{
+		ParkNo:     park.Code,
+		Capacity:   park.Capacity,
+		Occupied:   max(visits+park.OccupancyAdjustment, 0),
+		Adjustment: park.OccupancyAdjustment,
+	}
+	if o.Capacity > 0 {
+		o.Free = max(o.Capacity-o.Occupied, 0)
+		o.Full = o.Occupied >= o.Capacity
+	}
+	return o
+}
+
+func countVisits(db *gorm.DB) (map[string]int, error) {
+	var rows []struct {
+		ParkNo string
+		Total  int
+	}
+	err := db.Model(&modelscar.Car_Model{}).
+		Select("park_no, COUNT(*) AS total").
+		Where("status IN ?", openStatuses).
+		Group("park_no").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	visits := make(map[string]int, len(rows))
+	for _, row := range rows {
+		visits[row.ParkNo] = row.Total
+	}
+	return visits, nil
+}
+
+// Admit locks the row of the park, counts its open visits and runs insert in
+// the same transaction when a place is free. Entries to the same park wait on
+// the lock, so the last free place is given out only once. The returned
+// occupancy is the one seen before the insert.
+func Admit(parkNo string, insert func(tx *gorm.DB) error) (Occupancy, error) {
+	var o Occupancy
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var park modelpark.Park
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("code = ?", parkNo).First(&park).Error; err != nil {
+			return err
+		}
+		var visits int64
+		if err := tx.Model(&modelscar.Car_Model{}).
+			Where("park_no = ? AND status IN ?", parkNo, openStatuses).
+			Count(&visits).Error; err != nil {
+			return err
+		}
+		o = newOccupancy(park, int(visits))
+		if o.Full {
+			return ErrFull
+		}
+		return insert(tx)
+	})
+	return o, err
+}
+
+// Update asks Run to recount the visits of every park and push the result
+// to the websocket clients. It returns at once, so camera events do not
+// wait for slow clients. It is called after every entry, exit and status
+// change.
+func Update() {
+	select {
+	case updates <- struct{}{}:
+	default:
+	}
+}
+
+// Run recounts and broadcasts for every Update, one at a time.
+func Run() {
+	for range updates {
+		next, err := Recount()
+		if err != nil {
+			log.Println("Failed to count occupancy -", err)
+			continue
+		}
+		broadcast(list(next))
+	}
+}
+
+// Recount counts the open visits of every park and stores the result. The
+// rows of the parks are locked for share, so the count waits for entries
+// that Admit is letting in and sees them.
+func Recount() (map[string]Occupancy, error) {
+	recountMutex.Lock()
+	defer recountMutex.Unlock()
+
+	var next map[string]Occupancy
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var parks []modelpark.Park
+		if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Find(&parks).Error; err != nil {
+			return err
+		}
+		visits, err := countVisits(tx)
+		if err != nil {
+			return err
+		}
+		next = make(map[string]Occupancy, len(parks))
+		for _, park := range parks {
+			next[park.Code] = newOccupancy(park, visits[park.Code])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	countsMutex.Lock()
+	counts = next
+	countsMutex.Unlock()
+	return next, nil
+}
+
+// Get returns the last known occupancy of a park.
+func Get(parkNo string) (Occupancy, bool) {
+	countsMutex.RLock()
+	defer countsMutex.RUnlock()
+	o, ok := counts[parkNo]
+	return o, ok
+}
+
+// list orders the occupancy of every park by park code. REST and websocket
+// clients receive the same list.
+func list(counts map[string]Occupancy) []Occupancy {
+	l := make([]Occupancy, 0, len(counts))
+	for _, o := range counts {
+		l = append(l, o)
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].ParkNo < l[j].ParkNo })
+	return l
+}
+
+func snapshot() []Occupancy {
+	countsMutex.RLock()
+	defer countsMutex.RUnlock()
+	return list(counts)
+}
+
+// broadcast copies the clients under the mutex and writes to them after
+// releasing it.
+func broadcast(occupancy []Occupancy) {
+	clientsMutex.Lock()
+	targets := make(map[*websocket.Conn]*sync.Mutex, len(clients))
+	for client, mu := range clients {
+		targets[client] = mu
+	}
+	clientsMutex.Unlock()
+
+	for client, mu := range targets {
+		mu.Lock()
+		err := client.WriteJSON(occupancy)
+		mu.Unlock()
+		if err != nil {
+			drop(client)
+		}
+	}
+}
+
+func drop(client *websocket.Conn) {
+	clientsMutex.Lock()
+	delete(clients, client)
+	clientsMutex.Unlock()
+	client.Close()
+}
+
+// GetOccupancy godoc
+// @Summary Get live occupancy
+// @Description Returns the occupancy, capacity and free places of every park.
+// @Tags Parks
+// @Produce json
+// @Success 200 {array} Occupancy
+// @Router /api/v1/occupancy [get]
+func GetOccupancy(c *fiber.Ctx) error {
+	return c.Status(200).JSON(snapshot())
+}
+
+// GetParkOccupancy godoc
+// @Summary Get live occupancy of a park
+// @Tags Parks
+// @Produce json
+// @Param code path string true "Park code"
+// @Success 200 {object} Occupancy
+// @Failure 404 {object} resmodel.ErrorResponse "Park not found"
+// @Router /api/v1/occupancy/{code} [get]
+func GetParkOccupancy(c *fiber.Ctx) error {
+	o, ok := Get(c.Params("code"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(resmodel.ErrorResponse{Error: "Park not found"})
+	}
+	return c.Status(200).JSON(o)
+}
+
+// AdjustOccupancy godoc
+// @Summary Correct the occupancy of a park
+// @Description Sets the number of cars in a park after a miscount. The difference to the counted visits is kept as the adjustment of the park until it is set again.
+// @Tags Parks
+// @Accept json
+// @Produce json
+// @Param code path string true "Park code"
+// @Param request body AdjustRequest true "Actual number of cars"
+// @Success 200 {object} Occupancy
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid request data"
+// @Failure 404 {object} resmodel.ErrorResponse "Park not found"
+// @Failure 500 {object} resmodel.ErrorResponse "Database error"
+// @Router /api/v1/occupancy/{code} [put]
+func AdjustOccupancy(c *fiber.Ctx) error {
+	var park modelpark.Park
+	if err := database.DB.Where("code = ?", c.Params("code")).First(&park).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(resmodel.ErrorResponse{
+			Error:   "Park not found",
+			Details: err.Error(),
+		})
+	}
+
+	var req AdjustRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to parse request body",
+			Details: err.Error(),
+		})
+	}
+	if req.Occupied < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{Error: "Occupied can not be negative"})
+	}
+	if before, ok := Get(park.Code); ok {
+		audit.Before(c, "occupancy", park.Code, before)
+	}
+
+	visits, err := countVisits(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to count visits",
+			Details: err.Error(),
+		})
+	}
+
+	adjustment := req.Occupied - visits[park.Code]
+	if err := database.DB.Model(&park).Update("occupancy_adjustment", adjustment).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to save the adjustment",
+			Details: err.Error(),
+		})
+	}
+	log.Printf("Occupancy of %s set to %d (adjustment %d)", park.Code, req.Occupied, adjustment)
+
+	park.OccupancyAdjustment = adjustment
+	o := newOccupancy(park, visits[park.Code])
+	Update()
+	audit.After(c, "occupancy", park.Code, o)
+	return c.Status(200).JSON(o)
+}
+
+// OccupancyUpgrade godoc
+// @Summary Live occupancy over WebSocket
+// @Description Sends the occupancy of all parks on connect and after every change, as the same list GET /api/v1/occupancy returns.
+// @Tags Parks
+// @Success 101 {object} nil "WebSocket upgrade"
+// @Router /api/v1/occupancy/ws [get]
+func OccupancyUpgrade(c *fiber.Ctx) error {
+	if websocket.IsWebSocketUpgrade(c) {
+		return c.Next()
+	}
+	return fiber.ErrUpgradeRequired
+}
+
+func Ws(c *websocket.Conn) {
+	// The client is registered with its write mutex held, so a broadcast
+	// that starts meanwhile is sent after the first snapshot.
+	mu := &sync.Mutex{}
+	mu.Lock()
+	clientsMutex.Lock()
+	clients[c] = mu
+	clientsMutex.Unlock()
+	err := c.WriteJSON(snapshot())
+	mu.Unlock()
+	defer drop(c)
+	if err != nil {
+		return
+	}
+
+	for {
+		if _, _, err := c.ReadMessage(); err != nil {
+			break
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package occupancy
+
+import (
+	"testing"
+
+	modelpark "park/models/parkModel"
+)
+
+func TestNewOccupancy(t *testing.T) {
+	o := newOccupancy(modelpark.Park{Code: "P1", Capacity: 10, OccupancyAdjustment: -2}, 12)
+	if o.Occupied != 10 || o.Free != 0 || !o.Full {
+		t.Fatalf("got %+v, want 10 occupied and full", o)
+	}
+
+	o = newOccupancy(modelpark.Park{Code: "P2"}, 500)
+	if o.Full || o.Free != 0 {
+		t.Fatalf("park without capacity is full: %+v", o)
+	}
+
+	o = newOccupancy(modelpark.Park{Code: "P3", Capacity: 5, OccupancyAdjustment: -4}, 1)
+	if o.Occupied != 0 || o.Free != 5 {
+		t.Fatalf("negative occupancy not clamped: %+v", o)
+	}
+}
+
+func TestListIsOrderedByPark(t *testing.T) {
+	l := list(map[string]Occupancy{
+		"P3": {ParkNo: "P3"},
+		"P1": {ParkNo: "P1"},
+		"P2": {ParkNo: "P2"},
+	})
+	for i, want := range []string{"P1", "P2", "P3"} {
+		if l[i].ParkNo != want {
+			t.Fatalf("list[%d] = %s, want %s", i, l[i].ParkNo, want)
+		}
+	}
+}
+
+func TestUpdateDoesNotWait(t *testing.T) {
+	defer func() {
+		select {
+		case <-updates:
+		default:
+		}
+	}()
+
+	// Without Run nothing takes the updates; they must still return and
+	// leave a single recount pending.
+	for i := 0; i < 3; i++ {
+		Update()
+	}
+	if len(updates) != 1 {
+		t.Fatalf("%d recounts pending, want 1", len(updates))
+	}
+}
@@ -0,0 +1,155 @@
+package operator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"park/database"
+	"park/middleware"
+	modelexemption "park/models/exemptionModel"
+	modelscar "park/models/modelsCar"
+	modelsuser "park/models/modelsUser"
+	"park/service/exemption"
+	"park/service/ledger"
+	"park/service/loginguard"
+	"park/service/security"
+	"park/util"
+	"park/util/sitetime"
+)
+
+// ApprovePermission is held by the supervisors who approve exemptions.
+const ApprovePermission = "exemptions.approve"
+
+// refusal is an exemption the operator is not allowed to give, with the
+// response that tells them why.
+type refusal struct {
+	status int
+	body   fiber.Map
+}
+
+func (r *refusal) Error() string { return fmt.Sprint(r.body["message"]) }
+
+// quoteFee prices a stay that has not been priced for its exit yet.
+var quoteFee = util.QuoteFee
+
+// checkPin checks the approval PIN of a supervisor.
+var checkPin = func(username, pin string) (modelsuser.User, error) {
+	return exemption.CheckPin(database.DB, username, pin)
+}
+
+// exitFee is the fee in minor units that the exit of car waives. The fee
+// is computed at the exit, so a car still inside is priced up to now.
+func exitFee(car modelscar.Car_Model, now time.Time) int64 {
+	if (car.Status != statusInside && !car.End_time.IsZero()) || car.Start_time.IsZero() {
+		return ledger.ToMinor(car.Total_payment)
+	}
+	fee, err := quoteFee(car.Start_time.Time, now)
+	if err != nil {
+		return ledger.ToMinor(car.Total_payment)
+	}
+	return ledger.ToMinor(fee)
+}
+
+// priceExit closes the visit of car, let out by hand at now, and prices it
+// like the exit camera would. A car already priced at the exit keeps its end
+// time and fee. The visit is closed even when it can not be priced.
+func priceExit(car modelscar.Car_Model, now time.Time) (modelscar.Car_Model, error) {
+	if car.Status != statusInside && !car.End_time.IsZero() {
+		return car, nil
+	}
+	car.End_time = sitetime.From(now)
+	if car.Start_time.IsZero() {
+		return car, errors.New("the visit has no start time")
+	}
+	car.Duration = int(now.Sub(car.Start_time.Time).Minutes())
+	fee, err := quoteFee(car.Start_time.Time, now)
+	if err != nil {
+		return car, err
+	}
+	car.Total_payment = fee
+	return car, nil
+}
+
+// authorizeExemption checks the exemption of fee asked for by the exit of
+// car and returns it, not yet stored. An exemption approved by a request
+// has the id of the request and is claimed when the exit is stored. When
+// the car could not be priced the fee is unknown, so the exemption always
+// needs approval.
+func authorizeExemption(c *fiber.Ctx, car modelscar.Car_Model, fee int64, priced bool, operator string, input modelscar.CarUpdate) (*modelexemption.Exemption, error) {
+	reason, err := exemption.FindReason(database.DB, input.Reason)
+	if errors.Is(err, exemption.ErrUnknownReason) {
+		return nil, &refusal{fiber.StatusBadRequest, fiber.Map{"message": "Unknown exemption reason", "reason": input.Reason}}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ex := &modelexemption.Exemption{
+		CarId:       car.ID,
+		CarNumber:   car.Car_number,
+		ParkNo:      car.ParkNo,
+		ReasonCode:  reason.Code,
+		Note:        input.Note,
+		RequestedBy: operator,
+		Via:         modelexemption.ViaNone,
+	}
+	role, _ := c.Locals("role").(string)
+	switch {
+	case input.ApprovalId != 0:
+		ex.Id = input.ApprovalId
+	case priced && !exemption.NeedsApproval(reason, fee):
+	case middleware.Allowed(ApprovePermission, modelsuser.RoleType(role)):
+		ex.Via = modelexemption.ViaSelf
+		ex.ApprovedBy = operator
+	case input.Supervisor != "":
+		if err := checkSupervisor(c, car, input.Supervisor, input.Pin); err != nil {
+			return nil, err
+		}
+		ex.Via = modelexemption.ViaPin
+		ex.ApprovedBy = input.Supervisor
+	default:
+		return nil, &refusal{fiber.StatusForbidden, fiber.Map{
+			"message":           "Supervisor approval required",
+			"approval_required": true,
+			"reason":            reason.Code,
+		}}
+	}
+	return ex, nil
+}
+
+// checkSupervisor checks the PIN a supervisor entered at the terminal.
+// Failures are throttled like logins.
+func checkSupervisor(c *fiber.Ctx, car modelscar.Car_Model, username, pin string) error {
+	key := "pin:" + username
+	if wait, _ := loginguard.Wait(key, c.IP()); wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		security.Record(c, security.SupervisorPinThrottled, username, fmt.Sprintf("retry after %ds", seconds))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+		return &refusal{fiber.StatusTooManyRequests, fiber.Map{"message": "Too many PIN attempts", "retry_after": seconds}}
+	}
+
+	user, err := checkPin(username, pin)
+	if err != nil && !errors.Is(err, exemption.ErrInvalidPin) {
+		return err
+	}
+	if err != nil || !middleware.Allowed(ApprovePermission, user.Role) {
+		loginguard.Fail(key, c.IP())
+		security.Record(c, security.SupervisorPinFailed, username, "exit of "+car.Car_number)
+		return &refusal{fiber.StatusForbidden, fiber.Map{"message": "Invalid supervisor or PIN", "approval_required": true}}
+	}
+	loginguard.Succeed(key)
+	return nil
+}
+
+// claimRefused reports whether err refused the approved request sent with
+// an exit. A request that does not exist is answered apart.
+func claimRefused(err error) bool {
+	return errors.Is(err, exemption.ErrNotApproved) ||
+		errors.Is(err, exemption.ErrExpired) ||
+		errors.Is(err, exemption.ErrMismatch)
+}
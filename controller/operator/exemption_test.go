@@ -0,0 +1,128 @@
+package operator
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	modelscar "park/models/modelsCar"
+	modelsuser "park/models/modelsUser"
+	"park/service/exemption"
+	"park/service/loginguard"
+	"park/util/sitetime"
+)
+
+func TestExitFeeQuotesCarsInside(t *testing.T) {
+	now := time.Date(2025, 1, 29, 14, 0, 0, 0, time.UTC)
+	defer func(quote func(start, end time.Time) (float64, error)) { quoteFee = quote }(quoteFee)
+	quoteFee = func(start, end time.Time) (float64, error) {
+		return end.Sub(start).Hours() * 10, nil
+	}
+
+	inside := modelscar.Car_Model{
+		Status:     statusInside,
+		Start_time: sitetime.From(now.Add(-3 * time.Hour)),
+	}
+	if got := exitFee(inside, now); got != 3000 {
+		t.Fatalf("exitFee of a car inside = %d, want 3000", got)
+	}
+
+	pending := inside
+	pending.Status = "Pending"
+	pending.End_time = sitetime.From(now.Add(-time.Hour))
+	pending.Total_payment = 25
+	if got := exitFee(pending, now); got != 2500 {
+		t.Fatalf("exitFee of a priced exit = %d, want 2500", got)
+	}
+}
+
+func TestPriceExitClosesTheVisit(t *testing.T) {
+	now := time.Date(2025, 1, 29, 14, 0, 0, 0, time.UTC)
+	defer func(quote func(start, end time.Time) (float64, error)) { quoteFee = quote }(quoteFee)
+	quoteFee = func(start, end time.Time) (float64, error) {
+		return end.Sub(start).Hours() * 10, nil
+	}
+
+	inside := modelscar.Car_Model{
+		Status:     statusInside,
+		Start_time: sitetime.From(now.Add(-150 * time.Minute)),
+	}
+	got, err := priceExit(inside, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Total_payment != 25 || !got.End_time.Time.Equal(now) || got.Duration != 150 {
+		t.Fatalf("car inside let out with fee %v, end %v and %d minutes, want 25 at %v after 150 minutes",
+			got.Total_payment, got.End_time.Time, got.Duration, now)
+	}
+
+	pending := modelscar.Car_Model{
+		Status:        "Pending",
+		Start_time:    sitetime.From(now.Add(-3 * time.Hour)),
+		End_time:      sitetime.From(now.Add(-time.Hour)),
+		Duration:      120,
+		Total_payment: 18,
+	}
+	if got, err := priceExit(pending, now); err != nil || got != pending {
+		t.Fatalf("priced exit changed to %+v (%v), want it kept", got, err)
+	}
+
+	quoteFee = func(start, end time.Time) (float64, error) {
+		return 0, errors.New("no tariff")
+	}
+	got, err = priceExit(inside, now)
+	if err == nil {
+		t.Fatalf("car inside priced at %v without a tariff, want an error", got.Total_payment)
+	}
+	if got.Total_payment != 0 || !got.End_time.Time.Equal(now) {
+		t.Fatalf("unpriced exit closed at %v with fee %v, want the visit closed at %v", got.End_time.Time, got.Total_payment, now)
+	}
+}
+
+func TestSupervisorPinIsThrottled(t *testing.T) {
+	const supervisor = "pin-throttle-supervisor"
+	t.Setenv("LOGIN_FREE_ATTEMPTS", "3")
+
+	defer func(check func(string, string) (modelsuser.User, error)) { checkPin = check }(checkPin)
+	checks := 0
+	checkPin = func(username, pin string) (modelsuser.User, error) {
+		checks++
+		return modelsuser.User{}, exemption.ErrInvalidPin
+	}
+
+	app := fiber.New()
+	app.Post("/", func(c *fiber.Ctx) error {
+		err := checkSupervisor(c, modelscar.Car_Model{Car_number: "BE5084AG"}, supervisor, "000000")
+		var refused *refusal
+		if errors.As(err, &refused) {
+			return c.Status(refused.status).JSON(refused.body)
+		}
+		return err
+	})
+	attempt := func() (int, string) {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, resp.Header.Get(fiber.HeaderRetryAfter)
+	}
+
+	for i := 0; i < 4; i++ {
+		if status, _ := attempt(); status != fiber.StatusForbidden {
+			t.Fatalf("wrong PIN %d answered %d, want 403", i+1, status)
+		}
+	}
+	status, retryAfter := attempt()
+	if status != fiber.StatusTooManyRequests || retryAfter == "" {
+		t.Fatalf("PIN after repeated failures answered %d with Retry-After %q, want 429", status, retryAfter)
+	}
+	if checks != 4 {
+		t.Fatalf("PIN checked %d times, want 4: a throttled attempt must not reach the PIN", checks)
+	}
+	if wait, _ := loginguard.Wait(supervisor, "10.0.0.9"); wait > 0 {
+		t.Fatalf("PIN failures throttled the login of the supervisor for %s", wait)
+	}
+}
@@ -0,0 +1,37 @@
+package operator
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	modelscar "park/models/modelsCar"
+	"park/util/export"
+)
+
+// ExportCars godoc
+// @Summary Export cars
+// @Description Streams the cars matched by the filters of /api/v1/searchcar as CSV or XLSX, without pagination.
+// @Tags cars
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv or xlsx" default(csv)
+// @Param car_number query string false "Filter by car plate number (partial match allowed)"
+// @Param enter_time query string false "Start of enter time range (YYYY-MM-DD)"
+// @Param end_time query string false "End of end time range (YYYY-MM-DD)"
+// @Param status query string false "Filter by car status (Inside, Exited)"
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Router /api/v1/searchcar/export [get]
+func ExportCars(c *fiber.Ctx) error {
+	query, message := searchQuery(c)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": message})
+	}
+
+	header := []string{"ID", "Car number", "Park", "Entry", "Exit", "Duration", "Status", "Total payment", "Reason", "Operator", "No entry", "Entry estimated"}
+	return export.Stream(c, query.Order("id DESC"), "cars", header, func(car modelscar.Car_Model) []interface{} {
+		return []interface{}{
+			car.ID, car.Car_number, car.ParkNo, car.Start_time, car.End_time, car.Duration, car.Status,
+			car.Total_payment, car.Reason, car.User_id, car.NoEntry, car.EntryEstimated,
+		}
+	})
+}
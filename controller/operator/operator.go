@@ -1,21 +1,35 @@
 package operator
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"math"
 	"os"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 
+	gatecontrol "park/controller/gateControl"
+	"park/controller/occupancy"
 	"park/database"
+	modelexemption "park/models/exemptionModel"
 	modelscar "park/models/modelsCar"
+	modelpayment "park/models/paymentModel"
+	"park/service/audit"
+	"park/service/exemption"
+	"park/service/gate"
+	"park/service/ledger"
+	platenorm "park/util/plate"
+	"park/util/sitetime"
 )
 
-const statusExited = "Exited"
+const (
+	statusExited = "Exited"
+	statusInside = "Inside"
+)
 
 type UpdateCarResponse struct {
 	Message string              `json:"message"`
@@ -115,7 +129,7 @@ func GetCar(c *fiber.Ctx) error {
 
 // UpdateCar godoc
 // @Summary Update a car by plate number
-// @Description Updates a car's status and calculates payment and duration based on start and end times.
+// @Description Lets a car out and takes the unpaid part of its fee. A car still inside is priced up to now; one that can not be priced only leaves with an approved exemption. A reason from the exemption catalog lets it out without payment; reasons marked for approval, and fees above EXEMPTION_APPROVAL_THRESHOLD, need a supervisor PIN or the id of an approved exemption request, unless the operator may approve exemptions.
 // @Tags cars
 // @Accept  json
 // @Produce  json
@@ -123,11 +137,14 @@ func GetCar(c *fiber.Ctx) error {
 // @Param car body modelscar.CarUpdate true "Car details to update"
 // @Success 200 {object} map[string]interface{} "Updated car details"
 // @Failure 400 {object} ErrorResponse "Car already exited or invalid request"
+// @Failure 403 {object} map[string]interface{} "Supervisor approval required"
 // @Failure 404 {object} ErrorResponse "Car not found"
+// @Failure 409 {object} ErrorResponse "Cash without an open shift, the car can not be priced, or the exemption request can not be used"
+// @Failure 429 {object} map[string]interface{} "Too many PIN attempts"
 // @Failure 500 {object} ErrorResponse "Error parsing time"
 // @Router /api/v1/camera/updatecar/{plate} [put]
 func UpdateCar(c *fiber.Ctx) error {
-	plate := c.Params("plate")
+	plate := platenorm.Normalize(c.Params("plate"))
 	userIDVal := c.Locals("username")
 
 	var car modelscar.Car_Model
@@ -137,42 +154,127 @@ func UpdateCar(c *fiber.Ctx) error {
 	if car.Status == statusExited {
 		return c.Status(400).JSON("Car already Exited")
 	}
+	audit.Before(c, "car", strconv.Itoa(car.ID), car)
 
-	var updatedCar modelscar.Car_Model
-	if err := c.BodyParser(&updatedCar); err != nil {
+	var input modelscar.CarUpdate
+	if err := c.BodyParser(&input); err != nil {
 		return c.Status(400).JSON(fiber.Map{"message": "Invalid request", "error": err.Error()})
 	}
+	updatedCar := modelscar.Car_Model{Reason: input.Reason}
+
+	method := modelpayment.Cash
+	if input.Method != "" {
+		method = modelpayment.Method(input.Method)
+	}
+	if car.SubscriptionId != nil {
+		method = modelpayment.Subscription
+	}
+	if !ledger.ValidMethod(method) {
+		return c.Status(400).JSON(fiber.Map{"message": "Invalid payment method"})
+	}
 
+	userID, ok := userIDVal.(string)
+	if !ok {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error - Invalid user ID type",
+		})
+	}
+	updatedCar.User_id = userID
 	updatedCar.Status = statusExited
 
+	now := sitetime.Now()
+	fee := exitFee(car, now)
+	priced, priceErr := priceExit(car, now)
+	var exempt *modelexemption.Exemption
 	if updatedCar.Reason == "" {
+		if priceErr != nil {
+			return c.Status(409).JSON(fiber.Map{
+				"message":           "Can not price the car, let it out with an approved exemption",
+				"error":             priceErr.Error(),
+				"approval_required": true,
+			})
+		}
 		updatedCar.Reason = "Toleg edildi"
-		updatedCar.Total_payment = car.Total_payment
+		updatedCar.Total_payment = priced.Total_payment
 	} else {
+		var err error
+		exempt, err = authorizeExemption(c, car, fee, priceErr == nil, userID, input)
+		var refused *refusal
+		if errors.As(err, &refused) {
+			return c.Status(refused.status).JSON(refused.body)
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"message": "Can not check the exemption", "error": err.Error()})
+		}
+		updatedCar.Reason = exempt.ReasonCode
 		updatedCar.Total_payment = 0
 	}
 
 	car.Reason = updatedCar.Reason
 	car.Total_payment = updatedCar.Total_payment
+	car.End_time = priced.End_time
+	car.Duration = priced.Duration
 	updatedCar.End_time = car.End_time
+	updatedCar.Duration = car.Duration
+
+	var payment *modelpayment.Payment
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if exempt != nil && exempt.Id != 0 {
+			claimed, err := exemption.Claim(tx, exempt.Id, car.ID, exempt.ReasonCode, now)
+			if err != nil {
+				return err
+			}
+			*exempt = claimed
+		}
 
-	userID, ok := userIDVal.(string)
-	if !ok {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Internal Server Error - Invalid user ID type",
-		})
-	}
-	updatedCar.User_id = userID
+		if err := tx.Model(&car).Updates(map[string]interface{}{
+			"reason":        updatedCar.Reason,
+			"total_payment": updatedCar.Total_payment,
+			"user_id":       updatedCar.User_id,
+			"status":        updatedCar.Status,
+			"end_time":      updatedCar.End_time,
+			"duration":      updatedCar.Duration,
+			"fuzzy_match":   false,
+		}).Error; err != nil {
+			return err
+		}
 
-	if err := database.DB.Model(&car).Updates(map[string]interface{}{
-		"reason":        updatedCar.Reason,
-		"total_payment": updatedCar.Total_payment,
-		"user_id":       updatedCar.User_id,
-		"status":        updatedCar.Status,
-		"end_time":      updatedCar.End_time,
-	}).Error; err != nil {
+		// Only the part of the fee that has not been paid yet is taken.
+		paid, err := ledger.Paid(tx, car.ID)
+		if err != nil {
+			return err
+		}
+		if exempt != nil {
+			if err := exemption.Apply(tx, exempt, max(fee-paid, 0), now); err != nil {
+				return err
+			}
+		}
+		due := ledger.ToMinor(updatedCar.Total_payment) - paid
+		if due <= 0 && method != modelpayment.Subscription {
+			return nil
+		}
+		row, err := ledger.Pay(tx, car, max(due, 0), method, userID, updatedCar.Reason)
+		payment = &row
+		return err
+	})
+	if errors.Is(err, ledger.ErrNoOpenShift) {
+		return c.Status(409).JSON(fiber.Map{"message": "Open a shift before taking cash", "error": err.Error()})
+	}
+	if errors.Is(err, exemption.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"message": "Exemption request not found"})
+	}
+	if claimRefused(err) {
+		return c.Status(409).JSON(fiber.Map{"message": "Exemption request can not be used", "error": err.Error(), "approval_required": true})
+	}
+	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"message": "Database update failed", "error": err.Error()})
 	}
+	occupancy.Update()
+
+	gateEvent, err := gatecontrol.OpenForCar(car, gate.TriggerPayment, userID)
+	if err != nil {
+		log.Println("Failed to open the gate for", car.Car_number, "-", err)
+	}
 
 	updatedCar.ID = car.ID
 	updatedCar.Car_number = car.Car_number
@@ -181,12 +283,63 @@ func UpdateCar(c *fiber.Ctx) error {
 	updatedCar.End_time = car.End_time
 	updatedCar.Image_Url = car.Image_Url
 
+	var stored modelscar.Car_Model
+	if database.DB.First(&stored, car.ID).Error == nil {
+		audit.After(c, "car", strconv.Itoa(car.ID), stored)
+	}
+
 	return c.Status(200).JSON(fiber.Map{
-		"message": "Car updated successfully",
-		"car":     updatedCar},
+		"message":   "Car updated successfully",
+		"car":       updatedCar,
+		"payment":   payment,
+		"exemption": exempt,
+		"gate":      gateEvent},
 	)
 }
 
+// RejectMatch godoc
+// @Summary Reject a fuzzy plate match
+// @Description Puts a visit that was matched to a misread exit plate back inside, clearing the exit fields.
+// @Tags cars
+// @Produce  json
+// @Param id path int true "Car ID"
+// @Success 200 {object} UpdateCarResponse
+// @Failure 400 {object} ErrorResponse "Visit was not matched by plate similarity"
+// @Failure 404 {object} ErrorResponse "Car not found"
+// @Failure 500 {object} ErrorResponse "Database update failed"
+// @Router /api/v1/camera/rejectmatch/{id} [put]
+func RejectMatch(c *fiber.Ctx) error {
+	var car modelscar.Car_Model
+	if err := database.DB.First(&car, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"message": "Car not found", "error": err.Error()})
+	}
+	if !car.FuzzyMatch || car.Status == statusExited {
+		return c.Status(400).JSON(fiber.Map{"message": "Car is not waiting for match confirmation"})
+	}
+	audit.Before(c, "car", strconv.Itoa(car.ID), car)
+
+	if err := database.DB.Model(&car).Updates(map[string]interface{}{
+		"status":          statusInside,
+		"end_time":        nil,
+		"duration":        0,
+		"total_payment":   0,
+		"subscription_id": nil,
+		"reason":          "entry",
+		"exit_plate":      "",
+		"fuzzy_match":     false,
+	}).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Database update failed", "error": err.Error()})
+	}
+	database.DB.First(&car, car.ID)
+	audit.After(c, "car", strconv.Itoa(car.ID), car)
+	Refresh <- struct{}{}
+
+	return c.Status(200).JSON(UpdateCarResponse{
+		Message: "Match rejected, car is inside again",
+		Car:     car,
+	})
+}
+
 // SearchCar godoc
 // @Summary Search for cars
 // @Description Retrieve a paginated list of cars with optional filtering by car number, enter time range, end time range, park number, and status.
@@ -207,11 +360,6 @@ func SearchCar(c *fiber.Ctx) error {
 	var cars []modelscar.Car_Model
 	var totalCount int64
 
-	carNumber := c.Query("car_number")
-	enterTime := c.Query("enter_time")
-	endTime := c.Query("end_time")
-	parkNo, _ := c.Locals("parkno").(string)
-	status := c.Query("status")
 	pageStr := c.Query("page", "1")
 	limitStr := c.Query("limit", "5")
 
@@ -225,36 +373,9 @@ func SearchCar(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid limit number"})
 	}
 
-	baseQuery := database.DB.Model(&modelscar.Car_Model{}).Debug()
-
-	if carNumber != "" {
-		baseQuery = baseQuery.Where("car_number LIKE ?", "%"+strings.TrimSpace(carNumber)+"%")
-	}
-
-	if enterTime != "" {
-		if _, err := time.Parse("2006-01-02", enterTime); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid enter_time format. Use YYYY-MM-DD."})
-		}
-		baseQuery = baseQuery.Where("start_time >= ?", enterTime+" 00:00:00")
-	}
-
-	if endTime != "" {
-		if _, err := time.Parse("2006-01-02", endTime); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid end_time format. Use YYYY-MM-DD."})
-		}
-		baseQuery = baseQuery.Where("end_time <= ?", endTime+" 23:59:59")
-	}
-
-	if parkNo != "" {
-		baseQuery = baseQuery.Where("park_no = ?", parkNo)
-	}
-
-	if status != "" {
-		validStatuses := map[string]bool{"Inside": true, "Exited": true}
-		if !validStatuses[status] {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid status. Use Inside or Exited."})
-		}
-		baseQuery = baseQuery.Where("status = ?", status)
+	baseQuery, message := searchQuery(c)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": message})
 	}
 
 	if err := baseQuery.Count(&totalCount).Error; err != nil {
@@ -298,6 +419,51 @@ func SearchCar(c *fiber.Ctx) error {
 	})
 }
 
+// searchQuery applies the filters of SearchCar. It returns a message when
+// a filter is invalid.
+func searchQuery(c *fiber.Ctx) (*gorm.DB, string) {
+	carNumber := c.Query("car_number")
+	enterTime := c.Query("enter_time")
+	endTime := c.Query("end_time")
+	parkNo, _ := c.Locals("parkno").(string)
+	status := c.Query("status")
+
+	baseQuery := database.DB.Model(&modelscar.Car_Model{}).Debug()
+
+	if carNumber != "" {
+		baseQuery = baseQuery.Where("car_number LIKE ?", "%"+strings.TrimSpace(carNumber)+"%")
+	}
+
+	if enterTime != "" {
+		day, err := sitetime.ParseDate(enterTime)
+		if err != nil {
+			return nil, "Invalid enter_time format. Use YYYY-MM-DD."
+		}
+		baseQuery = baseQuery.Where("start_time >= ?", day)
+	}
+
+	if endTime != "" {
+		day, err := sitetime.ParseDate(endTime)
+		if err != nil {
+			return nil, "Invalid end_time format. Use YYYY-MM-DD."
+		}
+		baseQuery = baseQuery.Where("end_time < ?", day.AddDate(0, 0, 1))
+	}
+
+	if parkNo != "" {
+		baseQuery = baseQuery.Where("park_no = ?", parkNo)
+	}
+
+	if status != "" {
+		validStatuses := map[string]bool{"Inside": true, "Exited": true}
+		if !validStatuses[status] {
+			return nil, "Invalid status. Use Inside or Exited."
+		}
+		baseQuery = baseQuery.Where("status = ?", status)
+	}
+	return baseQuery, ""
+}
+
 type GetCarsResponse struct {
 	Cars       []modelscar.Car_Model `json:"cars"`
 	Page       int                   `json:"page"`
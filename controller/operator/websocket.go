@@ -1,22 +1,50 @@
 package operator
 
 import (
+	"sync"
+
 	modelscar "park/models/modelsCar"
 
+	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 )
 
-var clients = make(map[*websocket.Conn]bool)
+// clients maps every connection to the park it follows. Connections
+// without a park receive the cars of all parks.
+var clients = make(map[*websocket.Conn]string)
+var clientsMutex sync.Mutex
 var Broadcast = make(chan modelscar.Car_Model)
 var Refresh = make(chan struct{})
 
+// Upgrade lets a websocket client follow the park it logged in to. The
+// park comes from the token; a parkno query naming another park is
+// refused.
+func Upgrade(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return fiber.ErrUpgradeRequired
+	}
+	park, _ := c.Locals("parkno").(string)
+	if query := c.Query("parkno"); query != "" && query != park {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Forbidden - parkno is not the park of the session",
+		})
+	}
+	return c.Next()
+}
+
 func Ws(c *websocket.Conn) {
+	park, _ := c.Locals("parkno").(string)
+
 	defer func() {
+		clientsMutex.Lock()
 		delete(clients, c)
+		clientsMutex.Unlock()
 		c.Close()
 	}()
 
-	clients[c] = true
+	clientsMutex.Lock()
+	clients[c] = park
+	clientsMutex.Unlock()
 
 	for {
 		var msg interface{}
@@ -37,20 +65,27 @@ func HandleMessages() {
 	for {
 		select {
 		case car := <-Broadcast:
-			for client := range clients {
-				if err := client.WriteJSON(car); err != nil {
-					client.Close()
-					delete(clients, client)
-				}
-			}
+			send(car.ParkNo, car)
 
 		case <-Refresh:
-			for client := range clients {
-				if err := client.WriteJSON("refresh"); err != nil {
-					client.Close()
-					delete(clients, client)
-				}
-			}
+			send("", "refresh")
+		}
+	}
+}
+
+// send writes msg to the clients of park, or to every client when park is
+// empty.
+func send(park string, msg interface{}) {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+
+	for client, clientPark := range clients {
+		if park != "" && clientPark != "" && clientPark != park {
+			continue
+		}
+		if err := client.WriteJSON(msg); err != nil {
+			client.Close()
+			delete(clients, client)
 		}
 	}
 }
@@ -0,0 +1,33 @@
+package operator
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestUpgradeFollowsParkOfSession(t *testing.T) {
+	app := fiber.New()
+	app.Get("/ws", func(c *fiber.Ctx) error {
+		c.Locals("parkno", "P4")
+		return c.Next()
+	}, Upgrade, func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusSwitchingProtocols) })
+
+	for target, want := range map[string]int{
+		"/ws":           fiber.StatusSwitchingProtocols,
+		"/ws?parkno=P4": fiber.StatusSwitchingProtocols,
+		"/ws?parkno=P5": fiber.StatusForbidden,
+	} {
+		req := httptest.NewRequest(fiber.MethodGet, target, nil)
+		req.Header.Set(fiber.HeaderConnection, "Upgrade")
+		req.Header.Set(fiber.HeaderUpgrade, "websocket")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("%s answered %d, want %d", target, resp.StatusCode, want)
+		}
+	}
+}
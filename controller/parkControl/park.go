@@ -0,0 +1,305 @@
+package parkcontrol
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	resmodel "park/controller/getdata/resModel"
+	"park/controller/occupancy"
+	"park/database"
+	"park/models/camera"
+	modelpark "park/models/parkModel"
+	"park/service/audit"
+)
+
+type ParkInput struct {
+	Code     string `json:"code" example:"P4"`
+	Name     string `json:"name" example:"Terminal parking"`
+	Capacity int    `json:"capacity" example:"120"`
+	TimeZone string `json:"time_zone" example:"Asia/Ashgabat"`
+}
+
+type CamerasInput struct {
+	CameraIds []int `json:"camera_ids" example:"1,2"`
+}
+
+func (in ParkInput) validate() string {
+	if in.Code == "" {
+		return "Park code is required"
+	}
+	if in.Capacity < 0 {
+		return "Capacity can not be negative"
+	}
+	if in.TimeZone != "" {
+		if _, err := time.LoadLocation(in.TimeZone); err != nil {
+			return "Unknown time zone"
+		}
+	}
+	return ""
+}
+
+// CreatePark godoc
+// @Summary Create a park
+// @Description Creates a parking lot. Cars entering through its cameras are counted against its capacity; 0 means unlimited. Reports of the park count days in its time zone, an IANA name such as Asia/Ashgabat; without one the site time zone is used.
+// @Tags Parks
+// @Accept json
+// @Produce json
+// @Param park body ParkInput true "Park"
+// @Success 201 {object} modelpark.Park "Successfully created"
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid request data"
+// @Failure 409 {object} resmodel.ErrorResponse "Park code already exists"
+// @Failure 500 {object} resmodel.ErrorResponse "Failed to save data to the database"
+// @Router /api/v1/parks [post]
+func CreatePark(c *fiber.Ctx) error {
+	var input ParkInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to parse request body",
+			Details: err.Error(),
+		})
+	}
+	if msg := input.validate(); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{Error: msg})
+	}
+
+	var existing modelpark.Park
+	if err := database.DB.Where("code = ?", input.Code).First(&existing).Error; err == nil {
+		return c.Status(fiber.StatusConflict).JSON(resmodel.ErrorResponse{
+			Error: "Park with code " + input.Code + " already exists",
+		})
+	}
+
+	park := modelpark.Park{
+		Code:     input.Code,
+		Name:     input.Name,
+		Capacity: input.Capacity,
+		TimeZone: input.TimeZone,
+	}
+
+	if err := database.DB.Create(&park).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to save data to the database",
+			Details: err.Error(),
+		})
+	}
+	audit.After(c, "park", strconv.Itoa(park.Id), park)
+	occupancy.Update()
+	return c.Status(201).JSON(park)
+}
+
+// GetParks godoc
+// @Summary Get all parks
+// @Description Retrieves all parks with their cameras.
+// @Tags Parks
+// @Produce json
+// @Success 200 {array} modelpark.Park "List of parks"
+// @Failure 500 {object} resmodel.ErrorResponse "Database error"
+// @Router /api/v1/parks [get]
+func GetParks(c *fiber.Ctx) error {
+	var parks []modelpark.Park
+	if err := database.DB.Preload("Cameras").Order("code").Find(&parks).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to retrieve parks",
+			Details: err.Error(),
+		})
+	}
+	return c.Status(200).JSON(parks)
+}
+
+// GetPark godoc
+// @Summary Get a park
+// @Description Retrieves a park with its cameras by ID.
+// @Tags Parks
+// @Produce json
+// @Param id path int true "Park ID"
+// @Success 200 {object} modelpark.Park
+// @Failure 404 {object} resmodel.ErrorResponse "Park not found"
+// @Router /api/v1/parks/{id} [get]
+func GetPark(c *fiber.Ctx) error {
+	var park modelpark.Park
+	if err := database.DB.Preload("Cameras").First(&park, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(resmodel.ErrorResponse{
+			Error:   "Park not found",
+			Details: err.Error(),
+		})
+	}
+	return c.Status(200).JSON(park)
+}
+
+// UpdatePark godoc
+// @Summary Update a park
+// @Description Updates the name, capacity and time zone of a park. The code can only change while no visits use it.
+// @Tags Parks
+// @Accept json
+// @Produce json
+// @Param id path int true "Park ID"
+// @Param park body ParkInput true "Park"
+// @Success 200 {object} modelpark.Park
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid request data"
+// @Failure 404 {object} resmodel.ErrorResponse "Park not found"
+// @Failure 409 {object} resmodel.ErrorResponse "Park code is in use"
+// @Failure 500 {object} resmodel.ErrorResponse "Database error"
+// @Router /api/v1/parks/{id} [put]
+func UpdatePark(c *fiber.Ctx) error {
+	var park modelpark.Park
+	if err := database.DB.First(&park, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(resmodel.ErrorResponse{
+			Error:   "Park not found",
+			Details: err.Error(),
+		})
+	}
+	audit.Before(c, "park", strconv.Itoa(park.Id), park)
+
+	var input ParkInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to parse request body",
+			Details: err.Error(),
+		})
+	}
+	if input.Code == "" {
+		input.Code = park.Code
+	}
+	if msg := input.validate(); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{Error: msg})
+	}
+
+	if input.Code != park.Code {
+		var used int64
+		database.DB.Model(&modelpark.Park{}).Where("code = ?", input.Code).Count(&used)
+		if used == 0 {
+			database.DB.Table("car_models").Where("park_no = ?", park.Code).Count(&used)
+		}
+		if used > 0 {
+			return c.Status(fiber.StatusConflict).JSON(resmodel.ErrorResponse{
+				Error: "Park code can not be changed while it is in use",
+			})
+		}
+		park.Code = input.Code
+	}
+	if input.Name != "" {
+		park.Name = input.Name
+	}
+	if input.TimeZone != "" {
+		park.TimeZone = input.TimeZone
+	}
+	park.Capacity = input.Capacity
+
+	if err := database.DB.Save(&park).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to update park",
+			Details: err.Error(),
+		})
+	}
+	audit.After(c, "park", strconv.Itoa(park.Id), park)
+	occupancy.Update()
+	return c.Status(200).JSON(park)
+}
+
+// DeletePark godoc
+// @Summary Delete a park
+// @Description Deletes a park. Its cameras are kept but no longer belong to a park.
+// @Tags Parks
+// @Param id path int true "Park ID"
+// @Success 200 {string} string "Park successfully deleted"
+// @Failure 404 {object} resmodel.ErrorResponse "Park not found"
+// @Failure 500 {object} resmodel.ErrorResponse "Database error"
+// @Router /api/v1/parks/{id} [delete]
+func DeletePark(c *fiber.Ctx) error {
+	var park modelpark.Park
+	if err := database.DB.First(&park, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(resmodel.ErrorResponse{
+			Error:   "Park not found",
+			Details: err.Error(),
+		})
+	}
+	audit.Before(c, "park", strconv.Itoa(park.Id), park)
+
+	tx := database.DB.Begin()
+	if err := tx.Model(&camera.CamFix{}).Where("park_id = ?", park.Id).Update("park_id", nil).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to delete park",
+			Details: err.Error(),
+		})
+	}
+	if err := tx.Delete(&park).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to delete park",
+			Details: err.Error(),
+		})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to delete park",
+			Details: err.Error(),
+		})
+	}
+
+	occupancy.Update()
+	return c.Status(200).JSON(fiber.Map{"message": "Park successfully deleted"})
+}
+
+// SetParkCameras godoc
+// @Summary Assign cameras to a park
+// @Description Replaces the cameras of a park. Cameras are identified by their CamFix ID and leave any park they belonged to before.
+// @Tags Parks
+// @Accept json
+// @Produce json
+// @Param id path int true "Park ID"
+// @Param cameras body CamerasInput true "Camera IDs"
+// @Success 200 {object} modelpark.Park
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid request data"
+// @Failure 404 {object} resmodel.ErrorResponse "Park not found"
+// @Failure 500 {object} resmodel.ErrorResponse "Database error"
+// @Router /api/v1/parks/{id}/cameras [put]
+func SetParkCameras(c *fiber.Ctx) error {
+	var park modelpark.Park
+	if err := database.DB.Preload("Cameras").First(&park, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(resmodel.ErrorResponse{
+			Error:   "Park not found",
+			Details: err.Error(),
+		})
+	}
+	audit.Before(c, "park", strconv.Itoa(park.Id), park)
+
+	var input CamerasInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to parse request body",
+			Details: err.Error(),
+		})
+	}
+
+	tx := database.DB.Begin()
+	if err := tx.Model(&camera.CamFix{}).Where("park_id = ?", park.Id).Update("park_id", nil).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to assign cameras",
+			Details: err.Error(),
+		})
+	}
+	if len(input.CameraIds) > 0 {
+		if err := tx.Model(&camera.CamFix{}).Where("id IN ?", input.CameraIds).Update("park_id", park.Id).Error; err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+				Error:   "Failed to assign cameras",
+				Details: err.Error(),
+			})
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to assign cameras",
+			Details: err.Error(),
+		})
+	}
+
+	park.Cameras = nil
+	database.DB.Preload("Cameras").First(&park, park.Id)
+	audit.After(c, "park", strconv.Itoa(park.Id), park)
+	return c.Status(200).JSON(park)
+}
@@ -0,0 +1,252 @@
+package payments
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	resmodel "park/controller/getdata/resModel"
+	"park/database"
+	modelscar "park/models/modelsCar"
+	modelpayment "park/models/paymentModel"
+	"park/service/audit"
+	"park/service/ledger"
+	"park/util/export"
+	"park/util/sitetime"
+)
+
+type PaymentInput struct {
+	CarId  int    `json:"car_id" example:"42"`
+	Amount int64  `json:"amount" example:"150"`
+	Method string `json:"method" example:"card"`
+	Note   string `json:"note"`
+}
+
+type ReverseInput struct {
+	Amount int64  `json:"amount" example:"50"`
+	Note   string `json:"note" example:"Charged twice"`
+}
+
+type PaymentsResponse struct {
+	Payments []modelpayment.Payment `json:"payments"`
+	Total    int64                  `json:"total"`
+}
+
+func ledgerError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(resmodel.ErrorResponse{Error: "Payment not found"})
+	case errors.Is(err, ledger.ErrInvalidAmount), errors.Is(err, ledger.ErrInvalidMethod),
+		errors.Is(err, ledger.ErrNotPayment), errors.Is(err, ledger.ErrExceedsBalance):
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, ledger.ErrNoOpenShift):
+		return c.Status(fiber.StatusConflict).JSON(resmodel.ErrorResponse{Error: err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+		Error:   "Failed to save data to the database",
+		Details: err.Error(),
+	})
+}
+
+// CreatePayment godoc
+// @Summary Record a payment
+// @Description Records a full or partial payment of a visit. The amount is in minor units.
+// @Tags Payments
+// @Accept json
+// @Produce json
+// @Param payment body PaymentInput true "Payment"
+// @Success 201 {object} modelpayment.Payment
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid amount or method"
+// @Failure 404 {object} resmodel.ErrorResponse "Car not found"
+// @Failure 409 {object} resmodel.ErrorResponse "Cash without an open shift"
+// @Router /api/v1/payments [post]
+func CreatePayment(c *fiber.Ctx) error {
+	var input PaymentInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to parse request body",
+			Details: err.Error(),
+		})
+	}
+
+	var car modelscar.Car_Model
+	if err := database.DB.First(&car, input.CarId).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(resmodel.ErrorResponse{
+			Error:   "Car not found",
+			Details: err.Error(),
+		})
+	}
+
+	operator, _ := c.Locals("username").(string)
+	payment, err := ledger.Pay(database.DB, car, input.Amount, modelpayment.Method(input.Method), operator, input.Note)
+	if err != nil {
+		return ledgerError(c, err)
+	}
+	audit.After(c, "payment", strconv.Itoa(payment.Id), payment)
+	return c.Status(201).JSON(payment)
+}
+
+// RefundPayment godoc
+// @Summary Refund a payment
+// @Description Returns part or all of a payment. The amount is in minor units and can not exceed what is left of the payment.
+// @Tags Payments
+// @Accept json
+// @Produce json
+// @Param id path int true "Payment ID"
+// @Param refund body ReverseInput true "Refund"
+// @Success 201 {object} modelpayment.Payment "Refund row"
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid amount"
+// @Failure 404 {object} resmodel.ErrorResponse "Payment not found"
+// @Router /api/v1/payments/{id}/refund [post]
+func RefundPayment(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{Error: "Invalid payment ID"})
+	}
+	var input ReverseInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to parse request body",
+			Details: err.Error(),
+		})
+	}
+
+	operator, _ := c.Locals("username").(string)
+	refund, err := ledger.Refund(database.DB, id, input.Amount, operator, input.Note)
+	if err != nil {
+		return ledgerError(c, err)
+	}
+	audit.After(c, "payment", strconv.Itoa(refund.Id), refund)
+	return c.Status(201).JSON(refund)
+}
+
+// VoidPayment godoc
+// @Summary Void a payment
+// @Description Cancels what is left of a payment that was taken by mistake.
+// @Tags Payments
+// @Accept json
+// @Produce json
+// @Param id path int true "Payment ID"
+// @Param void body ReverseInput false "Note"
+// @Success 201 {object} modelpayment.Payment "Void row"
+// @Failure 400 {object} resmodel.ErrorResponse "Payment is already reversed"
+// @Failure 404 {object} resmodel.ErrorResponse "Payment not found"
+// @Router /api/v1/payments/{id}/void [post]
+func VoidPayment(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{Error: "Invalid payment ID"})
+	}
+	var input ReverseInput
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+				Error:   "Failed to parse request body",
+				Details: err.Error(),
+			})
+		}
+	}
+
+	operator, _ := c.Locals("username").(string)
+	void, err := ledger.Void(database.DB, id, operator, input.Note)
+	if err != nil {
+		return ledgerError(c, err)
+	}
+	audit.After(c, "payment", strconv.Itoa(void.Id), void)
+	return c.Status(201).JSON(void)
+}
+
+// GetPayments godoc
+// @Summary List payments
+// @Description Lists ledger rows, newest first, with their net total in minor units.
+// @Tags Payments
+// @Produce json
+// @Param car_id query int false "Visit"
+// @Param operator query string false "Operator username"
+// @Param parkno query string false "Park code"
+// @Param start query string false "From" example("2025-01-29 00:00:00")
+// @Param end query string false "Until" example("2025-01-29 23:59:59")
+// @Success 200 {object} PaymentsResponse
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid time format"
+// @Failure 500 {object} resmodel.ErrorResponse "Database error"
+// @Router /api/v1/payments [get]
+func GetPayments(c *fiber.Ctx) error {
+	query, err := paymentQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{Error: err.Error()})
+	}
+
+	var rows []modelpayment.Payment
+	if err := query.Session(&gorm.Session{}).Order("id DESC").Find(&rows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to retrieve payments",
+			Details: err.Error(),
+		})
+	}
+	total, err := ledger.Sum(query)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to sum payments",
+			Details: err.Error(),
+		})
+	}
+
+	return c.Status(200).JSON(PaymentsResponse{Payments: rows, Total: total})
+}
+
+// paymentQuery applies the filters of GetPayments.
+func paymentQuery(c *fiber.Ctx) (*gorm.DB, error) {
+	query := database.DB.Model(&modelpayment.Payment{})
+	if carID := c.QueryInt("car_id"); carID != 0 {
+		query = query.Where("car_id = ?", carID)
+	}
+	if operator := c.Query("operator"); operator != "" {
+		query = query.Where("operator = ?", operator)
+	}
+	if parkNo := c.Query("parkno"); parkNo != "" {
+		query = query.Where("park_no = ?", parkNo)
+	}
+	for param, condition := range map[string]string{"start": "created_at >= ?", "end": "created_at <= ?"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		at, err := sitetime.Parse(value)
+		if err != nil {
+			return nil, errors.New("Invalid " + param + " format. Use YYYY-MM-DD HH:MM:SS.")
+		}
+		query = query.Where(condition, at)
+	}
+	return query, nil
+}
+
+// ExportPayments godoc
+// @Summary Export payments
+// @Description Streams the ledger rows matched by the filters of GET /api/v1/payments as CSV or XLSX, newest first. Amounts are in minor units.
+// @Tags Payments
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv or xlsx" default(csv)
+// @Param car_id query int false "Visit"
+// @Param operator query string false "Operator username"
+// @Param parkno query string false "Park code"
+// @Param start query string false "From" example("2025-01-29 00:00:00")
+// @Param end query string false "Until" example("2025-01-29 23:59:59")
+// @Success 200 {file} file
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid time format"
+// @Router /api/v1/payments/export [get]
+func ExportPayments(c *fiber.Ctx) error {
+	query, err := paymentQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{Error: err.Error()})
+	}
+
+	header := []string{"ID", "Car ID", "Amount", "Method", "Kind", "Operator", "Shift", "Park", "Reverses", "Note", "Created"}
+	return export.Stream(c, query.Order("id DESC"), "payments", header, func(p modelpayment.Payment) []interface{} {
+		return []interface{}{
+			p.Id, p.CarId, p.Amount, string(p.Method), string(p.Kind), p.Operator, p.ShiftId, p.ParkNo, p.RefId, p.Note, p.CreatedAt,
+		}
+	})
+}
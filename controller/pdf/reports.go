@@ -0,0 +1,381 @@
+package pdfGenerator
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jung-kurt/gofpdf"
+
+	resmodel "park/controller/getdata/resModel"
+	"park/database"
+	modelscar "park/models/modelsCar"
+	modelsuser "park/models/modelsUser"
+	modelpayment "park/models/paymentModel"
+	modelshift "park/models/shiftModel"
+	"park/service/ledger"
+	"park/util"
+	"park/util/pdfdoc"
+	"park/util/sitetime"
+)
+
+// reportFilter is the date range, park and operator a report is built for.
+// End is exclusive.
+type reportFilter struct {
+	Start    time.Time
+	End      time.Time
+	ParkNo   string
+	Operator string
+	// Location is the time zone of the park, or of the site when the
+	// report is not for one park.
+	Location *time.Location
+}
+
+func (f reportFilter) String() string {
+	s := fmt.Sprintf("%s — %s", f.Start.Format("2006-01-02"), f.End.AddDate(0, 0, -1).Format("2006-01-02"))
+	if f.ParkNo != "" {
+		s += ", awtoduralga " + f.ParkNo
+	}
+	if f.Operator != "" {
+		s += ", operator " + f.Operator
+	}
+	return s
+}
+
+// parseFilter reads the start and end dates, both inclusive and defaulting
+// to today, and the parkno and operator query parameters. Operators only
+// get their own data.
+func parseFilter(c *fiber.Ctx) (reportFilter, error) {
+	parkNo := c.Query("parkno")
+	loc := util.ParkLocation(parkNo)
+	today := time.Now().In(loc).Format("2006-01-02")
+	start, err := sitetime.ParseDateIn(c.Query("start", today), loc)
+	if err != nil {
+		return reportFilter{}, err
+	}
+	end, err := sitetime.ParseDateIn(c.Query("end", today), loc)
+	if err != nil {
+		return reportFilter{}, err
+	}
+
+	f := reportFilter{
+		Start:    start,
+		End:      end.AddDate(0, 0, 1),
+		ParkNo:   parkNo,
+		Operator: c.Query("operator"),
+		Location: loc,
+	}
+	if role, _ := c.Locals("role").(string); role == string(modelsuser.OperatorRole) {
+		f.Operator, _ = c.Locals("username").(string)
+	}
+	return f, nil
+}
+
+func invalidFilter(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+		Error:   "Invalid date format. Use YYYY-MM-DD.",
+		Details: err.Error(),
+	})
+}
+
+func queryFailed(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+		Error:   "Failed to query the report data",
+		Details: err.Error(),
+	})
+}
+
+// send renders pdf in memory and sends it as an attachment.
+func send(c *fiber.Ctx, pdf *gofpdf.Fpdf, filename string) error {
+	data, err := pdfdoc.Bytes(pdf)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error generating PDF")
+	}
+	c.Set("Content-Type", "application/pdf")
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	return c.Send(data)
+}
+
+func money(amount int64) string {
+	return fmt.Sprintf("%.2f", ledger.FromMinor(amount))
+}
+
+type dailyRow struct {
+	Day     string
+	Entries int
+	Exits   int
+	Free    int
+	Methods map[modelpayment.Method]int64
+	Net     int64
+}
+
+type dayRevenue struct {
+	Day    string
+	Method modelpayment.Method
+	Amount int64
+}
+
+type dayEntries struct {
+	Day   string
+	Count int
+}
+
+type dayExits struct {
+	Day   string
+	Count int
+	Free  int
+}
+
+var dailyMethods = []modelpayment.Method{modelpayment.Cash, modelpayment.Card, modelpayment.QR, modelpayment.Subscription}
+
+// summarize merges the revenue, entries and exits of every day into rows
+// ordered by day.
+func summarize(revenue []dayRevenue, entries []dayEntries, exits []dayExits) []*dailyRow {
+	days := make(map[string]*dailyRow)
+	row := func(day string) *dailyRow {
+		if days[day] == nil {
+			days[day] = &dailyRow{Day: day, Methods: make(map[modelpayment.Method]int64)}
+		}
+		return days[day]
+	}
+	for _, r := range revenue {
+		row(r.Day).Methods[r.Method] += r.Amount
+		row(r.Day).Net += r.Amount
+	}
+	for _, e := range entries {
+		row(e.Day).Entries = e.Count
+	}
+	for _, e := range exits {
+		row(e.Day).Exits = e.Count
+		row(e.Day).Free = e.Free
+	}
+
+	rows := make([]*dailyRow, 0, len(days))
+	for _, d := range days {
+		rows = append(rows, d)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Day < rows[j].Day })
+	return rows
+}
+
+// dailyTable returns the cells of the daily summary with a total row.
+func dailyTable(days []*dailyRow) [][]string {
+	var rows [][]string
+	total := dailyRow{Methods: make(map[modelpayment.Method]int64)}
+	for _, d := range days {
+		r := []string{d.Day, fmt.Sprint(d.Entries), fmt.Sprint(d.Exits), fmt.Sprint(d.Free)}
+		for _, m := range dailyMethods {
+			r = append(r, money(d.Methods[m]))
+			total.Methods[m] += d.Methods[m]
+		}
+		rows = append(rows, append(r, money(d.Net)))
+		total.Entries += d.Entries
+		total.Exits += d.Exits
+		total.Free += d.Free
+		total.Net += d.Net
+	}
+	r := []string{"Jemi", fmt.Sprint(total.Entries), fmt.Sprint(total.Exits), fmt.Sprint(total.Free)}
+	for _, m := range dailyMethods {
+		r = append(r, money(total.Methods[m]))
+	}
+	return append(rows, append(r, money(total.Net)))
+}
+
+// DailySummary godoc
+// @Summary Daily summary PDF
+// @Description Entries, exits, free exits and revenue by payment method for every day of the range. Exits of VIP subscribers are not counted as free.
+// @Tags Reports
+// @Produce application/pdf
+// @Param start query string false "First day, default today" example("2025-01-01")
+// @Param end query string false "Last day, default today" example("2025-01-31")
+// @Param parkno query string false "Park code"
+// @Param operator query string false "Operator username"
+// @Success 200 {file} file
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid date format"
+// @Failure 500 {object} resmodel.ErrorResponse "Database error"
+// @Router /api/v1/reports/pdf/daily [get]
+func DailySummary(c *fiber.Ctx) error {
+	f, err := parseFilter(c)
+	if err != nil {
+		return invalidFilter(c, err)
+	}
+	zone := f.Location.String()
+
+	var revenue []dayRevenue
+	query := database.DB.Model(&modelpayment.Payment{}).
+		Select("TO_CHAR(created_at AT TIME ZONE ?, 'YYYY-MM-DD') AS day, method, SUM(amount) AS amount", zone).
+		Where("created_at >= ? AND created_at < ?", f.Start, f.End)
+	if f.ParkNo != "" {
+		query = query.Where("park_no = ?", f.ParkNo)
+	}
+	if f.Operator != "" {
+		query = query.Where("operator = ?", f.Operator)
+	}
+	if err := query.Group("day, method").Scan(&revenue).Error; err != nil {
+		return queryFailed(c, err)
+	}
+
+	var entries []dayEntries
+	query = database.DB.Model(&modelscar.Car_Model{}).
+		Select("TO_CHAR(start_time AT TIME ZONE ?, 'YYYY-MM-DD') AS day, COUNT(*) AS count", zone).
+		Where("start_time >= ? AND start_time < ?", f.Start, f.End)
+	if f.ParkNo != "" {
+		query = query.Where("park_no = ?", f.ParkNo)
+	}
+	if err := query.Group("day").Scan(&entries).Error; err != nil {
+		return queryFailed(c, err)
+	}
+
+	// VIP subscribers pay with their subscription, so only unpaid exits
+	// without one are free.
+	var exits []dayExits
+	query = database.DB.Model(&modelscar.Car_Model{}).
+		Select(`TO_CHAR(end_time AT TIME ZONE ?, 'YYYY-MM-DD') AS day, COUNT(*) AS count,
+			COUNT(*) FILTER (WHERE total_payment = 0 AND subscription_id IS NULL) AS free`, zone).
+		Where("status = ? AND end_time >= ? AND end_time < ?", "Exited", f.Start, f.End)
+	if f.ParkNo != "" {
+		query = query.Where("park_no = ?", f.ParkNo)
+	}
+	if f.Operator != "" {
+		query = query.Where("user_id = ?", f.Operator)
+	}
+	if err := query.Group("day").Scan(&exits).Error; err != nil {
+		return queryFailed(c, err)
+	}
+
+	pdf := pdfdoc.New("L")
+	pdf.AddPage()
+	pdfdoc.Title(pdf, "Gündelik hasabat", f.String())
+	pdfdoc.Table(pdf, []float64{30, 25, 25, 25, 30, 30, 30, 35, 35},
+		[]string{"Gün", "Girenler", "Çykanlar", "Mugt", "Nagt", "Kart", "QR", "Abunalyk", "Jemi"},
+		dailyTable(summarize(revenue, entries, exits)))
+	return send(c, pdf, "daily-summary.pdf")
+}
+
+// OperatorShifts godoc
+// @Summary Operator shift PDF
+// @Description Every shift opened in the range with its float, expected and declared cash, variance and net takings.
+// @Tags Reports
+// @Produce application/pdf
+// @Param start query string false "First day, default today" example("2025-01-01")
+// @Param end query string false "Last day, default today" example("2025-01-31")
+// @Param parkno query string false "Park code"
+// @Param operator query string false "Operator username"
+// @Success 200 {file} file
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid date format"
+// @Failure 500 {object} resmodel.ErrorResponse "Database error"
+// @Router /api/v1/reports/pdf/shifts [get]
+func OperatorShifts(c *fiber.Ctx) error {
+	f, err := parseFilter(c)
+	if err != nil {
+		return invalidFilter(c, err)
+	}
+
+	query := database.DB.Where("opened_at >= ? AND opened_at < ?", f.Start, f.End)
+	if f.ParkNo != "" {
+		query = query.Where("park_no = ?", f.ParkNo)
+	}
+	if f.Operator != "" {
+		query = query.Where("operator = ?", f.Operator)
+	}
+	var shifts []modelshift.Shift
+	if err := query.Order("opened_at").Find(&shifts).Error; err != nil {
+		return queryFailed(c, err)
+	}
+
+	ids := make([]int64, len(shifts))
+	for i, s := range shifts {
+		ids[i] = s.Id
+	}
+	var nets []struct {
+		ShiftId int64
+		Amount  int64
+	}
+	if err := database.DB.Model(&modelpayment.Payment{}).
+		Select("shift_id, SUM(amount) AS amount").
+		Where("shift_id IN ?", ids).Group("shift_id").Scan(&nets).Error; err != nil {
+		return queryFailed(c, err)
+	}
+	net := make(map[int64]int64, len(nets))
+	for _, n := range nets {
+		net[n.ShiftId] = n.Amount
+	}
+
+	var rows [][]string
+	var totalNet, totalVariance int64
+	for _, s := range shifts {
+		rows = append(rows, []string{
+			fmt.Sprint(s.Id), s.Operator, s.ParkNo, s.OpenedAt.String(), s.ClosedAt.String(),
+			money(s.OpeningFloat), money(s.ExpectedCash), money(s.DeclaredCash), money(s.Variance),
+			money(net[s.Id]), s.Status,
+		})
+		totalNet += net[s.Id]
+		totalVariance += s.Variance
+	}
+	rows = append(rows, []string{"Jemi", "", "", "", "", "", "", "", money(totalVariance), money(totalNet), ""})
+
+	pdf := pdfdoc.New("L")
+	pdf.AddPage()
+	pdfdoc.Title(pdf, "Operator smenalary", f.String())
+	pdfdoc.Table(pdf, []float64{12, 28, 20, 33, 33, 22, 22, 22, 22, 24, 22},
+		[]string{"№", "Operator", "Duralga", "Açyldy", "Ýapyldy", "Başlangyç", "Garaşylýan", "Sanalan", "Tapawut", "Jemi", "Ýagdaý"}, rows)
+	return send(c, pdf, "operator-shifts.pdf")
+}
+
+// VIPUsage godoc
+// @Summary VIP usage PDF
+// @Description Visits of VIP subscribers in the range by plate, with the number of visits, total stay and last visit.
+// @Tags Reports
+// @Produce application/pdf
+// @Param start query string false "First day, default today" example("2025-01-01")
+// @Param end query string false "Last day, default today" example("2025-01-31")
+// @Param parkno query string false "Park code"
+// @Success 200 {file} file
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid date format"
+// @Failure 500 {object} resmodel.ErrorResponse "Database error"
+// @Router /api/v1/reports/pdf/vip [get]
+func VIPUsage(c *fiber.Ctx) error {
+	f, err := parseFilter(c)
+	if err != nil {
+		return invalidFilter(c, err)
+	}
+
+	var usage []struct {
+		CarNumber string
+		Name      string
+		Visits    int
+		Minutes   int
+		LastVisit sitetime.Time
+	}
+	query := database.DB.Table("car_models AS c").
+		Select(`c.car_number, COALESCE(MAX(t.name), '') AS name, COUNT(*) AS visits,
+			COALESCE(SUM(EXTRACT(EPOCH FROM c.end_time - c.start_time)) / 60, 0)::bigint AS minutes,
+			MAX(c.start_time) AS last_visit`).
+		Joins("LEFT JOIN tarifs t ON t.id = c.subscription_id").
+		Where("c.subscription_id IS NOT NULL AND c.start_time >= ? AND c.start_time < ?", f.Start, f.End)
+	if f.ParkNo != "" {
+		query = query.Where("c.park_no = ?", f.ParkNo)
+	}
+	if err := query.Group("c.car_number").Order("visits DESC").Scan(&usage).Error; err != nil {
+		return queryFailed(c, err)
+	}
+
+	var rows [][]string
+	visits := 0
+	for _, u := range usage {
+		rows = append(rows, []string{
+			u.CarNumber, u.Name, fmt.Sprint(u.Visits),
+			fmt.Sprintf("%d sag %02d min", u.Minutes/60, u.Minutes%60), u.LastVisit.String(),
+		})
+		visits += u.Visits
+	}
+	rows = append(rows, []string{"Jemi", "", fmt.Sprint(visits), "", ""})
+
+	pdf := pdfdoc.New("P")
+	pdf.AddPage()
+	pdfdoc.Title(pdf, "VIP ulanyşy", f.String())
+	pdfdoc.Table(pdf, []float64{35, 50, 20, 35, 40},
+		[]string{"Belgi", "Eýesi", "Sapar", "Jemi wagt", "Soňky giriş"}, rows)
+	return send(c, pdf, "vip-usage.pdf")
+}
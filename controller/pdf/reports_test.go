@@ -0,0 +1,77 @@
+package pdfGenerator
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	modelpayment "park/models/paymentModel"
+)
+
+func TestSummarizeMergesDays(t *testing.T) {
+	rows := summarize(
+		[]dayRevenue{
+			{Day: "2025-01-02", Method: modelpayment.Cash, Amount: 300},
+			{Day: "2025-01-01", Method: modelpayment.Card, Amount: 200},
+			{Day: "2025-01-02", Method: modelpayment.QR, Amount: 100},
+		},
+		[]dayEntries{{Day: "2025-01-01", Count: 5}, {Day: "2025-01-03", Count: 1}},
+		[]dayExits{{Day: "2025-01-02", Count: 4, Free: 1}},
+	)
+
+	var days []string
+	for _, r := range rows {
+		days = append(days, r.Day)
+	}
+	if want := []string{"2025-01-01", "2025-01-02", "2025-01-03"}; !reflect.DeepEqual(days, want) {
+		t.Fatalf("days = %v, want %v", days, want)
+	}
+	if rows[1].Net != 400 || rows[1].Exits != 4 || rows[1].Free != 1 || rows[1].Entries != 0 {
+		t.Fatalf("unexpected second day: %+v", rows[1])
+	}
+}
+
+func TestDailyTableAddsTotals(t *testing.T) {
+	table := dailyTable(summarize(
+		[]dayRevenue{
+			{Day: "2025-01-01", Method: modelpayment.Cash, Amount: 250},
+			{Day: "2025-01-02", Method: modelpayment.Cash, Amount: 100},
+			{Day: "2025-01-02", Method: modelpayment.Subscription, Amount: 0},
+		},
+		[]dayEntries{{Day: "2025-01-01", Count: 3}, {Day: "2025-01-02", Count: 2}},
+		[]dayExits{{Day: "2025-01-01", Count: 2, Free: 1}, {Day: "2025-01-02", Count: 3}},
+	))
+
+	if len(table) != 3 {
+		t.Fatalf("got %d rows, want 2 days and a total", len(table))
+	}
+	want := []string{"Jemi", "5", "5", "1", "3.50", "0.00", "0.00", "0.00", "3.50"}
+	if total := table[2]; !reflect.DeepEqual(total, want) {
+		t.Fatalf("total = %v, want %v", total, want)
+	}
+}
+
+func TestParseFilterLimitsOperators(t *testing.T) {
+	var got reportFilter
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Locals("role", "operator")
+		c.Locals("username", "aman")
+		var err error
+		got, err = parseFilter(c)
+		return err
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/?start=2025-01-01&end=2025-01-31&operator=other", nil))
+	if err != nil || resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("request failed: %v, %v", resp, err)
+	}
+	if got.Operator != "aman" {
+		t.Fatalf("operator = %q, want the caller", got.Operator)
+	}
+	if got.Start.Format("2006-01-02") != "2025-01-01" || got.End.Format("2006-01-02") != "2025-02-01" {
+		t.Fatalf("unexpected range %v - %v", got.Start, got.End)
+	}
+}
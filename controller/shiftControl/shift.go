@@ -0,0 +1,256 @@
+package shiftcontrol
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	resmodel "park/controller/getdata/resModel"
+	"park/database"
+	modelreport "park/models/reportModel"
+	modelshift "park/models/shiftModel"
+	"park/service/audit"
+	"park/service/report"
+	"park/service/shift"
+	"park/util/export"
+	"park/util/sitetime"
+)
+
+type OpenInput struct {
+	OpeningFloat int64 `json:"opening_float" example:"5000"`
+}
+
+type CloseInput struct {
+	DeclaredCash  int64                     `json:"declared_cash" example:"125000"`
+	Denominations []modelshift.Denomination `json:"denominations"`
+	Note          string                    `json:"note"`
+}
+
+type CloseResponse struct {
+	Shift   modelshift.Shift    `json:"shift"`
+	ZReport modelreport.ZReport `json:"zreport"`
+}
+
+type CurrentResponse struct {
+	Shift        modelshift.Shift `json:"shift"`
+	ExpectedCash int64            `json:"expected_cash"`
+}
+
+func shiftError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(resmodel.ErrorResponse{Error: "Shift not found"})
+	case errors.Is(err, shift.ErrInvalidAmount):
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, shift.ErrShiftOpen), errors.Is(err, shift.ErrNotOpen), errors.Is(err, shift.ErrNotClosed), errors.Is(err, shift.ErrLocked):
+		return c.Status(fiber.StatusConflict).JSON(resmodel.ErrorResponse{Error: err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+		Error:   "Failed to save data to the database",
+		Details: err.Error(),
+	})
+}
+
+// OpenShift godoc
+// @Summary Open a shift
+// @Description Opens a shift for the logged in operator with the cash in the till. Amounts are in minor units.
+// @Tags Shifts
+// @Accept json
+// @Produce json
+// @Param shift body OpenInput true "Opening float"
+// @Success 201 {object} modelshift.Shift
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid amount"
+// @Failure 409 {object} resmodel.ErrorResponse "Operator already has an open shift"
+// @Router /api/v1/shifts/open [post]
+func OpenShift(c *fiber.Ctx) error {
+	var input OpenInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to parse request body",
+			Details: err.Error(),
+		})
+	}
+
+	operator, _ := c.Locals("username").(string)
+	parkNo, _ := c.Locals("parkno").(string)
+	s, err := shift.Open(database.DB, operator, parkNo, input.OpeningFloat, sitetime.Now())
+	if err != nil {
+		return shiftError(c, err)
+	}
+	audit.After(c, "shift", strconv.FormatInt(s.Id, 10), s)
+	return c.Status(fiber.StatusCreated).JSON(s)
+}
+
+// GetCurrentShift godoc
+// @Summary Get the open shift
+// @Description Returns the open shift of the logged in operator with the cash that is expected in the till so far.
+// @Tags Shifts
+// @Produce json
+// @Success 200 {object} CurrentResponse
+// @Failure 404 {object} resmodel.ErrorResponse "No open shift"
+// @Router /api/v1/shifts/current [get]
+func GetCurrentShift(c *fiber.Ctx) error {
+	operator, _ := c.Locals("username").(string)
+	s, err := shift.Current(database.DB, operator)
+	if err != nil {
+		return shiftError(c, err)
+	}
+	expected, err := shift.ExpectedCash(database.DB, s)
+	if err != nil {
+		return shiftError(c, err)
+	}
+	return c.JSON(CurrentResponse{Shift: s, ExpectedCash: expected})
+}
+
+// CloseShift godoc
+// @Summary Close the open shift
+// @Description Closes the open shift of the logged in operator with the counted cash and issues its Z-report. When denominations are given the declared cash is their sum. The expected cash is the opening float plus the net cash payments of the shift, and the variance is declared minus expected.
+// @Tags Shifts
+// @Accept json
+// @Produce json
+// @Param shift body CloseInput true "Counted cash"
+// @Success 200 {object} CloseResponse
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid amount"
+// @Failure 404 {object} resmodel.ErrorResponse "No open shift"
+// @Router /api/v1/shifts/close [post]
+func CloseShift(c *fiber.Ctx) error {
+	var input CloseInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to parse request body",
+			Details: err.Error(),
+		})
+	}
+
+	operator, _ := c.Locals("username").(string)
+	s, err := shift.Current(database.DB, operator)
+	if err != nil {
+		return shiftError(c, err)
+	}
+	audit.Before(c, "shift", strconv.FormatInt(s.Id, 10), s)
+	z, s, err := report.Z(database.DB, s, input.DeclaredCash, input.Denominations, input.Note, sitetime.Now())
+	if err != nil {
+		return shiftError(c, err)
+	}
+	response := CloseResponse{Shift: s, ZReport: z}
+	audit.After(c, "shift", strconv.FormatInt(s.Id, 10), response)
+	return c.JSON(response)
+}
+
+// ApproveShift godoc
+// @Summary Approve a closed shift
+// @Description Approves a closed shift, after which it can not be changed.
+// @Tags Shifts
+// @Produce json
+// @Param id path int true "Shift ID"
+// @Success 200 {object} modelshift.Shift
+// @Failure 403 {object} map[string]string "Role may not approve shifts"
+// @Failure 404 {object} resmodel.ErrorResponse "Shift not found"
+// @Failure 409 {object} resmodel.ErrorResponse "Shift is open or already approved"
+// @Router /api/v1/shifts/{id}/approve [post]
+func ApproveShift(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{Error: "Invalid shift ID"})
+	}
+
+	var before modelshift.Shift
+	if err := database.DB.First(&before, id).Error; err == nil {
+		audit.Before(c, "shift", strconv.Itoa(id), before)
+	}
+
+	username, _ := c.Locals("username").(string)
+	s, err := shift.Approve(database.DB, int64(id), username, sitetime.Now())
+	if err != nil {
+		return shiftError(c, err)
+	}
+	audit.After(c, "shift", strconv.Itoa(id), s)
+	return c.JSON(s)
+}
+
+// GetShifts godoc
+// @Summary List shifts
+// @Description Lists shifts with their denominations, newest first.
+// @Tags Shifts
+// @Produce json
+// @Param operator query string false "Operator username"
+// @Param parkno query string false "Park code"
+// @Param status query string false "open, closed or approved"
+// @Param start query string false "Opened from" example("2025-01-29 00:00:00")
+// @Param end query string false "Opened until" example("2025-01-29 23:59:59")
+// @Success 200 {array} modelshift.Shift
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid time format"
+// @Router /api/v1/shifts [get]
+func GetShifts(c *fiber.Ctx) error {
+	query, err := shiftQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{Error: err.Error()})
+	}
+
+	var shifts []modelshift.Shift
+	if err := query.Preload("Denominations").Order("id DESC").Find(&shifts).Error; err != nil {
+		return shiftError(c, err)
+	}
+	return c.JSON(shifts)
+}
+
+// shiftQuery applies the filters of GetShifts.
+func shiftQuery(c *fiber.Ctx) (*gorm.DB, error) {
+	query := database.DB.Model(&modelshift.Shift{})
+	if operator := c.Query("operator"); operator != "" {
+		query = query.Where("operator = ?", operator)
+	}
+	if parkNo := c.Query("parkno"); parkNo != "" {
+		query = query.Where("park_no = ?", parkNo)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if start := c.Query("start"); start != "" {
+		startTime, err := sitetime.Parse(start)
+		if err != nil {
+			return nil, errors.New("Invalid start time format")
+		}
+		query = query.Where("opened_at >= ?", startTime)
+	}
+	if end := c.Query("end"); end != "" {
+		endTime, err := sitetime.Parse(end)
+		if err != nil {
+			return nil, errors.New("Invalid end time format")
+		}
+		query = query.Where("opened_at <= ?", endTime)
+	}
+	return query, nil
+}
+
+// ExportShifts godoc
+// @Summary Export shifts
+// @Description Streams the shifts matched by the filters of GET /api/v1/shifts as CSV or XLSX, newest first. Amounts are in minor units.
+// @Tags Shifts
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv or xlsx" default(csv)
+// @Param operator query string false "Operator username"
+// @Param parkno query string false "Park code"
+// @Param status query string false "open, closed or approved"
+// @Param start query string false "Opened from" example("2025-01-29 00:00:00")
+// @Param end query string false "Opened until" example("2025-01-29 23:59:59")
+// @Success 200 {file} file
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid time format"
+// @Router /api/v1/shifts/export [get]
+func ExportShifts(c *fiber.Ctx) error {
+	query, err := shiftQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{Error: err.Error()})
+	}
+
+	header := []string{"ID", "Operator", "Park", "Status", "Opened", "Closed", "Opening float", "Expected cash", "Declared cash", "Variance", "Note", "Approved by", "Approved"}
+	return export.Stream(c, query.Order("id DESC"), "shifts", header, func(s modelshift.Shift) []interface{} {
+		return []interface{}{
+			s.Id, s.Operator, s.ParkNo, s.Status, s.OpenedAt, s.ClosedAt, s.OpeningFloat, s.ExpectedCash,
+			s.DeclaredCash, s.Variance, s.Note, s.ApprovedBy, s.ApprovedAt,
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package tarifcontrol
+
+import (
+	"park/database"
+	"park/models/tarif"
+	"park/util/export"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ExportTarifs godoc
+// @Summary Export tarifs
+// @Description Streams all tarifs as CSV or XLSX.
+// @Tags Tarif
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv or xlsx" default(csv)
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string "Unknown format"
+// @Router /api/v1/accountant/tarif/export [get]
+func ExportTarifs(c *fiber.Ctx) error {
+	query := database.DB.Model(&tarif.Tarif{}).Order("id")
+	header := []string{"ID", "Plate", "Name", "Start", "End", "Price"}
+	return export.Stream(c, query, "tarifs", header, func(t tarif.Tarif) []interface{} {
+		return []interface{}{t.Id, t.Plate, t.Name, t.Start_time, t.End_time, t.Price}
+	})
+}
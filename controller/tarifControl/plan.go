@@ -0,0 +1,346 @@
+package tarifcontrol
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	resmodel "park/controller/getdata/resModel"
+	"park/database"
+	modelscar "park/models/modelsCar"
+	"park/models/tarif"
+	"park/service/audit"
+	"park/util"
+	"park/util/sitetime"
+)
+
+type PlanInput struct {
+	Name             string       `json:"name" example:"Standard"`
+	Active           *bool        `json:"active" example:"true"`
+	ValidFrom        string       `json:"valid_from" example:"2025-01-01 00:00:00"`
+	FirstHourPrice   float64      `json:"first_hour_price" example:"1"`
+	IncrementMinutes int          `json:"increment_minutes" example:"60"`
+	IncrementPrice   float64      `json:"increment_price" example:"0.5"`
+	DailyCap         float64      `json:"daily_cap" example:"3"`
+	PerStartedDay    bool         `json:"per_started_day" example:"true"`
+	LostTicketFee    *float64     `json:"lost_ticket_fee" example:"3"`
+	Bands            []tarif.Band `json:"bands"`
+}
+
+type QuoteResponse struct {
+	PlanId   int     `json:"plan_id"`
+	PlanName string  `json:"plan_name"`
+	Start    string  `json:"start"`
+	End      string  `json:"end"`
+	Duration int     `json:"duration"`
+	Total    float64 `json:"total_payment"`
+}
+
+func (in PlanInput) toPlan() (tarif.Plan, error) {
+	plan := tarif.Plan{
+		Name:             in.Name,
+		Active:           true,
+		FirstHourPrice:   in.FirstHourPrice,
+		IncrementMinutes: in.IncrementMinutes,
+		IncrementPrice:   in.IncrementPrice,
+		DailyCap:         in.DailyCap,
+		PerStartedDay:    in.PerStartedDay,
+		LostTicketFee:    util.LegacyPlan().LostTicketFee,
+		ValidFrom:        time.Now(),
+	}
+	if in.LostTicketFee != nil {
+		plan.LostTicketFee = *in.LostTicketFee
+	}
+	if in.Active != nil {
+		plan.Active = *in.Active
+	}
+	if in.ValidFrom != "" {
+		validFrom, err := sitetime.Parse(in.ValidFrom)
+		if err != nil {
+			return plan, err
+		}
+		plan.ValidFrom = validFrom
+	}
+	for _, band := range in.Bands {
+		plan.Bands = append(plan.Bands, tarif.Band{UpToMinutes: band.UpToMinutes, Price: band.Price})
+	}
+	return plan, nil
+}
+
+// CreatePlan godoc
+// @Summary Create a tariff plan
+// @Description Creates a pricing plan with time bands, a daily cap, a first hour rate, an increment and the fee charged for a lost ticket. Without lost_ticket_fee the fee of the legacy tariff is charged; 0 lets lost tickets out for free.
+// @Tags Tarif
+// @Accept json
+// @Produce json
+// @Param plan body PlanInput true "Tariff plan"
+// @Success 201 {object} tarif.Plan "Successfully created"
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid request data"
+// @Failure 500 {object} resmodel.ErrorResponse "Failed to save data to the database"
+// @Router /api/v1/accountant/tariff-plans [post]
+func CreatePlan(c *fiber.Ctx) error {
+	var input PlanInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to parse request body",
+			Details: err.Error(),
+		})
+	}
+
+	plan, err := input.toPlan()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Invalid valid_from format",
+			Details: err.Error(),
+		})
+	}
+
+	if err := database.DB.Create(&plan).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to save data to the database",
+			Details: err.Error(),
+		})
+	}
+	util.LoadTariffPlans()
+	audit.After(c, "tariff_plan", strconv.Itoa(plan.Id), plan)
+	return c.Status(201).JSON(plan)
+}
+
+// GetPlans godoc
+// @Summary Get all tariff plans
+// @Description Retrieves all tariff plans with their bands, newest first.
+// @Tags Tarif
+// @Produce json
+// @Success 200 {array} tarif.Plan "List of plans"
+// @Failure 500 {object} resmodel.ErrorResponse "Database error"
+// @Router /api/v1/accountant/tariff-plans [get]
+func GetPlans(c *fiber.Ctx) error {
+	var plans []tarif.Plan
+	if err := database.DB.Preload("Bands").Order("valid_from desc").Find(&plans).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to retrieve tariff plans",
+			Details: err.Error(),
+		})
+	}
+	return c.Status(200).JSON(plans)
+}
+
+// GetPlan godoc
+// @Summary Get a tariff plan
+// @Description Retrieves a tariff plan with its bands by ID.
+// @Tags Tarif
+// @Produce json
+// @Param id path int true "Plan ID"
+// @Success 200 {object} tarif.Plan
+// @Failure 404 {object} resmodel.ErrorResponse "Tariff plan not found"
+// @Router /api/v1/accountant/tariff-plans/{id} [get]
+func GetPlan(c *fiber.Ctx) error {
+	var plan tarif.Plan
+	if err := database.DB.Preload("Bands").First(&plan, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(resmodel.ErrorResponse{
+			Error:   "Tariff plan not found",
+			Details: err.Error(),
+		})
+	}
+	return c.Status(200).JSON(plan)
+}
+
+// UpdatePlan godoc
+// @Summary Update a tariff plan
+// @Description Replaces the prices and bands of a tariff plan. A plan keeps its valid_from, active state and lost ticket fee unless they are sent. Deactivating a plan ends it now: visits that started before are still priced with it, and it can not be activated again.
+// @Tags Tarif
+// @Accept json
+// @Produce json
+// @Param id path int true "Plan ID"
+// @Param plan body PlanInput true "Tariff plan"
+// @Success 200 {object} tarif.Plan
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid request data"
+// @Failure 404 {object} resmodel.ErrorResponse "Tariff plan not found"
+// @Failure 409 {object} resmodel.ErrorResponse "Tariff plan has ended"
+// @Failure 500 {object} resmodel.ErrorResponse "Database error"
+// @Router /api/v1/accountant/tariff-plans/{id} [put]
+func UpdatePlan(c *fiber.Ctx) error {
+	var existing tarif.Plan
+	if err := database.DB.Preload("Bands").First(&existing, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(resmodel.ErrorResponse{
+			Error:   "Tariff plan not found",
+			Details: err.Error(),
+		})
+	}
+	audit.Before(c, "tariff_plan", strconv.Itoa(existing.Id), existing)
+
+	var input PlanInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to parse request body",
+			Details: err.Error(),
+		})
+	}
+
+	plan, err := input.toPlan()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Invalid valid_from format",
+			Details: err.Error(),
+		})
+	}
+	plan.Id = existing.Id
+	plan.CreatedAt = existing.CreatedAt
+	if input.ValidFrom == "" {
+		plan.ValidFrom = existing.ValidFrom
+	}
+	if input.Active == nil {
+		plan.Active = existing.Active
+	}
+	if input.LostTicketFee == nil {
+		plan.LostTicketFee = existing.LostTicketFee
+	}
+	plan.ValidUntil = existing.ValidUntil
+	if plan.Active && existing.ValidUntil != nil {
+		return c.Status(fiber.StatusConflict).JSON(resmodel.ErrorResponse{
+			Error: "An ended tariff plan can not be reactivated, create a new plan",
+		})
+	}
+	if existing.Active && !plan.Active {
+		now := time.Now()
+		plan.ValidUntil = &now
+	}
+
+	tx := database.DB.Begin()
+	if err := tx.Where("plan_id = ?", plan.Id).Delete(&tarif.Band{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to update tariff plan",
+			Details: err.Error(),
+		})
+	}
+	if err := tx.Save(&plan).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to update tariff plan",
+			Details: err.Error(),
+		})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to update tariff plan",
+			Details: err.Error(),
+		})
+	}
+	util.LoadTariffPlans()
+	audit.After(c, "tariff_plan", strconv.Itoa(plan.Id), plan)
+	return c.Status(200).JSON(plan)
+}
+
+// DeletePlan godoc
+// @Summary Delete a tariff plan
+// @Description Ends a tariff plan now. It is kept, with valid_until set, so that visits that started before can still be priced with it. A plan that was never active is removed with its bands.
+// @Tags Tarif
+// @Param id path int true "Plan ID"
+// @Success 200 {string} string "Tariff plan successfully deleted"
+// @Failure 404 {object} resmodel.ErrorResponse "Tariff plan not found"
+// @Failure 500 {object} resmodel.ErrorResponse "Database error"
+// @Router /api/v1/accountant/tariff-plans/{id} [delete]
+func DeletePlan(c *fiber.Ctx) error {
+	var plan tarif.Plan
+	if err := database.DB.Preload("Bands").First(&plan, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(resmodel.ErrorResponse{
+			Error:   "Tariff plan not found",
+			Details: err.Error(),
+		})
+	}
+
+	audit.Before(c, "tariff_plan", strconv.Itoa(plan.Id), plan)
+	var err error
+	switch {
+	case !plan.Active && plan.ValidUntil == nil:
+		err = database.DB.Select("Bands").Delete(&plan).Error
+	case plan.ValidUntil == nil:
+		now := time.Now()
+		plan.Active = false
+		plan.ValidUntil = &now
+		err = database.DB.Model(&plan).Select("Active", "ValidUntil").Updates(&plan).Error
+		audit.After(c, "tariff_plan", strconv.Itoa(plan.Id), plan)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to delete tariff plan",
+			Details: err.Error(),
+		})
+	}
+	util.LoadTariffPlans()
+
+	return c.Status(200).JSON(fiber.Map{"message": "Tariff plan successfully deleted"})
+}
+
+// QuotePlan godoc
+// @Summary Quote a parking fee
+// @Description Prices any interval with the plan in effect at its start, or with plan_id. With car_id the stored visit is recalculated.
+// @Tags Tarif
+// @Produce json
+// @Param start query string false "Start time" example("2025-01-29 10:13:51")
+// @Param end query string false "End time" example("2025-01-29 12:15:10")
+// @Param plan_id query int false "Plan to price with"
+// @Param car_id query int false "Visit to recalculate"
+// @Success 200 {object} QuoteResponse
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid request data"
+// @Failure 404 {object} resmodel.ErrorResponse "Tariff plan or car not found"
+// @Router /api/v1/accountant/tariff-plans/quote [get]
+func QuotePlan(c *fiber.Ctx) error {
+	startStr := c.Query("start")
+	endStr := c.Query("end")
+
+	if carID := c.Query("car_id"); carID != "" {
+		var car modelscar.Car_Model
+		if err := database.DB.First(&car, carID).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(resmodel.ErrorResponse{
+				Error:   "Car not found",
+				Details: err.Error(),
+			})
+		}
+		startStr = car.Start_time.String()
+		if endStr == "" {
+			endStr = car.End_time.String()
+		}
+		if endStr == "" {
+			endStr = sitetime.Now().Format(TimeFormat)
+		}
+	}
+
+	start, err := sitetime.Parse(startStr)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Invalid start time format",
+			Details: err.Error(),
+		})
+	}
+	end, err := sitetime.Parse(endStr)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Invalid end time format",
+			Details: err.Error(),
+		})
+	}
+
+	var plan tarif.Plan
+	if planID, _ := strconv.Atoi(c.Query("plan_id")); planID != 0 {
+		if err := database.DB.Preload("Bands").First(&plan, planID).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(resmodel.ErrorResponse{
+				Error:   "Tariff plan not found",
+				Details: err.Error(),
+			})
+		}
+	} else {
+		plan = util.PlanAt(start)
+	}
+
+	return c.Status(200).JSON(QuoteResponse{
+		PlanId:   plan.Id,
+		PlanName: plan.Name,
+		Start:    start.Format(TimeFormat),
+		End:      end.Format(TimeFormat),
+		Duration: int(end.Sub(start).Minutes()),
+		Total:    util.QuotePlan(plan, start, end),
+	})
+}
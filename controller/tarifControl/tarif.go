@@ -6,7 +6,9 @@ import (
 	resmodel "park/controller/getdata/resModel"
 	"park/database"
 	"park/models/tarif"
+	"park/service/audit"
 	"park/util"
+	"park/util/sitetime"
 	"strconv"
 	"time"
 
@@ -49,13 +51,13 @@ func (t *Tarif) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	startTime, err := time.Parse(TimeFormat, aux.Start_time)
+	startTime, err := sitetime.Parse(aux.Start_time)
 	if err != nil {
 		return err
 	}
 	t.Start_time = startTime
 
-	endTime, err := time.Parse(TimeFormat, aux.End_time)
+	endTime, err := sitetime.Parse(aux.End_time)
 	if err != nil {
 		return err
 	}
@@ -91,6 +93,7 @@ func CreateTarif(c *fiber.Ctx) error {
 		})
 	}
 	util.LoadVIPPlates()
+	audit.After(c, "tarif", strconv.Itoa(tarif.Id), tarif)
 	return c.Status(201).JSON(tarif)
 }
 
@@ -121,6 +124,7 @@ func DeleteTarif(c *fiber.Ctx) error {
 		})
 	}
 
+	audit.Before(c, "tarif", strconv.Itoa(tarif.Id), tarif)
 	if err := database.DB.Delete(&tarif).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
 			Error:   "Failed to delete tarif",
@@ -0,0 +1,77 @@
+package zreport
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	modelreport "park/models/reportModel"
+	"park/service/ledger"
+	"park/util/pdfdoc"
+)
+
+func money(amount int64) string {
+	return fmt.Sprintf("%.2f TMT", ledger.FromMinor(amount))
+}
+
+// sendPDF renders a report in memory and sends it as an attachment.
+func sendPDF(c *fiber.Ctx, r modelreport.ZReport, filename string) error {
+	pdf := pdfdoc.New("P")
+	pdf.AddPage()
+
+	title := "X-Hasabat"
+	if r.Number != 0 {
+		title = fmt.Sprintf("Z-Hasabat № %d", r.Number)
+	}
+	pdfdoc.Title(pdf, title, fmt.Sprintf("%s — %s", r.OpenedAt.String(), r.ClosedAt.String()))
+
+	rows := [][]string{
+		{"Operator", r.Operator},
+		{"Awtoduralga", r.ParkNo},
+		{"Smena", fmt.Sprint(r.ShiftId)},
+	}
+	pdfdoc.Table(pdf, []float64{70, 110}, nil, rows)
+	pdf.Ln(5)
+
+	rows = nil
+	for _, t := range r.Totals {
+		rows = append(rows, []string{string(t.Method), fmt.Sprint(t.Payments), money(t.Amount)})
+	}
+	rows = append(rows,
+		[]string{"Gaýtarylan", "", money(r.Refunds)},
+		[]string{"Jemi", "", money(r.Net)},
+	)
+	pdfdoc.Table(pdf, []float64{70, 40, 70}, []string{"Töleg görnüşi", "Sany", "Jemi"}, rows)
+	pdf.Ln(5)
+
+	rows = nil
+	for _, e := range r.Exemptions {
+		rows = append(rows, []string{e.Reason, fmt.Sprint(e.Count)})
+	}
+	rows = append(rows,
+		[]string{"Mugt çykyşlar jemi", fmt.Sprint(r.ExemptionTotal)},
+		[]string{"VIP çykyşlar", fmt.Sprint(r.VipExits)},
+	)
+	pdfdoc.Table(pdf, []float64{110, 70}, []string{"Sebäp", "Sany"}, rows)
+	pdf.Ln(5)
+
+	rows = [][]string{
+		{"Başlangyç nagt", money(r.OpeningFloat)},
+		{"Garaşylýan nagt", money(r.ExpectedCash)},
+	}
+	if r.Number != 0 {
+		rows = append(rows,
+			[]string{"Sanalan nagt", money(r.DeclaredCash)},
+			[]string{"Tapawut", money(r.Variance)},
+		)
+	}
+	pdfdoc.Table(pdf, []float64{110, 70}, []string{"Nagt", ""}, rows)
+
+	data, err := pdfdoc.Bytes(pdf)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error generating PDF")
+	}
+	c.Set("Content-Type", "application/pdf")
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	return c.Send(data)
+}
@@ -1,35 +1,198 @@
 package zreport
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	resmodel "park/controller/getdata/resModel"
+	shiftcontrol "park/controller/shiftControl"
+	"park/database"
+	modelsuser "park/models/modelsUser"
+	modelreport "park/models/reportModel"
+	"park/service/audit"
+	"park/service/report"
+	"park/service/shift"
+	"park/util/sitetime"
 )
 
-type ZReport struct {
-	Total_payment int    `json:"total_payment"`
-	Username      string `json:"username"`
-	PrakNo        string `json:"parkno"`
+func reportError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(resmodel.ErrorResponse{Error: "Report not found"})
+	case errors.Is(err, shift.ErrInvalidAmount):
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, shift.ErrNotOpen):
+		return c.Status(fiber.StatusConflict).JSON(resmodel.ErrorResponse{Error: err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(resmodel.ErrorResponse{
+		Error:   "Failed to build the report",
+		Details: err.Error(),
+	})
+}
+
+// currentX builds the X-report of the open shift of the logged in operator.
+func currentX(c *fiber.Ctx) (modelreport.ZReport, error) {
+	operator, _ := c.Locals("username").(string)
+	s, err := shift.Current(database.DB, operator)
+	if err != nil {
+		return modelreport.ZReport{}, err
+	}
+	return report.Build(database.DB, s, sitetime.Now())
+}
+
+// GetXReport godoc
+// @Summary X-report of the open shift
+// @Description Returns the running totals of the open shift of the logged in operator. X-reports are not stored and do not close the shift. Amounts are in minor units.
+// @Tags Zreport
+// @Produce json
+// @Success 200 {object} modelreport.ZReport
+// @Failure 404 {object} resmodel.ErrorResponse "No open shift"
+// @Router /api/v1/reports/x [get]
+func GetXReport(c *fiber.Ctx) error {
+	r, err := currentX(c)
+	if err != nil {
+		return reportError(c, err)
+	}
+	return c.JSON(r)
+}
+
+// GetXReportPDF godoc
+// @Summary X-report of the open shift as PDF
+// @Tags Zreport
+// @Produce application/pdf
+// @Success 200 {file} file
+// @Failure 404 {object} resmodel.ErrorResponse "No open shift"
+// @Router /api/v1/reports/x/pdf [get]
+func GetXReportPDF(c *fiber.Ctx) error {
+	r, err := currentX(c)
+	if err != nil {
+		return reportError(c, err)
+	}
+	return sendPDF(c, r, "x-report.pdf")
 }
 
-// CreateTarif godoc
-// @Summary Create a New Tarif
-// @Description Creates a new Report and saves it to the database.
+// CreateZReport godoc
+// @Summary Close the shift with a Z-report
+// @Description Closes the open shift of the logged in operator with the counted cash and stores its Z-report under the next number.
 // @Tags Zreport
 // @Accept json
 // @Produce json
-// @Param tarif body ZReport true "Tarif details to be created"
-// @Success 201 {object} ZReport "Successfully created"
-// @Failure 400 {object} resmodel.ErrorResponse "Invalid request data"
-// @Failure 500 {object} resmodel.ErrorResponse "Failed to save data to the database"
-// @Router /zreport [post]
-func GetZdata(c *fiber.Ctx) error {
-	var data ZReport
-	if err := c.BodyParser(&data); err != nil {
-		return c.Status(400).JSON("Prese err")
-	}
-	fmt.Println("payment:", data.Total_payment)
-	fmt.Println("Username:", data.Username)
-	fmt.Println("Parkno:", data.PrakNo)
-	return c.Status(200).JSON(data)
+// @Param shift body shiftcontrol.CloseInput true "Counted cash"
+// @Success 201 {object} modelreport.ZReport
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid amount"
+// @Failure 404 {object} resmodel.ErrorResponse "No open shift"
+// @Router /api/v1/reports/z [post]
+func CreateZReport(c *fiber.Ctx) error {
+	var input shiftcontrol.CloseInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+			Error:   "Failed to parse request body",
+			Details: err.Error(),
+		})
+	}
+
+	operator, _ := c.Locals("username").(string)
+	s, err := shift.Current(database.DB, operator)
+	if err != nil {
+		return reportError(c, err)
+	}
+	r, _, err := report.Z(database.DB, s, input.DeclaredCash, input.Denominations, input.Note, sitetime.Now())
+	if err != nil {
+		return reportError(c, err)
+	}
+	audit.After(c, "zreport", strconv.FormatInt(r.Id, 10), r)
+	return c.Status(fiber.StatusCreated).JSON(r)
+}
+
+// GetZReports godoc
+// @Summary Search Z-reports
+// @Description Lists stored Z-reports, newest first.
+// @Tags Zreport
+// @Produce json
+// @Param number query int false "Report number"
+// @Param operator query string false "Operator username"
+// @Param parkno query string false "Park code"
+// @Param start query string false "Closed from" example("2025-01-29 00:00:00")
+// @Param end query string false "Closed until" example("2025-01-29 23:59:59")
+// @Success 200 {array} modelreport.ZReport
+// @Failure 400 {object} resmodel.ErrorResponse "Invalid time format"
+// @Failure 403 {object} map[string]string "Role may not search reports"
+// @Router /api/v1/reports/z [get]
+func GetZReports(c *fiber.Ctx) error {
+	query := database.DB.Preload("Totals").Preload("Exemptions")
+	if number := c.QueryInt("number"); number != 0 {
+		query = query.Where("number = ?", number)
+	}
+	if operator := c.Query("operator"); operator != "" {
+		query = query.Where("operator = ?", operator)
+	}
+	if parkNo := c.Query("parkno"); parkNo != "" {
+		query = query.Where("park_no = ?", parkNo)
+	}
+	for param, condition := range map[string]string{"start": "closed_at >= ?", "end": "closed_at <= ?"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		at, err := sitetime.Parse(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(resmodel.ErrorResponse{
+				Error: "Invalid " + param + " format. Use YYYY-MM-DD HH:MM:SS.",
+			})
+		}
+		query = query.Where(condition, at)
+	}
+
+	var reports []modelreport.ZReport
+	if err := query.Order("number DESC").Find(&reports).Error; err != nil {
+		return reportError(c, err)
+	}
+	return c.JSON(reports)
+}
+
+// zReport loads a Z-report by number. Operators can only load their own.
+func zReport(c *fiber.Ctx) (modelreport.ZReport, error) {
+	var r modelreport.ZReport
+	query := database.DB.Preload("Totals").Preload("Exemptions").Where("number = ?", c.Params("number"))
+	if role, _ := c.Locals("role").(string); role == string(modelsuser.OperatorRole) {
+		query = query.Where("operator = ?", c.Locals("username"))
+	}
+	err := query.First(&r).Error
+	return r, err
+}
+
+// GetZReport godoc
+// @Summary Get a Z-report
+// @Tags Zreport
+// @Produce json
+// @Param number path int true "Report number"
+// @Success 200 {object} modelreport.ZReport
+// @Failure 404 {object} resmodel.ErrorResponse "Report not found"
+// @Router /api/v1/reports/z/{number} [get]
+func GetZReport(c *fiber.Ctx) error {
+	r, err := zReport(c)
+	if err != nil {
+		return reportError(c, err)
+	}
+	return c.JSON(r)
+}
+
+// GetZReportPDF godoc
+// @Summary Get a Z-report as PDF
+// @Tags Zreport
+// @Produce application/pdf
+// @Param number path int true "Report number"
+// @Success 200 {file} file
+// @Failure 404 {object} resmodel.ErrorResponse "Report not found"
+// @Router /api/v1/reports/z/{number}/pdf [get]
+func GetZReportPDF(c *fiber.Ctx) error {
+	r, err := zReport(c)
+	if err != nil {
+		return reportError(c, err)
+	}
+	return sendPDF(c, r, fmt.Sprintf("z-report-%d.pdf", r.Number))
 }
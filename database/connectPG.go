@@ -3,11 +3,20 @@ package database
 import (
 	"log"
 	"os"
+	modelaudit "park/models/auditModel"
 	"park/models/camera"
+	modelexemption "park/models/exemptionModel"
+	modelgate "park/models/gateModel"
 	modelscar "park/models/modelsCar"
 	modelsuser "park/models/modelsUser"
 	modeloperator "park/models/operatorModel"
+	modelpark "park/models/parkModel"
+	modelpayment "park/models/paymentModel"
+	modelreport "park/models/reportModel"
+	modelsecret "park/models/secretModel"
+	modelshift "park/models/shiftModel"
 	"park/models/tarif"
+	"park/service/secrets"
 
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
@@ -21,6 +30,9 @@ func ConnectDB() {
 	if err != nil {
 		log.Fatal("Failed to load environment variables")
 	}
+	if err := secrets.CheckKey(); err != nil {
+		log.Fatal(err, " - generate one with: openssl rand -base64 32")
+	}
 
 	dsn := os.Getenv("DATABASE_URL")
 	database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
@@ -28,17 +40,40 @@ func ConnectDB() {
 		log.Fatal("Failed to connect to PostgreSQL:", err)
 	}
 
-	if err != nil {
-		log.Fatal("Failed to migrate models:", err)
+	if err := runMigrations(database); err != nil {
+		log.Fatal("Failed to run data migrations:", err)
 	}
 	err = database.AutoMigrate(
 		&modelscar.Car_Model{},
+		&modelscar.OrphanExit{},
 		&modelsuser.User{},
 		&camera.Cameras{},
 		&modeloperator.Operator{},
 		&tarif.Tarif{},
+		&modelpayment.Payment{},
+		&modelshift.Shift{},
+		&modelshift.Denomination{},
+		&modelreport.ZReport{},
+		&modelreport.MethodTotal{},
+		&modelreport.ExemptionCount{},
+		&tarif.Plan{},
+		&tarif.Band{},
+		&modelpark.Park{},
 		&camera.CamFix{},
-		&modelsuser.MacUser{},
+		&modelgate.Gate{},
+		&modelgate.Event{},
+		&camera.EventLog{},
+		&camera.JournalEntry{},
+		&modelsecret.Secret{},
+		&modelsuser.Permission{},
+		&modelsuser.Session{},
+		&modelsuser.SecurityEvent{},
+		&modelsuser.TwoFactor{},
+		&modelsuser.RecoveryCode{},
+		&modelaudit.Entry{},
+		&modelexemption.Reason{},
+		&modelexemption.Exemption{},
+		&modelexemption.Pin{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate models:", err)
@@ -0,0 +1,245 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"park/models/camera"
+	modelscar "park/models/modelsCar"
+	modeloperator "park/models/operatorModel"
+	modelpark "park/models/parkModel"
+	modelpayment "park/models/paymentModel"
+	modelsecret "park/models/secretModel"
+	modelshift "park/models/shiftModel"
+	"park/service/secrets"
+	"park/util/plate"
+	"park/util/sitetime"
+)
+
+// SchemaMigration records a data migration that has been applied.
+type SchemaMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+type migration struct {
+	version int
+	name    string
+	up      func(tx *gorm.DB) error
+}
+
+// migrations run in order, once, before the models are auto-migrated.
+// Append new migrations at the end and never renumber old ones.
+var migrations = []migration{
+	{1, "store car and operator times as timestamptz", timesToTimestamptz},
+	{2, "create parks from camera channel names", parksFromChannelNames},
+	{3, "move paid visits into the payments ledger", paymentsFromCars},
+	{4, "turn operator sessions into shifts", shiftsFromOperators},
+	{5, "move the Macroscop login into the secret store", macroscopLoginToSecrets},
+	{6, "normalize the plates of stored visits", normalizeCarNumbers},
+}
+
+func runMigrations(db *gorm.DB) error {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		var applied int64
+		if err := db.Model(&SchemaMigration{}).Where("version = ?", m.version).Count(&applied).Error; err != nil {
+			return err
+		}
+		if applied > 0 {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.version, Name: m.name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		log.Printf("Applied migration %d: %s", m.version, m.name)
+	}
+	return nil
+}
+
+// timesToTimestamptz converts the "2006-01-02 15:04:05" text columns, which
+// hold site local time, into timestamptz. Empty strings become NULL.
+func timesToTimestamptz(tx *gorm.DB) error {
+	zone := strings.ReplaceAll(sitetime.Location().String(), "'", "''")
+	columns := map[string][]string{
+		"car_models": {"start_time", "end_time"},
+		"operators":  {"login_at", "logout_at"},
+	}
+
+	for table, names := range columns {
+		if !tx.Migrator().HasTable(table) {
+			continue
+		}
+		for _, column := range names {
+			var dataType string
+			if err := tx.Raw("SELECT data_type FROM information_schema.columns WHERE table_name = ? AND column_name = ?",
+				table, column).Scan(&dataType).Error; err != nil {
+				return err
+			}
+			if dataType != "text" && dataType != "character varying" {
+				continue
+			}
+
+			sql := fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN %s TYPE timestamptz USING (NULLIF(%s, '')::timestamp AT TIME ZONE '%s')`,
+				table, column, column, zone)
+			if err := tx.Exec(sql).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parksFromChannelNames creates a park for every channel name prefix such
+// as "P4" in "P4-6", which is how the park used to be derived, and assigns
+// the cameras to it.
+func parksFromChannelNames(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable(&camera.CamFix{}) {
+		return nil
+	}
+	if err := tx.AutoMigrate(&modelpark.Park{}, &camera.CamFix{}); err != nil {
+		return err
+	}
+
+	var cams []camera.CamFix
+	if err := tx.Where("park_id IS NULL").Find(&cams).Error; err != nil {
+		return err
+	}
+
+	parks := make(map[string]int)
+	for _, cam := range cams {
+		code, _, _ := strings.Cut(strings.TrimSpace(cam.ChannelName), "-")
+		if code == "" {
+			continue
+		}
+
+		id, ok := parks[code]
+		if !ok {
+			park := modelpark.Park{Code: code, Name: code}
+			if err := tx.Where(modelpark.Park{Code: code}).FirstOrCreate(&park).Error; err != nil {
+				return err
+			}
+			id = park.Id
+			parks[code] = id
+		}
+		if err := tx.Model(&camera.CamFix{}).Where("id = ?", cam.Id).Update("park_id", id).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// paymentsFromCars writes one payment per exited visit with a fee, taken
+// by the visit's operator in the session that was open at its exit.
+func paymentsFromCars(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable(&modelscar.Car_Model{}) {
+		return nil
+	}
+	if err := tx.AutoMigrate(&modelscar.Car_Model{}, &modeloperator.Operator{}, &modelpayment.Payment{}); err != nil {
+		return err
+	}
+
+	return tx.Exec(`INSERT INTO payments (car_id, amount, method, kind, operator, shift_id, park_no, note, created_at)
+		SELECT c.id, ROUND(c.total_payment * 100),
+			CASE WHEN c.subscription_id IS NULL THEN 'cash' ELSE 'subscription' END,
+			'payment', c.user_id,
+			(SELECT o.id FROM operators o WHERE o.operator = c.user_id AND o.login_at <= c.end_time
+				ORDER BY o.login_at DESC LIMIT 1),
+			c.park_no, 'migrated from car_models', COALESCE(c.end_time, NOW())
+		FROM car_models c
+		WHERE c.status = 'Exited' AND c.total_payment > 0`).Error
+}
+
+// shiftsFromOperators creates a shift with the same id for every operator
+// session, so that the shift_id of migrated payments stays valid. Sessions
+// that are still open become open shifts; the others count as approved.
+func shiftsFromOperators(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable(&modeloperator.Operator{}) {
+		return nil
+	}
+	if err := tx.AutoMigrate(&modelshift.Shift{}, &modelshift.Denomination{}); err != nil {
+		return err
+	}
+
+	err := tx.Exec(`INSERT INTO shifts (id, operator, park_no, status, opened_at, closed_at,
+			expected_cash, declared_cash, note, approved_by, approved_at, created_at, updated_at)
+		SELECT o.id, o.operator, o.park,
+			CASE WHEN o.logout_at IS NULL THEN ? ELSE ? END,
+			o.login_at, o.logout_at, o.money * 100, o.money * 100, 'migrated from operators',
+			CASE WHEN o.logout_at IS NULL THEN '' ELSE 'migration' END, o.logout_at, NOW(), NOW()
+		FROM operators o
+		ON CONFLICT (id) DO NOTHING`, modelshift.StatusOpen, modelshift.StatusApproved).Error
+	if err != nil {
+		return err
+	}
+	return tx.Exec(`SELECT setval(pg_get_serial_sequence('shifts', 'id'), COALESCE((SELECT MAX(id) FROM shifts), 0) + 1, false)`).Error
+}
+
+// macroscopLoginToSecrets seals the Macroscop login that was kept in plain
+// text in mac_users and drops that table. It needs SECRET_STORE_KEY.
+func macroscopLoginToSecrets(tx *gorm.DB) error {
+	if err := tx.AutoMigrate(&modelsecret.Secret{}); err != nil {
+		return err
+	}
+	if !tx.Migrator().HasTable("mac_users") {
+		return nil
+	}
+
+	var login struct {
+		MacUsername string
+		MacPassword string
+	}
+	result := tx.Table("mac_users").Order("id").Limit(1).Scan(&login)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		if err := secrets.Set(tx, secrets.MacroscopUsername, login.MacUsername); err != nil {
+			return err
+		}
+		if err := secrets.Set(tx, secrets.MacroscopPassword, login.MacPassword); err != nil {
+			return err
+		}
+	}
+	return tx.Migrator().DropTable("mac_users")
+}
+
+// normalizeCarNumbers rewrites the plates stored before plates were
+// normalized, so that old visits match the exits read since.
+func normalizeCarNumbers(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable(&modelscar.Car_Model{}) {
+		return nil
+	}
+
+	var numbers []string
+	if err := tx.Model(&modelscar.Car_Model{}).Distinct().Pluck("car_number", &numbers).Error; err != nil {
+		return err
+	}
+	for _, number := range numbers {
+		normalized := plate.Normalize(number)
+		if normalized == number {
+			continue
+		}
+		err := tx.Model(&modelscar.Car_Model{}).Where("car_number = ?", number).
+			Update("car_number", normalized).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,16 +1,23 @@
 package main
 
 import (
+	"log"
+	"os"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/swagger"
 
 	"park/controller/imagetoplate"
+	"park/controller/occupancy"
 	"park/controller/operator"
 	"park/database"
 	_ "park/docs"
+	"park/middleware"
+	"park/replay"
 	"park/routes"
+	"park/service/session"
 	"park/util"
 )
 
@@ -18,8 +25,22 @@ import (
 // @host 127.0.0.1:3000
 // @BasePath /
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err := replay.Run(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	database.ConnectDB()
+	if err := session.LoadRevoked(database.DB); err != nil {
+		log.Fatal("Failed to load revoked sessions:", err)
+	}
 	util.LoadVIPPlates()
+	util.LoadTariffPlans()
+	if _, err := occupancy.Recount(); err != nil {
+		log.Println("Failed to count occupancy:", err)
+	}
 
 	app := fiber.New()
 	app.Use(logger.New())
@@ -32,15 +53,12 @@ func main() {
 	}))
 	app.Get("/swagger/*", swagger.HandlerDefault)
 	go operator.HandleMessages()
+	go occupancy.Run()
 	go imagetoplate.WatchDirectory("image", database.DB)
 
-	routes.AuthRoute(app)
-	routes.InitAdminRoute(app)
-	routes.CameraRoutes(app)
-	routes.AccountantRoutes(app)
-	routes.InitZreport(app)
-	routes.InitRealtime(app)
-	routes.FixRoute(app)
-	routes.Init(app)
+	routes.Register(app)
+	if err := middleware.LoadPermissions(database.DB); err != nil {
+		log.Fatal("Failed to load permissions:", err)
+	}
 	app.Listen(":3000")
 }
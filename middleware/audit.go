@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"park/database"
+	modelaudit "park/models/auditModel"
+	"park/service/audit"
+	"park/util/sitetime"
+)
+
+// maxAuditBody is the largest request body stored when the handler does
+// not tell what it changed.
+const maxAuditBody = 64 << 10
+
+// Audit writes every POST, PUT, PATCH and DELETE of a logged-in user to the
+// audit trail once the handler has run. Handlers describe their change
+// with audit.Before and audit.After; for the others the request body is
+// kept. Requests without a user, such as logins and camera events, are
+// left to the security log and the event log.
+func Audit(c *fiber.Ctx) error {
+	switch c.Method() {
+	case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+	default:
+		return c.Next()
+	}
+
+	err := c.Next()
+
+	actor, _ := c.Locals("username").(string)
+	if actor == "" || database.DB == nil {
+		return err
+	}
+	status := c.Response().StatusCode()
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		status = fiberErr.Code
+	} else if err != nil {
+		status = fiber.StatusInternalServerError
+	}
+
+	role, _ := c.Locals("role").(string)
+	entry := modelaudit.Entry{
+		At:     sitetime.From(sitetime.Now()),
+		Actor:  actor,
+		Role:   role,
+		IP:     c.IP(),
+		Method: c.Method(),
+		Path:   c.Path(),
+		Action: c.Method() + " " + c.Route().Path,
+		Status: status,
+	}
+	if change, ok := audit.Recorded(c); ok {
+		entry.Entity = change.Entity
+		entry.EntityId = change.EntityId
+		entry.Before = change.Before
+		entry.After = change.After
+		entry.Diff = audit.Diff(change.Before, change.After)
+	} else {
+		entry.Entity = entityOf(c.Route().Path)
+		entry.EntityId = c.Params("id")
+		if body := c.Body(); len(body) <= maxAuditBody {
+			entry.After = audit.Redact(body)
+		}
+	}
+
+	if appendErr := audit.Append(database.DB, &entry); appendErr != nil {
+		log.Println("Failed to write audit entry for", entry.Action, "by", actor, ":", appendErr)
+	}
+	return err
+}
+
+// entityOf names the entity of a route by its last fixed path segment.
+func entityOf(route string) string {
+	parts := strings.Split(strings.Trim(route, "/"), "/")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i] != "" && !strings.HasPrefix(parts[i], ":") {
+			return parts[i]
+		}
+	}
+	return ""
+}
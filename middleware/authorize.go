@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	modelsuser "park/models/modelsUser"
+	"park/service/security"
+)
+
+// The permission matrix maps a permission name to the roles allowed to use
+// it. Routes declare their permission and its default roles with Allow;
+// LoadPermissions stores the defaults in the permissions table and from
+// then on the table decides.
+var (
+	permissionsMu sync.RWMutex
+	permissions   = make(map[string]map[modelsuser.RoleType]bool)
+	defaults      = make(map[string][]modelsuser.RoleType)
+)
+
+// Allow authenticates the request like Auth and lets it through only when
+// the role of the user holds permission. Admins hold every permission.
+func Allow(permission string, roles ...modelsuser.RoleType) fiber.Handler {
+	permissionsMu.Lock()
+	if _, ok := defaults[permission]; !ok {
+		defaults[permission] = roles
+		permissions[permission] = roleSet(roles)
+	}
+	permissionsMu.Unlock()
+
+	return func(c *fiber.Ctx) error {
+		if status, message, forged := authenticate(c); status != 0 {
+			return reject(c, status, message, forged)
+		}
+		role, _ := c.Locals("role").(string)
+		if !Allowed(permission, modelsuser.RoleType(role)) {
+			username, _ := c.Locals("username").(string)
+			security.Record(c, security.PermissionDenied, username, permission)
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "Forbidden - Role " + role + " does not have permission " + permission,
+			})
+		}
+		return c.Next()
+	}
+}
+
+// Allowed reports whether role holds permission.
+func Allowed(permission string, role modelsuser.RoleType) bool {
+	if role == modelsuser.AdminRole {
+		return true
+	}
+	permissionsMu.RLock()
+	defer permissionsMu.RUnlock()
+	return permissions[permission][role]
+}
+
+func roleSet(roles []modelsuser.RoleType) map[modelsuser.RoleType]bool {
+	set := make(map[modelsuser.RoleType]bool, len(roles))
+	for _, role := range roles {
+		set[role] = true
+	}
+	return set
+}
+
+// Matrix returns the roles of every declared permission, sorted by name.
+func Matrix() map[string][]modelsuser.RoleType {
+	permissionsMu.RLock()
+	defer permissionsMu.RUnlock()
+
+	matrix := make(map[string][]modelsuser.RoleType, len(defaults))
+	for name := range defaults {
+		roles := []modelsuser.RoleType{}
+		for role := range permissions[name] {
+			roles = append(roles, role)
+		}
+		sort.Slice(roles, func(i, j int) bool { return roles[i] < roles[j] })
+		matrix[name] = roles
+	}
+	return matrix
+}
+
+// LoadPermissions writes the default roles of permissions that are not in
+// the permissions table yet and loads the table. It must run after the
+// routes are registered.
+func LoadPermissions(db *gorm.DB) error {
+	var rows []modelsuser.Permission
+	if err := db.Find(&rows).Error; err != nil {
+		return err
+	}
+	known := make(map[string]bool)
+	for _, row := range rows {
+		known[row.Name] = true
+	}
+
+	permissionsMu.Lock()
+	defer permissionsMu.Unlock()
+	for name, roles := range defaults {
+		if known[name] {
+			continue
+		}
+		// A row without a role marks the permission as stored, so that
+		// taking every role away from it is not undone at the next start.
+		stored := []modelsuser.Permission{{Name: name}}
+		for _, role := range roles {
+			stored = append(stored, modelsuser.Permission{Name: name, Role: role})
+		}
+		if err := db.Create(&stored).Error; err != nil {
+			return err
+		}
+		rows = append(rows, stored...)
+	}
+
+	loaded := make(map[string]map[modelsuser.RoleType]bool)
+	for _, row := range rows {
+		if loaded[row.Name] == nil {
+			loaded[row.Name] = make(map[modelsuser.RoleType]bool)
+		}
+		if row.Role != "" {
+			loaded[row.Name][row.Role] = true
+		}
+	}
+	for name := range defaults {
+		permissions[name] = loaded[name]
+	}
+	return nil
+}
+
+// SetPermission replaces the roles of a declared permission.
+func SetPermission(db *gorm.DB, name string, roles []modelsuser.RoleType) error {
+	permissionsMu.Lock()
+	defer permissionsMu.Unlock()
+	if _, ok := defaults[name]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("name = ?", name).Delete(&modelsuser.Permission{}).Error; err != nil {
+			return err
+		}
+		rows := []modelsuser.Permission{{Name: name}}
+		for _, role := range roles {
+			rows = append(rows, modelsuser.Permission{Name: name, Role: role})
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		return err
+	}
+	permissions[name] = roleSet(roles)
+	return nil
+}
@@ -1,15 +1,39 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
+
+	"park/service/security"
+	"park/service/session"
 )
 
 func Auth(c *fiber.Ctx) error {
+	if status, message, forged := authenticate(c); status != 0 {
+		return reject(c, status, message, forged)
+	}
+	return c.Next()
+}
+
+// reject answers a request that authenticate refused. Only forged and
+// malformed tokens are written to the security log; missing, expired and
+// revoked tokens are part of normal use and would flood it.
+func reject(c *fiber.Ctx, status int, message string, forged bool) error {
+	if forged {
+		security.Record(c, security.TokenRejected, "", message)
+	}
+	return c.Status(status).JSON(fiber.Map{"message": message})
+}
+
+// authenticate verifies the JWT of the request and stores its claims in
+// the locals. On failure it returns the status and message to respond with
+// and whether the token was forged or malformed.
+func authenticate(c *fiber.Ctx) (int, string, bool) {
 	token := c.Cookies("jwt")
 	if token == "" {
 		authHeader := c.Get("Authorization")
@@ -19,40 +43,34 @@ func Auth(c *fiber.Ctx) error {
 	}
 
 	if token == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Unauthorized - No token provided",
-		})
+		return fiber.StatusUnauthorized, "Unauthorized - No token provided", false
 	}
 
 	claims := jwt.MapClaims{}
 	parsedToken, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
 		return []byte(os.Getenv("SECRET_KEY_JWT")), nil
 	})
+	var invalid *jwt.ValidationError
+	if errors.As(err, &invalid) && invalid.Errors == jwt.ValidationErrorExpired {
+		return fiber.StatusUnauthorized, "Unauthorized - Invalid token", false
+	}
 	if err != nil || !parsedToken.Valid {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Unauthorized - Invalid token",
-		})
+		return fiber.StatusUnauthorized, "Unauthorized - Invalid token", true
 	}
 
 	username, ok := claims["username"].(string)
 	if !ok || username == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Bad Request - Username not found or invalid type",
-		})
+		return fiber.StatusBadRequest, "Bad Request - Username not found or invalid type", true
 	}
 
 	role, ok := claims["role"].(string)
 	if !ok || role == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Bad Request - Role not found or invalid type",
-		})
+		return fiber.StatusBadRequest, "Bad Request - Role not found or invalid type", true
 	}
 
 	userIDValue, ok := claims["user_id"]
 	if !ok || userIDValue == nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Bad Request - User ID not found or invalid type",
-		})
+		return fiber.StatusBadRequest, "Bad Request - User ID not found or invalid type", true
 	}
 
 	var userID string
@@ -62,42 +80,29 @@ func Auth(c *fiber.Ctx) error {
 	case float64:
 		userID = fmt.Sprintf("%.0f", v)
 	default:
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Bad Request - User ID has invalid type",
-		})
+		return fiber.StatusBadRequest, "Bad Request - User ID has invalid type", true
 	}
 
 	parkNo, ok := claims["parkno"].(string)
 	if !ok {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Bad Request - Park number not found in token",
-		})
-	}
-
-	macusername, ok := claims["macusername"].(string)
-	if !ok {
-		macusername = "N/A"
+		return fiber.StatusBadRequest, "Bad Request - Park number not found in token", true
 	}
 
-	macpassword, ok := claims["macpassword"].(string)
-	if !ok {
-		macpassword = "N/A"
+	sessionID, ok := claims["sid"].(string)
+	if !ok || sessionID == "" {
+		return fiber.StatusUnauthorized, "Unauthorized - Invalid token", true
 	}
-
-	keys, ok := claims["keys"].(string)
-	if !ok {
-		keys = "N/A"
+	if session.Revoked(sessionID) {
+		return fiber.StatusUnauthorized, "Unauthorized - Session revoked", false
 	}
 
 	c.Locals("parkno", parkNo)
 	c.Locals("user_id", userID)
 	c.Locals("username", username)
 	c.Locals("role", role)
-	c.Locals("macusername", macusername)
-	c.Locals("macpassword", macpassword)
-	c.Locals("keys", keys)
+	c.Locals("session_id", sessionID)
 
-	return c.Next()
+	return 0, "", false
 }
 func SetParkNoCookie(c *fiber.Ctx, parkNo string) {
 	c.Cookie(&fiber.Cookie{
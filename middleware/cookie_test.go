@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func signed(t *testing.T, key string, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestAuthenticateFlagsForgedTokens(t *testing.T) {
+	t.Setenv("SECRET_KEY_JWT", "test")
+	claims := func(exp time.Time) jwt.MapClaims {
+		return jwt.MapClaims{
+			"user_id":  7,
+			"username": "op",
+			"role":     "operator",
+			"parkno":   "P4",
+			"sid":      "session",
+			"exp":      exp.Unix(),
+		}
+	}
+	valid := time.Now().Add(time.Minute)
+
+	cases := []struct {
+		name   string
+		token  string
+		status int
+		forged bool
+	}{
+		{"valid", signed(t, "test", claims(valid)), 0, false},
+		{"missing", "", fiber.StatusUnauthorized, false},
+		{"expired", signed(t, "test", claims(time.Now().Add(-time.Minute))), fiber.StatusUnauthorized, false},
+		{"wrong key", signed(t, "other", claims(valid)), fiber.StatusUnauthorized, true},
+		{"expired with wrong key", signed(t, "other", claims(time.Now().Add(-time.Minute))), fiber.StatusUnauthorized, true},
+		{"malformed", "not.a.token", fiber.StatusUnauthorized, true},
+		{"no session", signed(t, "test", jwt.MapClaims{"user_id": 7, "username": "op", "role": "operator", "parkno": "P4"}), fiber.StatusUnauthorized, true},
+	}
+
+	app := fiber.New()
+	var status int
+	var forged bool
+	app.Get("/", func(c *fiber.Ctx) error {
+		status, _, forged = authenticate(c)
+		return nil
+	})
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		if _, err := app.Test(req, -1); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if status != tc.status || forged != tc.forged {
+			t.Errorf("%s: got status %d forged %v, want %d %v", tc.name, status, forged, tc.status, tc.forged)
+		}
+	}
+}
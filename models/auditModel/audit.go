@@ -0,0 +1,63 @@
+package modelaudit
+
+import (
+	"database/sql/driver"
+	"errors"
+
+	"park/util/sitetime"
+)
+
+// Entry is one mutating request in the audit trail. Hash covers the entry
+// and PrevHash, the hash of the entry before it, so that changing or
+// removing a past entry breaks the chain.
+type Entry struct {
+	Id       int64         `json:"id" gorm:"primaryKey"`
+	At       sitetime.Time `json:"at" gorm:"index"`
+	Actor    string        `json:"actor" gorm:"index"`
+	Role     string        `json:"role"`
+	IP       string        `json:"ip"`
+	Method   string        `json:"method"`
+	Path     string        `json:"path"`
+	Action   string        `json:"action" gorm:"index" example:"PUT /api/v1/users/:id"`
+	Entity   string        `json:"entity" gorm:"index:idx_audit_entity" example:"user"`
+	EntityId string        `json:"entity_id" gorm:"index:idx_audit_entity" example:"7"`
+	Status   int           `json:"status"`
+	Before   JSON          `json:"before" swaggertype:"object"`
+	After    JSON          `json:"after" swaggertype:"object"`
+	Diff     JSON          `json:"diff" swaggertype:"object"`
+	PrevHash string        `json:"prev_hash" gorm:"size:64"`
+	Hash     string        `json:"hash" gorm:"size:64"`
+}
+
+// JSON is a JSON document kept as text, byte for byte, so that hashes
+// over it stay valid. It is rendered as JSON, or null when empty.
+type JSON string
+
+func (JSON) GormDataType() string {
+	return "text"
+}
+
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if j == "" {
+		return []byte("null"), nil
+	}
+	return []byte(j), nil
+}
+
+func (j JSON) Value() (driver.Value, error) {
+	return string(j), nil
+}
+
+func (j *JSON) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*j = ""
+	case string:
+		*j = JSON(v)
+	case []byte:
+		*j = JSON(v)
+	default:
+		return errors.New("unsupported type for audit JSON")
+	}
+	return nil
+}
@@ -8,6 +8,8 @@ type CapturedEventData struct {
 	EventComment     string    `json:"EventComment"`
 	ChannelName      string    `json:"ChannelName"`
 	CapturedTime     time.Time `json:"captured_time"`
+	Timestamp        time.Time `json:"Timestamp"`
+	ChannelId        string    `json:"ChannelId"`
 }
 
 type CameraType string
@@ -23,6 +25,7 @@ type CapturedEventDataE struct {
 	EventComment     string    `json:"EventComment"`
 	ChannelName      string    `json:"ChannelName"`
 	CapturedTime     time.Time `json:"captured_time"`
+	Timestamp        time.Time `json:"Timestamp"`
 	ChannelId        string    `json:"ChannelId"`
 }
 
@@ -37,4 +40,5 @@ type CamFix struct {
 	ChannelName string     `json:"ChannelName"`
 	ChannelId   string     `json:"ChannelId"`
 	Type        CameraType `json:"type"`
+	ParkId      *int       `json:"park_id" gorm:"index"`
 }
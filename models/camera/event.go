@@ -0,0 +1,16 @@
+package camera
+
+import "time"
+
+// EventLog remembers the response given to a camera event so that a
+// redelivery of the same EventId and ChannelId gets the same answer.
+type EventLog struct {
+	Id         int       `json:"id"`
+	EventID    string    `json:"EventId" gorm:"uniqueIndex:idx_event_logs_event_channel"`
+	ChannelId  string    `json:"ChannelId" gorm:"uniqueIndex:idx_event_logs_event_channel"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	StatusCode int       `json:"status_code"`
+	Response   string    `json:"response"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package camera
+
+import "time"
+
+// JournalEntry is the verbatim copy of one request to the camera endpoints.
+// Entries are only ever inserted so that a day can be rebuilt by replay.
+type JournalEntry struct {
+	Id         int64     `json:"id"`
+	ReceivedAt time.Time `json:"received_at" gorm:"index"`
+	SourceIP   string    `json:"source_ip"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Body       string    `json:"body"`
+	StatusCode int       `json:"status_code"`
+	Outcome    string    `json:"outcome"`
+}
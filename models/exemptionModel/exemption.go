@@ -0,0 +1,61 @@
+package modelexemption
+
+import "park/util/sitetime"
+
+// Reason is an entry of the catalog of reasons an operator may let a car
+// out without payment. The code is stored as the reason of the car, so
+// free exits are reported by code.
+type Reason struct {
+	Id               int           `json:"id" gorm:"primaryKey"`
+	Code             string        `json:"code" gorm:"uniqueIndex;size:32" example:"ambulance"`
+	Name             string        `json:"name" example:"Ambulance"`
+	RequiresApproval bool          `json:"requires_approval" example:"false"`
+	Active           bool          `json:"active" example:"true"`
+	CreatedAt        sitetime.Time `json:"created_at"`
+}
+
+type Status string
+
+const (
+	Pending  Status = "pending"
+	Approved Status = "approved"
+	Rejected Status = "rejected"
+	Applied  Status = "applied"
+)
+
+// Via tells how an exemption was approved.
+type Via string
+
+const (
+	ViaNone   Via = "none"   // the reason and the fee need no approval
+	ViaSelf   Via = "self"   // the operator may approve exemptions
+	ViaPin    Via = "pin"    // a supervisor entered a PIN at the terminal
+	ViaRemote Via = "remote" // a supervisor approved a request
+)
+
+// Exemption is a free exit, or a request for one waiting for a
+// supervisor. Amount is the waived fee in minor units.
+type Exemption struct {
+	Id          int64         `json:"id" gorm:"primaryKey"`
+	CarId       int           `json:"car_id" gorm:"index"`
+	CarNumber   string        `json:"car_number"`
+	ParkNo      string        `json:"park_no" gorm:"index"`
+	ReasonCode  string        `json:"reason_code" gorm:"index;size:32"`
+	Note        string        `json:"note"`
+	Amount      int64         `json:"amount" example:"300"`
+	Status      Status        `json:"status" gorm:"index" enums:"pending,approved,rejected,applied"`
+	RequestedBy string        `json:"requested_by"`
+	ApprovedBy  string        `json:"approved_by"`
+	Via         Via           `json:"via" enums:"none,self,pin,remote"`
+	CreatedAt   sitetime.Time `json:"created_at" gorm:"index"`
+	DecidedAt   sitetime.Time `json:"decided_at"`
+	ExpiresAt   sitetime.Time `json:"expires_at"`
+	AppliedAt   sitetime.Time `json:"applied_at"`
+}
+
+// Pin is the hashed approval PIN of a supervisor.
+type Pin struct {
+	UserId    int           `json:"-" gorm:"primaryKey;autoIncrement:false"`
+	Hash      string        `json:"-"`
+	UpdatedAt sitetime.Time `json:"-"`
+}
@@ -0,0 +1,33 @@
+package modelgate
+
+import "time"
+
+// Gate is a barrier that is opened by one of the gate drivers. Exits seen
+// by the camera with ChannelId open this gate.
+type Gate struct {
+	Id         int       `json:"id" gorm:"primaryKey"`
+	Name       string    `json:"name" example:"P4 exit"`
+	ParkId     *int      `json:"park_id" gorm:"index"`
+	ChannelId  string    `json:"ChannelId" gorm:"index" example:"8dc9685f-a80b-4d95-ae19-da340efe89ab"`
+	Driver     string    `json:"driver" example:"macroscop" enums:"macroscop,http,simulator"`
+	Address    string    `json:"address" example:"http://10.0.0.20/relay"`
+	Relay      string    `json:"relay" example:"1"`
+	CloseAfter int       `json:"close_after" example:"10"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Event is one open or close command sent to a gate and its result.
+type Event struct {
+	Id        int       `json:"id" gorm:"primaryKey"`
+	GateId    int       `json:"gate_id" gorm:"index"`
+	Action    string    `json:"action" example:"open"`
+	Trigger   string    `json:"trigger" example:"payment"`
+	CarId     *int      `json:"car_id"`
+	User      string    `json:"user"`
+	Reason    string    `json:"reason"`
+	Driver    string    `json:"driver"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
@@ -1,23 +1,39 @@
 package modelscar
 
+import "park/util/sitetime"
+
 type Car_Model struct {
-	ID            int     `json:"id"`
-	Car_number    string  `json:"car_number"`
-	Start_time    string  `json:"start_time"`
-	End_time      string  `json:"end_time"`
-	Total_payment float64 `json:"total_payment"`
-	Status        string  `json:"status"`
-	Reason        string  `json:"reason"`
-	Image_Url     string  `json:"image_url"`
-	ParkNo        string  `json:"park_no"`
-	Duration      int     `json:"duration"`
-	User_id       string  `json:"user_id"`
-	PayStatus     bool    `json:"paystatus"`
-	CameraID      string  `json:"cameraid"`
-	CamToken      string  `json:"ChannelId"`
+	ID             int           `json:"id"`
+	Car_number     string        `json:"car_number"`
+	Start_time     sitetime.Time `json:"start_time"`
+	End_time       sitetime.Time `json:"end_time"`
+	Total_payment  float64       `json:"total_payment"`
+	Status         string        `json:"status"`
+	Reason         string        `json:"reason"`
+	Image_Url      string        `json:"image_url"`
+	ParkNo         string        `json:"park_no"`
+	Duration       int           `json:"duration"`
+	User_id        string        `json:"user_id"`
+	PayStatus      bool          `json:"paystatus"`
+	CameraID       string        `json:"cameraid"`
+	CamToken       string        `json:"ChannelId"`
+	SubscriptionId *int          `json:"subscription_id"`
+	FuzzyMatch     bool          `json:"fuzzy_match"`
+	ExitPlate      string        `json:"exit_plate"`
+	NoEntry        bool          `json:"no_entry"`
+	EntryEstimated bool          `json:"entry_estimated"`
 }
 
+// CarUpdate is the exit of a car by an operator. A reason lets the car out
+// without payment; it is a code of the exemption catalog. When the
+// exemption needs a supervisor, either Supervisor and Pin or the id of an
+// approved request are sent with it.
 type CarUpdate struct {
-	Reason        string  `json:"reason"`
+	Reason        string  `json:"reason" example:"ambulance"`
 	Total_payment float64 `json:"total_payment"`
+	Method        string  `json:"method" example:"cash"`
+	Note          string  `json:"note"`
+	Supervisor    string  `json:"supervisor" example:"admin"`
+	Pin           string  `json:"pin" example:"123456"`
+	ApprovalId    int64   `json:"approval_id"`
 }
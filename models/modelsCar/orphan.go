@@ -0,0 +1,20 @@
+package modelscar
+
+import "time"
+
+// OrphanExit is an exit event that arrived before the entry of its visit.
+// It is applied as soon as the matching entry is recorded.
+type OrphanExit struct {
+	ID          int       `json:"id"`
+	Car_number  string    `json:"car_number" gorm:"index"`
+	ExitPlate   string    `json:"exit_plate"`
+	ParkNo      string    `json:"park_no"`
+	ChannelName string    `json:"ChannelName"`
+	ChannelId   string    `json:"ChannelId"`
+	Reason      string    `json:"reason"`
+	CheckPark   bool      `json:"check_park"`
+	Broadcast   bool      `json:"broadcast"`
+	ExitAt      time.Time `json:"exit_at"`
+	VisitId     *int      `json:"visit_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,9 @@
+package modelsuser
+
+// Permission allows a role to use the routes guarded by a permission name.
+// The rows form the permission matrix; admins are always allowed.
+type Permission struct {
+	Id   int      `json:"-" gorm:"primaryKey"`
+	Name string   `json:"name" gorm:"uniqueIndex:idx_permission_role" example:"payments.reverse"`
+	Role RoleType `json:"role" gorm:"uniqueIndex:idx_permission_role" example:"accountant"`
+}
@@ -0,0 +1,19 @@
+package modelsuser
+
+import (
+	"park/util/sitetime"
+)
+
+// SecurityEvent records a failed or refused authentication, such as a
+// wrong password, a locked login or a revoked token.
+type SecurityEvent struct {
+	Id        int64         `json:"id" gorm:"primaryKey"`
+	Kind      string        `json:"kind" gorm:"index" example:"login_failed"`
+	Username  string        `json:"username" gorm:"index"`
+	IP        string        `json:"ip"`
+	UserAgent string        `json:"user_agent"`
+	Method    string        `json:"method"`
+	Path      string        `json:"path"`
+	Detail    string        `json:"detail"`
+	CreatedAt sitetime.Time `json:"created_at" gorm:"index"`
+}
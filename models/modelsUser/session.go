@@ -0,0 +1,28 @@
+package modelsuser
+
+import (
+	"park/util/sitetime"
+)
+
+// Session is a login of a user. Access tokens carry the session ID and
+// live for minutes; the refresh token of the session, kept here only as a
+// hash, gets new ones and is replaced every time it is used.
+// PreviousHash remembers the last replaced refresh token so that its reuse
+// can be recognised; RotatedAt is when it was replaced. A revoked session
+// cannot be refreshed and its access tokens are refused.
+type Session struct {
+	Id           string        `json:"id" gorm:"primaryKey;size:32"`
+	UserId       int           `json:"user_id" gorm:"index"`
+	Username     string        `json:"username"`
+	ParkNo       string        `json:"park_no"`
+	RefreshHash  string        `json:"-" gorm:"uniqueIndex;size:64"`
+	PreviousHash string        `json:"-" gorm:"index;size:64"`
+	RotatedAt    sitetime.Time `json:"-"`
+	UserAgent    string        `json:"user_agent"`
+	IP           string        `json:"ip"`
+	CreatedAt    sitetime.Time `json:"created_at"`
+	LastUsedAt   sitetime.Time `json:"last_used_at"`
+	ExpiresAt    sitetime.Time `json:"expires_at"`
+	RevokedAt    sitetime.Time `json:"revoked_at"`
+	RevokeReason string        `json:"revoke_reason"`
+}
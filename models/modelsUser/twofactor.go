@@ -0,0 +1,25 @@
+package modelsuser
+
+import (
+	"park/util/sitetime"
+)
+
+// TwoFactor is the TOTP enrollment of a user. Secret is sealed by the
+// secret store. LastStep is the time step of the last accepted code, so
+// that a code cannot be used twice.
+type TwoFactor struct {
+	UserId    int           `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	Secret    string        `json:"-"`
+	Enabled   bool          `json:"enabled"`
+	EnabledAt sitetime.Time `json:"enabled_at"`
+	LastStep  int64         `json:"-"`
+}
+
+// RecoveryCode lets a user log in once without the authenticator. Only
+// the hash of the code is kept.
+type RecoveryCode struct {
+	Id     int           `json:"-" gorm:"primaryKey"`
+	UserId int           `json:"-" gorm:"index"`
+	Hash   string        `json:"-" gorm:"size:64"`
+	UsedAt sitetime.Time `json:"-"`
+}
@@ -2,6 +2,8 @@ package modelsuser
 
 import (
 	"gorm.io/gorm"
+
+	"park/util/sitetime"
 )
 
 // RoleType defines a custom type for user roles.
@@ -19,6 +21,8 @@ type User struct {
 	IsActive  bool     `json:"isActive" example:"true"`
 	Role      RoleType `json:"role" example:"admin"`
 	ParkNo    *string  `json:"park_no" example:"P123"`
+
+	PasswordChangedAt sitetime.Time `json:"password_changed_at" swaggerignore:"true"`
 }
 
 type UserRes struct {
@@ -36,9 +40,3 @@ const (
 	OperatorRole   RoleType = "operator"
 	AccountantRole RoleType = "accountant"
 )
-
-type MacUser struct {
-	Id          int    `json:"id"`
-	MacUsername string `json:"macusername"`
-	MacPassword string `json:"macpassword"`
-}
@@ -1,10 +1,12 @@
 package modeloperator
 
+import "park/util/sitetime"
+
 type Operator struct {
-	ID       int64  `json:"id"`
-	Park     string `json:"park"`
-	LoginAt  string `json:"login_at"`
-	LogoutAt string `json:"logout_at"`
-	Operator string `json:"operator"`
-	Money    int    `json:"money"`
+	ID       int64         `json:"id"`
+	Park     string        `json:"park"`
+	LoginAt  sitetime.Time `json:"login_at"`
+	LogoutAt sitetime.Time `json:"logout_at"`
+	Operator string        `json:"operator"`
+	Money    int           `json:"money"`
 }
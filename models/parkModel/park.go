@@ -0,0 +1,36 @@
+package modelpark
+
+import (
+	"time"
+
+	"park/models/camera"
+	"park/util/sitetime"
+)
+
+// Park is a parking lot. Car visits keep its Code in their park_no column.
+// TimeZone names the zone its days and hours are counted in; empty means
+// the site time zone of SITE_TIMEZONE.
+type Park struct {
+	Id                  int             `json:"id" gorm:"primaryKey"`
+	Code                string          `json:"code" gorm:"uniqueIndex" example:"P4"`
+	Name                string          `json:"name" example:"Terminal parking"`
+	Capacity            int             `json:"capacity" example:"120"`
+	TimeZone            string          `json:"time_zone" example:"Asia/Ashgabat"`
+	OccupancyAdjustment int             `json:"occupancy_adjustment"`
+	Cameras             []camera.CamFix `json:"cameras,omitempty" gorm:"foreignKey:ParkId;constraint:OnDelete:SET NULL"`
+	CreatedAt           time.Time       `json:"created_at"`
+	UpdatedAt           time.Time       `json:"updated_at"`
+}
+
+// Location returns the time zone of the park, or the site time zone when
+// it has none or an unknown one.
+func (p Park) Location() *time.Location {
+	if p.TimeZone == "" {
+		return sitetime.Location()
+	}
+	loc, err := time.LoadLocation(p.TimeZone)
+	if err != nil {
+		return sitetime.Location()
+	}
+	return loc
+}
@@ -0,0 +1,37 @@
+package modelpayment
+
+import "time"
+
+type Method string
+
+const (
+	Cash         Method = "cash"
+	Card         Method = "card"
+	QR           Method = "qr"
+	Subscription Method = "subscription"
+)
+
+type Kind string
+
+const (
+	KindPayment Kind = "payment"
+	KindRefund  Kind = "refund"
+	KindVoid    Kind = "void"
+)
+
+// Payment is one row of the payments ledger. Amounts are in minor units
+// (1/100 of the currency); refunds and voids are negative rows that point
+// at the payment they reverse with RefId.
+type Payment struct {
+	Id        int       `json:"id" gorm:"primaryKey"`
+	CarId     int       `json:"car_id" gorm:"index"`
+	Amount    int64     `json:"amount" example:"300"`
+	Method    Method    `json:"method" example:"cash" enums:"cash,card,qr,subscription"`
+	Kind      Kind      `json:"kind" example:"payment" enums:"payment,refund,void"`
+	Operator  string    `json:"operator" gorm:"index"`
+	ShiftId   *int64    `json:"shift_id" gorm:"index"`
+	ParkNo    string    `json:"park_no" gorm:"index"`
+	RefId     *int      `json:"ref_id" gorm:"index"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
@@ -0,0 +1,49 @@
+package modelreport
+
+import (
+	"time"
+
+	modelpayment "park/models/paymentModel"
+	"park/util/sitetime"
+)
+
+// ZReport is the report that closes a shift. Numbers are gapless and never
+// reused. X-reports have the same shape but are not stored and have no
+// number. Amounts are in minor units.
+type ZReport struct {
+	Id             int64            `json:"-" gorm:"primaryKey"`
+	Number         int64            `json:"number" gorm:"uniqueIndex" example:"17"`
+	ShiftId        int64            `json:"shift_id" gorm:"uniqueIndex"`
+	Operator       string           `json:"operator" gorm:"index"`
+	ParkNo         string           `json:"park_no" gorm:"index"`
+	OpenedAt       sitetime.Time    `json:"opened_at"`
+	ClosedAt       sitetime.Time    `json:"closed_at" gorm:"index"`
+	Totals         []MethodTotal    `json:"totals" gorm:"foreignKey:ReportId;constraint:OnDelete:CASCADE"`
+	Refunds        int64            `json:"refunds"`
+	Net            int64            `json:"net"`
+	Exemptions     []ExemptionCount `json:"exemptions" gorm:"foreignKey:ReportId;constraint:OnDelete:CASCADE"`
+	ExemptionTotal int              `json:"exemption_total"`
+	VipExits       int              `json:"vip_exits"`
+	OpeningFloat   int64            `json:"opening_float"`
+	ExpectedCash   int64            `json:"expected_cash"`
+	DeclaredCash   int64            `json:"declared_cash"`
+	Variance       int64            `json:"variance"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+// MethodTotal is the net amount taken with one payment method.
+type MethodTotal struct {
+	Id       int64               `json:"-" gorm:"primaryKey"`
+	ReportId int64               `json:"-" gorm:"index"`
+	Method   modelpayment.Method `json:"method" example:"cash"`
+	Payments int                 `json:"payments" example:"12"`
+	Amount   int64               `json:"amount" example:"3600"`
+}
+
+// ExemptionCount is the number of exits let out for free with one reason.
+type ExemptionCount struct {
+	Id       int64  `json:"-" gorm:"primaryKey"`
+	ReportId int64  `json:"-" gorm:"index"`
+	Reason   string `json:"reason"`
+	Count    int    `json:"count"`
+}
@@ -0,0 +1,11 @@
+package modelsecret
+
+import "time"
+
+// Secret is a value sealed with the secret store key. It is never sent to
+// clients.
+type Secret struct {
+	Name      string    `json:"name" gorm:"primaryKey"`
+	Value     string    `json:"-"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
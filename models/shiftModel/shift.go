@@ -0,0 +1,44 @@
+package modelshift
+
+import (
+	"time"
+
+	"park/util/sitetime"
+)
+
+const (
+	StatusOpen     = "open"
+	StatusClosed   = "closed"
+	StatusApproved = "approved"
+)
+
+// Shift is the time an operator works a till. Amounts are in minor units.
+// ExpectedCash is the opening float plus the net cash payments of the
+// shift; Variance is DeclaredCash minus ExpectedCash. Approved shifts are
+// locked.
+type Shift struct {
+	Id            int64          `json:"id" gorm:"primaryKey"`
+	Operator      string         `json:"operator" gorm:"index"`
+	ParkNo        string         `json:"park_no"`
+	Status        string         `json:"status" gorm:"index" enums:"open,closed,approved"`
+	OpenedAt      sitetime.Time  `json:"opened_at"`
+	ClosedAt      sitetime.Time  `json:"closed_at"`
+	OpeningFloat  int64          `json:"opening_float" example:"5000"`
+	ExpectedCash  int64          `json:"expected_cash"`
+	DeclaredCash  int64          `json:"declared_cash"`
+	Variance      int64          `json:"variance"`
+	Denominations []Denomination `json:"denominations" gorm:"foreignKey:ShiftId;constraint:OnDelete:CASCADE"`
+	Note          string         `json:"note"`
+	ApprovedBy    string         `json:"approved_by"`
+	ApprovedAt    sitetime.Time  `json:"approved_at"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// Denomination is the count of one note or coin declared at close.
+type Denomination struct {
+	Id      int64 `json:"-" gorm:"primaryKey"`
+	ShiftId int64 `json:"-" gorm:"index"`
+	Value   int64 `json:"value" example:"1000"`
+	Count   int   `json:"count" example:"3"`
+}
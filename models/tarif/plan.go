@@ -0,0 +1,34 @@
+package tarif
+
+import "time"
+
+// Plan is a pricing plan used to calculate the exit fee of a visit.
+// The plan with the latest ValidFrom that is not after the start of a
+// visit is the one applied to it, so old plans are kept for recalculation.
+// A plan that is deactivated or deleted is ended at ValidUntil instead of
+// removed; visits that started before still find it. An inactive plan
+// that was never ended is a draft and prices nothing.
+type Plan struct {
+	Id               int        `json:"id"`
+	Name             string     `json:"name" example:"Standard"`
+	Active           bool       `json:"active" example:"true"`
+	ValidFrom        time.Time  `json:"valid_from"`
+	ValidUntil       *time.Time `json:"valid_until"`
+	FirstHourPrice   float64    `json:"first_hour_price" example:"1"`
+	IncrementMinutes int        `json:"increment_minutes" example:"60"`
+	IncrementPrice   float64    `json:"increment_price" example:"0.5"`
+	DailyCap         float64    `json:"daily_cap" example:"3"`
+	PerStartedDay    bool       `json:"per_started_day" example:"true"`
+	LostTicketFee    float64    `json:"lost_ticket_fee" example:"3"`
+	Bands            []Band     `json:"bands" gorm:"foreignKey:PlanId;constraint:OnDelete:CASCADE"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// Band is a fixed price for stays up to UpToMinutes within one day.
+type Band struct {
+	Id          int     `json:"id"`
+	PlanId      int     `json:"plan_id"`
+	UpToMinutes int     `json:"up_to_minutes" example:"360"`
+	Price       float64 `json:"price" example:"2"`
+}
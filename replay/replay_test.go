@@ -0,0 +1,83 @@
+package replay
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"park/controller/getdata"
+)
+
+// journal holds an entry, its redelivery by the camera, and an exit.
+var journal = []string{
+	`{"method":"POST","path":"/api/v1/camera/getdata","body":"{\"EventId\":\"e1\",\"ChannelId\":\"c1\"}","received_at":"2025-01-29T08:00:00Z"}`,
+	`{"method":"POST","path":"/api/v1/camera/getdata","body":"{\"EventId\":\"e1\",\"ChannelId\":\"c1\"}","received_at":"2025-01-29T08:00:05Z"}`,
+	`{"EventId":"e2","ChannelId":"c2","received_at":"2025-01-29T10:00:00Z"}`,
+}
+
+func replayRun(t *testing.T, app *fiber.App) {
+	t.Helper()
+	getdata.Events = getdata.NewMemoryCache()
+	for _, raw := range journal {
+		line, err := parseLine([]byte(raw), fiber.MethodPut, "/api/v1/camera/getdata")
+		if err != nil {
+			t.Fatalf("parseLine(%s): %v", raw, err)
+		}
+		if status, _, err := send(app, line); err != nil || status != fiber.StatusOK {
+			t.Fatalf("send(%s) = %d, %v", raw, status, err)
+		}
+	}
+}
+
+func TestReplayAppliesEventsAgain(t *testing.T) {
+	defer func() {
+		getdata.Events = getdata.DBCache{}
+		getdata.Now = time.Now
+	}()
+
+	applied := map[string]int{}
+	var seen []time.Time
+	handler := func(c *fiber.Ctx) error {
+		// Fiber reuses the buffer behind Method.
+		applied[strings.Clone(c.Method())]++
+		seen = append(seen, getdata.Now())
+		return c.JSON(fiber.Map{"message": "ok"})
+	}
+	app := fiber.New()
+	app.Post("/api/v1/camera/getdata", getdata.Idempotent, handler)
+	app.Put("/api/v1/camera/getdata", getdata.Idempotent, handler)
+
+	replayRun(t, app)
+	if applied[fiber.MethodPost] != 1 || applied[fiber.MethodPut] != 1 {
+		t.Fatalf("first run applied %v, want the redelivery dropped", applied)
+	}
+	if want := time.Date(2025, 1, 29, 10, 0, 0, 0, time.UTC); !seen[1].Equal(want) {
+		t.Fatalf("exit ran at %v, want the receive time %v", seen[1], want)
+	}
+
+	// A second replay of the same journal applies the events again rather
+	// than answering from the responses of the first run.
+	replayRun(t, app)
+	if applied[fiber.MethodPost] != 2 || applied[fiber.MethodPut] != 2 {
+		t.Fatalf("second run applied %v, want every event again", applied)
+	}
+}
+
+func TestReplaySkipsRefusedRequests(t *testing.T) {
+	for raw, want := range map[string]bool{
+		`{"method":"POST","path":"/api/v1/camera/getdata","body":"{}","status_code":401}`: true,
+		`{"method":"POST","path":"/api/v1/camera/getdata","body":"{}","status_code":503}`: true,
+		`{"method":"POST","path":"/api/v1/camera/getdata","body":"{}","status_code":200}`: false,
+		`{"EventId":"e2","ChannelId":"c2"}`:                                               false,
+	} {
+		line, err := parseLine([]byte(raw), fiber.MethodPost, "/api/v1/camera/getdata")
+		if err != nil {
+			t.Fatalf("parseLine(%s): %v", raw, err)
+		}
+		if got := line.refused(); got != want {
+			t.Errorf("refused(%s) = %v, want %v", raw, got, want)
+		}
+	}
+}
@@ -10,10 +10,23 @@ import (
 
 func AccountantRoutes(app *fiber.App) {
 	act := app.Group("/api/v1/accountant")
-	act.Get("/calculateMoney", middleware.Auth, accountant.CalculateMoney)
-	act.Get("/operators", middleware.Auth, accountant.GetOperators)
-	act.Post("/tarif", tarifcontrol.CreateTarif)
-	act.Delete("/tarif/:id", tarifcontrol.DeleteTarif)
-	act.Get("/tarif", tarifcontrol.GetAllTarif)
-	act.Get("/search_car", tarifcontrol.SearchCar)
+	act.Get("/calculateMoney", middleware.Allow("accounting", roleAccountant), accountant.CalculateMoney)
+	act.Get("/operators", middleware.Allow("accounting", roleAccountant), accountant.GetOperators)
+	act.Get("/operators/export", middleware.Allow("accounting", roleAccountant), accountant.ExportOperators)
+	act.Get("/lost-tickets", middleware.Allow("accounting", roleAccountant), accountant.LostTickets)
+	act.Get("/analytics/revenue", middleware.Allow("accounting", roleAccountant), accountant.Revenue)
+	act.Get("/analytics/visits", middleware.Allow("accounting", roleAccountant), accountant.Visits)
+	act.Get("/analytics/peak-hours", middleware.Allow("accounting", roleAccountant), accountant.PeakHours)
+	act.Get("/analytics/free-exits", middleware.Allow("accounting", roleAccountant), accountant.FreeExits)
+	act.Post("/tarif", middleware.Allow("tariffs", roleAccountant), tarifcontrol.CreateTarif)
+	act.Delete("/tarif/:id", middleware.Allow("tariffs", roleAccountant), tarifcontrol.DeleteTarif)
+	act.Get("/tarif", middleware.Allow("tariffs.read", roleOperator, roleAccountant), tarifcontrol.GetAllTarif)
+	act.Get("/tarif/export", middleware.Allow("tariffs.read", roleOperator, roleAccountant), tarifcontrol.ExportTarifs)
+	act.Get("/search_car", middleware.Allow("tariffs.read", roleOperator, roleAccountant), tarifcontrol.SearchCar)
+	act.Get("/tariff-plans/quote", middleware.Allow("tariffs.read", roleOperator, roleAccountant), tarifcontrol.QuotePlan)
+	act.Post("/tariff-plans", middleware.Allow("tariffs", roleAccountant), tarifcontrol.CreatePlan)
+	act.Get("/tariff-plans", middleware.Allow("tariffs.read", roleOperator, roleAccountant), tarifcontrol.GetPlans)
+	act.Get("/tariff-plans/:id", middleware.Allow("tariffs.read", roleOperator, roleAccountant), tarifcontrol.GetPlan)
+	act.Put("/tariff-plans/:id", middleware.Allow("tariffs", roleAccountant), tarifcontrol.UpdatePlan)
+	act.Delete("/tariff-plans/:id", middleware.Allow("tariffs", roleAccountant), tarifcontrol.DeletePlan)
 }
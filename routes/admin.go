@@ -2,26 +2,45 @@ package routes
 
 import (
 	admincontrol "park/controller/adminControl"
+	parkcontrol "park/controller/parkControl"
 	pdfGenerator "park/controller/pdf"
+	"park/middleware"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 func InitAdminRoute(app *fiber.App) {
 	user := app.Group("/api/v1")
-	user.Post("/users", admincontrol.CreateUser)
-	user.Get("/users", admincontrol.GetAllUsers)
-	user.Get("/user/operators", admincontrol.GetOperator)
-	user.Get("/users/:id", admincontrol.UserGetByID)
-	user.Put("/users/:id", admincontrol.UserUpdate)
-	user.Delete("/users/:id", admincontrol.UserDelete)
-	user.Get("/userCount", admincontrol.UsersCount)
-	user.Post("/pdf", pdfGenerator.CreatePDF)
+	user.Post("/users", middleware.Allow("users"), admincontrol.CreateUser)
+	user.Get("/users", middleware.Allow("users"), admincontrol.GetAllUsers)
+	user.Get("/user/operators", middleware.Allow("users.operators", roleAccountant), admincontrol.GetOperator)
+	user.Get("/users/:id", middleware.Allow("users"), admincontrol.UserGetByID)
+	user.Put("/users/:id", middleware.Allow("users"), admincontrol.UserUpdate)
+	user.Delete("/users/:id", middleware.Allow("users"), admincontrol.UserDelete)
+	user.Get("/users/:id/sessions", middleware.Allow("users"), admincontrol.GetUserSessions)
+	user.Delete("/users/:id/sessions", middleware.Allow("users"), admincontrol.RevokeUserSessions)
+	user.Delete("/users/:id/sessions/:session", middleware.Allow("users"), admincontrol.RevokeUserSession)
+	user.Delete("/users/:id/2fa", middleware.Allow("users"), admincontrol.ResetTwoFactor)
+	user.Get("/userCount", middleware.Allow("users"), admincontrol.UsersCount)
+	user.Post("/pdf", middleware.Allow("reports", roleAccountant), pdfGenerator.CreatePDF)
+	user.Get("/security-events", middleware.Allow("security"), admincontrol.GetSecurityEvents)
+	user.Get("/audit", middleware.Allow("audit"), admincontrol.GetAuditEntries)
+	user.Get("/audit/verify", middleware.Allow("audit"), admincontrol.VerifyAudit)
+	user.Get("/permissions", middleware.Allow("permissions"), admincontrol.GetPermissions)
+	user.Put("/permissions/:name", middleware.Allow("permissions"), admincontrol.UpdatePermission)
 
-	camera := app.Group("/api/v1/")
-	camera.Post("/cameras", admincontrol.CreateCamera)
-	camera.Put("/cameras/:id", admincontrol.UpdateCamera)
-	camera.Get("/cameras/:id", admincontrol.GetCameraByID)
-	camera.Delete("/cameras/:id", admincontrol.DeleteCamera)
-	camera.Get("/cameras/", admincontrol.GetCameras)
+	camera := app.Group("/api/v1/cameras", middleware.Allow("cameras"))
+	camera.Post("/", admincontrol.CreateCamera)
+	camera.Put("/:id", admincontrol.UpdateCamera)
+	camera.Get("/:id", admincontrol.GetCameraByID)
+	camera.Delete("/:id", admincontrol.DeleteCamera)
+	camera.Get("/", admincontrol.GetCameras)
+
+	park := app.Group("/api/v1/parks")
+	park.Post("/", middleware.Allow("parks"), parkcontrol.CreatePark)
+	park.Get("/", middleware.Allow("parks.read", roleOperator, roleAccountant), parkcontrol.GetParks)
+	park.Get("/:id", middleware.Allow("parks.read", roleOperator, roleAccountant), parkcontrol.GetPark)
+	park.Put("/:id", middleware.Allow("parks"), parkcontrol.UpdatePark)
+	park.Delete("/:id", middleware.Allow("parks"), parkcontrol.DeletePark)
+	park.Put("/:id/cameras", middleware.Allow("parks"), parkcontrol.SetParkCameras)
 }
@@ -9,9 +9,20 @@ import (
 
 func AuthRoute(app *fiber.App) {
 	auth := app.Group("/api/v1/auth")
-	auth.Post("/register", usercontrol.Register)
+	auth.Post("/register", usercontrol.FirstUserOr(middleware.Allow("users")), usercontrol.Register)
 	auth.Post("/login", usercontrol.Login)
-	auth.Post("/logout", middleware.Auth, usercontrol.Logout)
-	auth.Get("/me", middleware.Auth, usercontrol.Me)
+	auth.Post("/refresh", usercontrol.Refresh)
+	auth.Post("/password", usercontrol.ChangePassword)
+	auth.Post("/2fa/challenge", usercontrol.Challenge)
+	auth.Post("/2fa/challenge/setup", usercontrol.ChallengeSetup)
+	auth.Post("/logout", middleware.Allow("session", roleOperator, roleAccountant), usercontrol.Logout)
+	auth.Get("/me", middleware.Allow("session", roleOperator, roleAccountant), usercontrol.Me)
+	auth.Get("/sessions", middleware.Allow("session", roleOperator, roleAccountant), usercontrol.GetSessions)
+	auth.Delete("/sessions/:id", middleware.Allow("session", roleOperator, roleAccountant), usercontrol.DeleteSession)
+	auth.Get("/2fa", middleware.Allow("session", roleOperator, roleAccountant), usercontrol.GetTwoFactor)
+	auth.Post("/2fa/setup", middleware.Allow("session", roleOperator, roleAccountant), usercontrol.SetupTwoFactor)
+	auth.Post("/2fa/enable", middleware.Allow("session", roleOperator, roleAccountant), usercontrol.EnableTwoFactor)
+	auth.Post("/2fa/disable", middleware.Allow("session", roleOperator, roleAccountant), usercontrol.DisableTwoFactor)
+	auth.Post("/2fa/recovery-codes", middleware.Allow("session", roleOperator, roleAccountant), usercontrol.RegenerateRecoveryCodes)
 
 }
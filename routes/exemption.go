@@ -0,0 +1,27 @@
+package routes
+
+import (
+	exemptioncontrol "park/controller/exemptionControl"
+	"park/controller/operator"
+	"park/middleware"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// InitExemptions adds the exemption catalog and the supervisor approval of
+// free exits. Only admins approve until the permission matrix grants
+// exemptions.approve to another role.
+func InitExemptions(app *fiber.App) {
+	ex := app.Group("/api/v1/exemptions")
+	ex.Get("/", middleware.Allow("exemptions.read", roleAccountant), exemptioncontrol.GetExemptions)
+	ex.Get("/reasons", middleware.Allow("exemptions.reasons.read", roleOperator, roleAccountant), exemptioncontrol.GetReasons)
+	ex.Post("/reasons", middleware.Allow("exemptions.reasons"), exemptioncontrol.CreateReason)
+	ex.Put("/reasons/:code", middleware.Allow("exemptions.reasons"), exemptioncontrol.UpdateReason)
+	ex.Post("/requests", middleware.Allow("exemptions.request", roleOperator), exemptioncontrol.RequestExemption)
+	ex.Put("/requests/:id/approve", middleware.Allow(operator.ApprovePermission), exemptioncontrol.ApproveExemption)
+	ex.Put("/requests/:id/reject", middleware.Allow(operator.ApprovePermission), exemptioncontrol.RejectExemption)
+	ex.Put("/pin", middleware.Allow(operator.ApprovePermission), exemptioncontrol.SetPin)
+	ex.Get("/ws", middleware.Allow("exemptions.request", roleOperator),
+		exemptioncontrol.Upgrade, websocket.New(exemptioncontrol.Ws))
+}
@@ -2,16 +2,18 @@ package routes
 
 import (
 	camfix "park/controller/camFix"
+	"park/middleware"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 func FixRoute(app *fiber.App) {
-	app.Post("/api/v1/addcam", camfix.AddCam)
-	app.Get("/api/v1/cams", camfix.GetAllCams)
-	app.Put("/api/v1/update-channel-ids", camfix.UpdateChannelIdsByChannelName)
-	app.Put("/api/v1/updatemac", camfix.UpdateMacUser)
-	app.Patch("/api/v1/type/:id", camfix.UpdateCamera)
-	app.Delete("/api/v1/deletecam/:id", camfix.DeleteCam)
-	app.Get("/api/v1/sync-camfix", camfix.SyncCamFixWithConfig)
+	app.Post("/api/v1/addcam", middleware.Allow("cameras"), camfix.AddCam)
+	app.Get("/api/v1/cams", middleware.Allow("cameras"), camfix.GetAllCams)
+	app.Put("/api/v1/update-channel-ids", middleware.Allow("cameras"), camfix.UpdateChannelIdsByChannelName)
+	app.Put("/api/v1/updatemac", middleware.Allow("cameras"), camfix.UpdateMacUser)
+	app.Patch("/api/v1/type/:id", middleware.Allow("cameras"), camfix.UpdateCamera)
+	app.Delete("/api/v1/deletecam/:id", middleware.Allow("cameras"), camfix.DeleteCam)
+	app.Get("/api/v1/sync-camfix", middleware.Allow("cameras"), camfix.SyncCamFixWithConfig)
+	app.Get("/api/v1/camera-proxy/:channelId/:action", middleware.Allow("cameras.view", roleOperator, roleAccountant), camfix.CameraProxy)
 }
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	gatecontrol "park/controller/gateControl"
+	"park/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitGate(app *fiber.App) {
+	gates := app.Group("/api/v1/gates")
+	gates.Post("/", middleware.Allow("gates"), gatecontrol.CreateGate)
+	gates.Get("/", middleware.Allow("gates.read", roleOperator), gatecontrol.GetGates)
+	gates.Put("/:id", middleware.Allow("gates"), gatecontrol.UpdateGate)
+	gates.Delete("/:id", middleware.Allow("gates"), gatecontrol.DeleteGate)
+	gates.Post("/:id/open", middleware.Allow("gates.open", roleOperator), gatecontrol.OpenGate)
+	gates.Get("/:id/events", middleware.Allow("gates.read", roleOperator), gatecontrol.GetGateEvents)
+}
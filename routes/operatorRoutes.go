@@ -9,8 +9,9 @@ import (
 
 func Init(app *fiber.App) {
 	cars := app.Group("/api/v1")
-	cars.Get("/getallcars", middleware.Auth, operator.GetCars)
-	cars.Get("/getcar/:id", middleware.Auth, operator.GetCar)
-	cars.Get("/searchcar", middleware.Auth, operator.SearchCar)
+	cars.Get("/getallcars", middleware.Allow("cars.read", roleOperator, roleAccountant), operator.GetCars)
+	cars.Get("/getcar/:id", middleware.Allow("cars.read", roleOperator, roleAccountant), operator.GetCar)
+	cars.Get("/searchcar", middleware.Allow("cars.read", roleOperator, roleAccountant), operator.SearchCar)
+	cars.Get("/searchcar/export", middleware.Allow("cars.read", roleOperator, roleAccountant), operator.ExportCars)
 
 }
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"park/controller/payments"
+	"park/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitPayments(app *fiber.App) {
+	pay := app.Group("/api/v1/payments")
+	pay.Get("/", middleware.Allow("payments.read", roleOperator, roleAccountant), payments.GetPayments)
+	pay.Get("/export", middleware.Allow("payments.read", roleOperator, roleAccountant), payments.ExportPayments)
+	pay.Post("/", middleware.Allow("payments", roleOperator), payments.CreatePayment)
+	pay.Post("/:id/refund", middleware.Allow("payments.reverse", roleAccountant), payments.RefundPayment)
+	pay.Post("/:id/void", middleware.Allow("payments.reverse", roleAccountant), payments.VoidPayment)
+}
@@ -1,13 +1,22 @@
 package routes
 
 import (
+	"park/controller/occupancy"
 	"park/controller/realtime"
+	"park/middleware"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 )
 
 func InitRealtime(app *fiber.App) {
-	app.Put("/api/v1/update/count", realtime.UpdateCount)
-	app.Get("/api/v1/update/count", websocket.New(realtime.HandleWebSocketCount), realtime.GetAllCounts)
+	app.Put("/api/v1/update/count", middleware.Allow("occupancy", roleOperator), realtime.UpdateCount)
+	app.Get("/api/v1/update/count", middleware.Allow("occupancy.read", roleOperator, roleAccountant),
+		websocket.New(realtime.HandleWebSocketCount), realtime.GetAllCounts)
+
+	app.Get("/api/v1/occupancy/ws", middleware.Allow("occupancy.read", roleOperator, roleAccountant),
+		occupancy.OccupancyUpgrade, websocket.New(occupancy.Ws))
+	app.Get("/api/v1/occupancy", middleware.Allow("occupancy.read", roleOperator, roleAccountant), occupancy.GetOccupancy)
+	app.Get("/api/v1/occupancy/:code", middleware.Allow("occupancy.read", roleOperator, roleAccountant), occupancy.GetParkOccupancy)
+	app.Put("/api/v1/occupancy/:code", middleware.Allow("occupancy", roleOperator), occupancy.AdjustOccupancy)
 }
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	modelsuser "park/models/modelsUser"
+)
+
+// Admins hold every permission, so only the other roles are listed where
+// routes declare who may use them.
+const (
+	roleOperator   = modelsuser.OperatorRole
+	roleAccountant = modelsuser.AccountantRole
+)
@@ -12,14 +12,18 @@ import (
 
 func CameraRoutes(app *fiber.App) {
 
-	app.Get("/ws/notification", websocket.New(operator.Ws))
+	app.Get("/ws/notification", middleware.Allow("cars.read", roleOperator, roleAccountant), operator.Upgrade, websocket.New(operator.Ws))
 
 	plate := os.Getenv("IMAGE_URL")
+	app.Use("/plate", middleware.Allow("cars.read", roleOperator, roleAccountant))
 	app.Static("/plate", plate)
 
 	camera := app.Group("/api/v1/camera")
-	camera.Post("/getdata", getdata.CreateCarEntry)
-	camera.Put("/getdata", getdata.CreateCarExit)
-	camera.Put("/getdata/nows", getdata.CreateCarExitNoWs)
-	camera.Put("/updatecar/:plate", middleware.Auth, operator.UpdateCar)
+	camera.Post("/getdata", getdata.Journal, getdata.Webhook, getdata.Idempotent, getdata.CreateCarEntry)
+	camera.Put("/getdata", getdata.Journal, getdata.Webhook, getdata.Idempotent, getdata.CreateCarExit)
+	camera.Put("/getdata/nows", getdata.Journal, getdata.Webhook, getdata.Idempotent, getdata.CreateCarExitNoWs)
+	camera.Get("/journal/export", middleware.Allow("journal"), getdata.ExportJournal)
+	camera.Put("/updatecar/:plate", middleware.Allow("cars.exit", roleOperator), operator.UpdateCar)
+	camera.Put("/rejectmatch/:id", middleware.Allow("cars.exit", roleOperator), operator.RejectMatch)
+	camera.Put("/lostticket/:id", middleware.Allow("cars.exit", roleOperator), getdata.SetLostTicketEntry)
 }
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"park/middleware"
+)
+
+// Register adds every route of the API to app. Mutating requests are
+// written to the audit trail.
+func Register(app *fiber.App) {
+	app.Use(middleware.Audit)
+	AuthRoute(app)
+	InitAdminRoute(app)
+	CameraRoutes(app)
+	AccountantRoutes(app)
+	InitZreport(app)
+	InitRealtime(app)
+	FixRoute(app)
+	InitGate(app)
+	InitPayments(app)
+	InitShifts(app)
+	InitExemptions(app)
+	Init(app)
+}
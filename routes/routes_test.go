@@ -0,0 +1,161 @@
+package routes
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"park/controller/getdata"
+	"park/middleware"
+	modelsuser "park/models/modelsUser"
+	"park/util"
+)
+
+// public lists the routes that can be used without a token, by method and
+// path.
+var public = map[string]bool{
+	"POST /api/v1/auth/login":               true,
+	"POST /api/v1/auth/register":            true, // open until the first user exists
+	"POST /api/v1/auth/refresh":             true, // uses the refresh token cookie
+	"POST /api/v1/auth/password":            true, // checks the current password
+	"POST /api/v1/auth/2fa/challenge":       true, // second step of a login
+	"POST /api/v1/auth/2fa/challenge/setup": true,
+	"POST /api/v1/camera/getdata":           true, // camera webhooks, see TestWebhooksRequireCameraToken
+	"PUT /api/v1/camera/getdata":            true,
+	"PUT /api/v1/camera/getdata/nows":       true,
+}
+
+// webhooks are the public routes that take the camera token instead.
+var webhooks = []string{
+	"POST /api/v1/camera/getdata",
+	"PUT /api/v1/camera/getdata",
+	"PUT /api/v1/camera/getdata/nows",
+}
+
+func newApp() *fiber.App {
+	app := fiber.New()
+	Register(app)
+	return app
+}
+
+// path fills the parameters of a route path.
+func path(route string) string {
+	parts := strings.Split(route, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") {
+			parts[i] = "1"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func TestEveryRouteRequiresToken(t *testing.T) {
+	app := newApp()
+
+	// Mounts added with Use and Static are listed too. The root mount only
+	// holds global middleware such as the audit trail.
+	routes := app.GetRoutes(false)
+	if len(routes) == 0 {
+		t.Fatal("no routes registered")
+	}
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		if public[key] || route.Path == "/" || len(route.Handlers) == 0 {
+			continue
+		}
+
+		resp, err := app.Test(httptest.NewRequest(route.Method, path(route.Path), nil), -1)
+		if err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		if resp.StatusCode != fiber.StatusUnauthorized {
+			t.Errorf("%s without a token returned %d, want 401", key, resp.StatusCode)
+		}
+	}
+}
+
+func TestPlateImagesRequireToken(t *testing.T) {
+	t.Setenv("IMAGE_URL", t.TempDir())
+	app := newApp()
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/plate/BE5084AG.jpg", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("plate image without a token returned %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestWebhooksRequireCameraToken(t *testing.T) {
+	t.Setenv("CAMERA_WEBHOOK_TOKEN", "camera-secret")
+	app := newApp()
+
+	for _, key := range webhooks {
+		method, target, _ := strings.Cut(key, " ")
+		for _, token := range []string{"", "wrong"} {
+			req := httptest.NewRequest(method, target, nil)
+			if token != "" {
+				req.Header.Set(getdata.WebhookTokenHeader, token)
+			}
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("%s: %v", key, err)
+			}
+			if resp.StatusCode != fiber.StatusUnauthorized {
+				t.Errorf("%s with token %q returned %d, want 401", key, token, resp.StatusCode)
+			}
+		}
+	}
+
+	t.Setenv("CAMERA_WEBHOOK_TOKEN", "")
+	resp, err := app.Test(httptest.NewRequest("POST", "/api/v1/camera/getdata?token=", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusServiceUnavailable {
+		t.Errorf("webhook without a configured token returned %d, want 503", resp.StatusCode)
+	}
+}
+
+func TestRoleWithoutPermissionIsForbidden(t *testing.T) {
+	t.Setenv("SECRET_KEY_JWT", "test")
+	app := newApp()
+
+	token, err := util.CreateJWT(7, "op", modelsuser.OperatorRole, "P4", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range []string{"POST /api/v1/users", "DELETE /api/v1/cameras/1", "PUT /api/v1/permissions/users"} {
+		method, target, _ := strings.Cut(target, " ")
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, target, err)
+		}
+		if resp.StatusCode != fiber.StatusForbidden {
+			t.Errorf("%s %s as operator returned %d, want 403", method, target, resp.StatusCode)
+		}
+	}
+}
+
+func TestAdminHoldsEveryPermission(t *testing.T) {
+	newApp()
+
+	matrix := middleware.Matrix()
+	if len(matrix) == 0 {
+		t.Fatal("no permissions declared")
+	}
+	for name := range matrix {
+		if !middleware.Allowed(name, modelsuser.AdminRole) {
+			t.Errorf("admin does not hold %s", name)
+		}
+	}
+	if middleware.Allowed("users", modelsuser.OperatorRole) {
+		t.Error("operator holds users")
+	}
+}
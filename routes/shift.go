@@ -0,0 +1,18 @@
+package routes
+
+import (
+	shiftcontrol "park/controller/shiftControl"
+	"park/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitShifts(app *fiber.App) {
+	shifts := app.Group("/api/v1/shifts")
+	shifts.Get("/", middleware.Allow("shifts.read", roleAccountant), shiftcontrol.GetShifts)
+	shifts.Get("/export", middleware.Allow("shifts.read", roleAccountant), shiftcontrol.ExportShifts)
+	shifts.Post("/open", middleware.Allow("shifts", roleOperator), shiftcontrol.OpenShift)
+	shifts.Get("/current", middleware.Allow("shifts", roleOperator), shiftcontrol.GetCurrentShift)
+	shifts.Post("/close", middleware.Allow("shifts", roleOperator), shiftcontrol.CloseShift)
+	shifts.Post("/:id/approve", middleware.Allow("shifts.approve", roleAccountant), shiftcontrol.ApproveShift)
+}
@@ -1,11 +1,22 @@
 package routes
 
 import (
+	pdfGenerator "park/controller/pdf"
 	zreport "park/controller/zreport"
+	"park/middleware"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 func InitZreport(app *fiber.App) {
-	app.Post("/zreport", zreport.GetZdata)
+	reports := app.Group("/api/v1/reports")
+	reports.Get("/x", middleware.Allow("shifts", roleOperator), zreport.GetXReport)
+	reports.Get("/x/pdf", middleware.Allow("shifts", roleOperator), zreport.GetXReportPDF)
+	reports.Post("/z", middleware.Allow("shifts", roleOperator), zreport.CreateZReport)
+	reports.Get("/z", middleware.Allow("reports", roleAccountant), zreport.GetZReports)
+	reports.Get("/z/:number", middleware.Allow("reports.read", roleOperator, roleAccountant), zreport.GetZReport)
+	reports.Get("/z/:number/pdf", middleware.Allow("reports.read", roleOperator, roleAccountant), zreport.GetZReportPDF)
+	reports.Get("/pdf/daily", middleware.Allow("reports", roleAccountant), pdfGenerator.DailySummary)
+	reports.Get("/pdf/shifts", middleware.Allow("reports", roleAccountant), pdfGenerator.OperatorShifts)
+	reports.Get("/pdf/vip", middleware.Allow("reports", roleAccountant), pdfGenerator.VIPUsage)
 }
@@ -0,0 +1,295 @@
+// Package analytics aggregates revenue and traffic in SQL. Every report is
+// built for a range and can be compared with the range of the same length
+// right before it.
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	modelscar "park/models/modelsCar"
+	modelpayment "park/models/paymentModel"
+	"park/util/sitetime"
+)
+
+var (
+	ErrInvalidInterval = errors.New("interval must be day, week or month")
+	ErrInvalidGroup    = errors.New("group must be park, operator or method")
+)
+
+// Range is the half-open time range [Start, End) of a report, optionally
+// limited to one park. Days and hours are counted in Zone, the time zone
+// of the park; nil means the site time zone.
+type Range struct {
+	Start  sitetime.Time  `json:"start"`
+	End    sitetime.Time  `json:"end"`
+	ParkNo string         `json:"park_no,omitempty"`
+	Zone   *time.Location `json:"-"`
+}
+
+func (r Range) location() *time.Location {
+	if r.Zone == nil {
+		return sitetime.Location()
+	}
+	return r.Zone
+}
+
+// Previous returns the range of the same length that ends where r starts.
+func (r Range) Previous() Range {
+	length := r.End.Sub(r.Start.Time)
+	return Range{
+		Start:  sitetime.From(r.Start.Add(-length)),
+		End:    r.Start,
+		ParkNo: r.ParkNo,
+		Zone:   r.Zone,
+	}
+}
+
+// Delta compares a value with the one of the previous range. Change is the
+// relative change in percent and is nil when the previous value is zero.
+type Delta struct {
+	Current  float64  `json:"current"`
+	Previous float64  `json:"previous"`
+	Change   *float64 `json:"change"`
+}
+
+func Compare(current, previous float64) Delta {
+	d := Delta{Current: current, Previous: previous}
+	if previous != 0 {
+		change := (current - previous) / previous * 100
+		d.Change = &change
+	}
+	return d
+}
+
+var intervals = map[string]bool{"day": true, "week": true, "month": true}
+
+var groups = map[string]string{
+	"":         "''",
+	"park":     "park_no",
+	"operator": "operator",
+	"method":   "method",
+}
+
+// period returns the SQL expression of the start of the interval column
+// falls in, as a local date in loc.
+func period(interval, column string, loc *time.Location) (string, error) {
+	if !intervals[interval] {
+		return "", ErrInvalidInterval
+	}
+	return fmt.Sprintf("TO_CHAR(DATE_TRUNC('%s', %s AT TIME ZONE '%s'), 'YYYY-MM-DD')",
+		interval, column, loc.String()), nil
+}
+
+// RevenuePoint is the net amount taken in one period for one key of the
+// grouping. Amounts are in minor units.
+type RevenuePoint struct {
+	Period   string `json:"period" example:"2025-01-27"`
+	Key      string `json:"key" example:"cash"`
+	Payments int    `json:"payments"`
+	Amount   int64  `json:"amount"`
+}
+
+// Revenue sums the payments ledger by interval and by park, operator or
+// payment method. An empty group sums everything.
+func Revenue(db *gorm.DB, r Range, interval, group string) ([]RevenuePoint, error) {
+	periodSQL, err := period(interval, "created_at", r.location())
+	if err != nil {
+		return nil, err
+	}
+	key, ok := groups[group]
+	if !ok {
+		return nil, ErrInvalidGroup
+	}
+
+	query := db.Model(&modelpayment.Payment{}).
+		Select(fmt.Sprintf("%s AS period, %s AS key, COUNT(*) FILTER (WHERE kind = ?) AS payments, SUM(amount) AS amount", periodSQL, key),
+			modelpayment.KindPayment).
+		Where("created_at >= ? AND created_at < ?", r.Start, r.End)
+	if r.ParkNo != "" {
+		query = query.Where("park_no = ?", r.ParkNo)
+	}
+
+	var points []RevenuePoint
+	err = query.Group("period, key").Order("period, key").Scan(&points).Error
+	return points, err
+}
+
+// VisitPoint counts the visits that started in one period. AverageStay is
+// in minutes and only covers visits that have exited.
+type VisitPoint struct {
+	Period      string  `json:"period" example:"2025-01-27"`
+	Visits      int     `json:"visits"`
+	Exits       int     `json:"exits"`
+	AverageStay float64 `json:"average_stay"`
+}
+
+func visitQuery(db *gorm.DB, r Range) *gorm.DB {
+	query := db.Model(&modelscar.Car_Model{}).
+		Where("no_entry = ? AND start_time >= ? AND start_time < ?", false, r.Start, r.End)
+	if r.ParkNo != "" {
+		query = query.Where("park_no = ?", r.ParkNo)
+	}
+	return query
+}
+
+const visitColumns = `COUNT(*) AS visits, COUNT(*) FILTER (WHERE status = 'Exited') AS exits,
+	COALESCE(AVG(EXTRACT(EPOCH FROM end_time - start_time) / 60) FILTER (WHERE status = 'Exited'), 0) AS average_stay`
+
+// Visits counts visits and their average stay by interval.
+func Visits(db *gorm.DB, r Range, interval string) ([]VisitPoint, error) {
+	periodSQL, err := period(interval, "start_time", r.location())
+	if err != nil {
+		return nil, err
+	}
+
+	var points []VisitPoint
+	err = visitQuery(db, r).Select(periodSQL + " AS period, " + visitColumns).
+		Group("period").Order("period").Scan(&points).Error
+	return points, err
+}
+
+// VisitTotals counts the visits of the whole range.
+func VisitTotals(db *gorm.DB, r Range) (VisitPoint, error) {
+	var total VisitPoint
+	err := visitQuery(db, r).Select(visitColumns).Scan(&total).Error
+	return total, err
+}
+
+// HourCell is the occupancy at the start of one hour of the week, averaged
+// over the weeks of the range. Weekday is 1 for Monday through 7.
+type HourCell struct {
+	Weekday  int     `json:"weekday"`
+	Hour     int     `json:"hour"`
+	Occupied float64 `json:"occupied"`
+	Peak     int     `json:"peak"`
+}
+
+// hourDelta is the change of occupancy at the start of an hour. Hour counts
+// the hours since the Unix epoch.
+type hourDelta struct {
+	Hour  int64
+	Delta int
+}
+
+// PeakHours counts the cars in the park at the start of every hour of the
+// range and averages them by weekday and hour. A car is in the park from the
+// first hour start at or after its entry until the first one at or after
+// its exit. The database groups these changes by hour; they are summed up
+// hour by hour in hourCells.
+func PeakHours(db *gorm.DB, r Range) ([]HourCell, error) {
+	park := ""
+	args := []interface{}{r.End, r.Start}
+	if r.ParkNo != "" {
+		park = "AND c.park_no = ?"
+		args = append(args, r.ParkNo)
+	}
+	args = append(args, args...)
+
+	var deltas []hourDelta
+	err := db.Raw(fmt.Sprintf(`SELECT hour, SUM(delta) AS delta FROM (
+			SELECT CEIL(EXTRACT(EPOCH FROM c.start_time) / 3600)::bigint AS hour, 1 AS delta
+			FROM car_models c
+			WHERE c.no_entry = false AND c.start_time < ? AND (c.end_time IS NULL OR c.end_time > ?) %[1]s
+			UNION ALL
+			SELECT CEIL(EXTRACT(EPOCH FROM c.end_time) / 3600)::bigint, -1
+			FROM car_models c
+			WHERE c.no_entry = false AND c.start_time < ? AND c.end_time > ? %[1]s
+		) changes
+		GROUP BY hour
+		ORDER BY hour`, park), args...).Scan(&deltas).Error
+	if err != nil {
+		return nil, err
+	}
+	return hourCells(r, deltas), nil
+}
+
+// hourCells sums deltas, ordered by hour, into the occupancy at the start
+// of every hour of r and averages it by weekday and hour in the time zone
+// of r.
+func hourCells(r Range, deltas []hourDelta) []HourCell {
+	type cell struct {
+		weekday, hour int
+	}
+	var (
+		sums   = make(map[cell]int)
+		counts = make(map[cell]int)
+		peaks  = make(map[cell]int)
+		order  []cell
+	)
+
+	occupied := 0
+	next := 0
+	for h := r.Start.Truncate(time.Hour); h.Before(r.End.Time); h = h.Add(time.Hour) {
+		for next < len(deltas) && deltas[next].Hour <= h.Unix()/3600 {
+			occupied += deltas[next].Delta
+			next++
+		}
+
+		local := h.In(r.location())
+		weekday := int(local.Weekday())
+		if weekday == 0 {
+			weekday = 7
+		}
+		k := cell{weekday, local.Hour()}
+		if counts[k] == 0 {
+			order = append(order, k)
+		}
+		sums[k] += occupied
+		counts[k]++
+		peaks[k] = max(peaks[k], occupied)
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		if order[i].weekday != order[j].weekday {
+			return order[i].weekday < order[j].weekday
+		}
+		return order[i].hour < order[j].hour
+	})
+	cells := make([]HourCell, 0, len(order))
+	for _, k := range order {
+		cells = append(cells, HourCell{
+			Weekday:  k.weekday,
+			Hour:     k.hour,
+			Occupied: float64(sums[k]) / float64(counts[k]),
+			Peak:     peaks[k],
+		})
+	}
+	return cells
+}
+
+// FreeExit is the number of exits let out without payment for one reason
+// and their share of all exits, in percent. VIP exits have the reason
+// "VIP".
+type FreeExit struct {
+	Reason string  `json:"reason"`
+	Count  int     `json:"count"`
+	Share  float64 `json:"share"`
+}
+
+// FreeExits groups the exits without payment in the range by reason and
+// returns them with the number of all exits.
+func FreeExits(db *gorm.DB, r Range) ([]FreeExit, int, error) {
+	query := db.Model(&modelscar.Car_Model{}).
+		Where("status = ? AND end_time >= ? AND end_time < ?", "Exited", r.Start, r.End)
+	if r.ParkNo != "" {
+		query = query.Where("park_no = ?", r.ParkNo)
+	}
+
+	var exits int64
+	if err := query.Session(&gorm.Session{}).Count(&exits).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var free []FreeExit
+	err := query.Session(&gorm.Session{}).
+		Select(`CASE WHEN subscription_id IS NOT NULL THEN 'VIP' ELSE reason END AS reason, COUNT(*) AS count,
+			COUNT(*) * 100.0 / ? AS share`, max(exits, 1)).
+		Where("total_payment = 0").
+		Group("1").Order("count DESC").Scan(&free).Error
+	return free, int(exits), err
+}
@@ -0,0 +1,95 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"park/util/sitetime"
+)
+
+// deltasOf groups the entries and exits of visits by hour the way the
+// query of PeakHours does. A zero end is a car that is still inside.
+func deltasOf(visits [][2]time.Time) []hourDelta {
+	sums := make(map[int64]int)
+	ceil := func(t time.Time) int64 { return int64(math.Ceil(float64(t.Unix()) / 3600)) }
+	for _, v := range visits {
+		sums[ceil(v[0])]++
+		if !v[1].IsZero() {
+			sums[ceil(v[1])]--
+		}
+	}
+	var deltas []hourDelta
+	for hour, delta := range sums {
+		deltas = append(deltas, hourDelta{Hour: hour, Delta: delta})
+	}
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i].Hour < deltas[j].Hour })
+	return deltas
+}
+
+func TestHourCellsAveragesWeeks(t *testing.T) {
+	loc := sitetime.Location()
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2025, 1, day, hour, minute, 0, 0, loc)
+	}
+	// Monday the 6th to Monday the 20th: two full weeks.
+	r := Range{Start: sitetime.From(at(6, 0, 0)), End: sitetime.From(at(20, 0, 0))}
+
+	cells := hourCells(r, deltasOf([][2]time.Time{
+		{at(6, 8, 30), at(6, 10, 0)},  // first Monday, inside at 9
+		{at(13, 8, 0), at(13, 9, 30)}, // second Monday, inside at 8 and 9
+		{at(5, 12, 0), {}},            // entered before the range, never left
+	}))
+
+	if len(cells) != 7*24 {
+		t.Fatalf("got %d cells, want one per hour of the week", len(cells))
+	}
+	if cells[0].Weekday != 1 || cells[0].Hour != 0 || cells[len(cells)-1].Weekday != 7 || cells[len(cells)-1].Hour != 23 {
+		t.Fatalf("cells are not ordered by weekday and hour: %+v ... %+v", cells[0], cells[len(cells)-1])
+	}
+
+	want := map[int]HourCell{
+		7:  {Weekday: 1, Hour: 7, Occupied: 1, Peak: 1},
+		8:  {Weekday: 1, Hour: 8, Occupied: 1.5, Peak: 2},
+		9:  {Weekday: 1, Hour: 9, Occupied: 2, Peak: 2},
+		10: {Weekday: 1, Hour: 10, Occupied: 1, Peak: 1},
+	}
+	for i, w := range want {
+		if cells[i] != w {
+			t.Errorf("cell %d = %+v, want %+v", i, cells[i], w)
+		}
+	}
+}
+
+func TestHourCellsEmptyRange(t *testing.T) {
+	now := sitetime.From(sitetime.Now().Truncate(time.Hour))
+	if cells := hourCells(Range{Start: now, End: now}, nil); len(cells) != 0 {
+		t.Fatalf("got %d cells for an empty range", len(cells))
+	}
+}
+
+func TestHourCellsUseParkZone(t *testing.T) {
+	zone := time.FixedZone("UTC+3", 3*3600)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2025, 1, 6, hour, minute, 0, 0, zone)
+	}
+	r := Range{Start: sitetime.From(at(0, 0)), End: sitetime.From(at(0, 0).AddDate(0, 0, 1)), ParkNo: "P4", Zone: zone}
+
+	cells := hourCells(r, deltasOf([][2]time.Time{{at(8, 30), at(10, 0)}}))
+	for _, cell := range cells {
+		want := 0
+		if cell.Hour == 9 {
+			want = 1
+		}
+		if cell.Weekday != 1 || cell.Peak != want {
+			t.Errorf("cell %+v, want Monday with peak %d", cell, want)
+		}
+	}
+	if len(cells) != 24 {
+		t.Fatalf("got %d cells, want the 24 hours of one local day", len(cells))
+	}
+	if prev := r.Previous(); prev.Zone != zone {
+		t.Fatalf("previous range lost the park zone")
+	}
+}
@@ -0,0 +1,275 @@
+// Package audit keeps the audit trail of mutating requests. Entries are
+// chained by hash: each stores the hash of the one before it, and Verify
+// recomputes the chain to find entries that were changed or removed.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	modelaudit "park/models/auditModel"
+)
+
+const localsKey = "audit"
+
+// Change is what a handler tells the audit trail about the entity it
+// changed.
+type Change struct {
+	Entity   string
+	EntityId string
+	Before   modelaudit.JSON
+	After    modelaudit.JSON
+	set      bool
+}
+
+// Before records the state of an entity before the handler changes it. It
+// must be called before the change, since the value is copied at once.
+func Before(c *fiber.Ctx, entity, id string, value interface{}) {
+	change := changeOf(c)
+	change.Entity = entity
+	change.EntityId = id
+	change.Before = encode(value)
+	change.set = true
+}
+
+// After records the state of the entity after the change. A created
+// entity has only After; a deleted one only Before.
+func After(c *fiber.Ctx, entity, id string, value interface{}) {
+	change := changeOf(c)
+	change.Entity = entity
+	change.EntityId = id
+	change.After = encode(value)
+	change.set = true
+}
+
+// Recorded returns the change told by the handler, if any.
+func Recorded(c *fiber.Ctx) (Change, bool) {
+	change, ok := c.Locals(localsKey).(*Change)
+	if !ok || !change.set {
+		return Change{}, false
+	}
+	return *change, true
+}
+
+func changeOf(c *fiber.Ctx) *Change {
+	change, ok := c.Locals(localsKey).(*Change)
+	if !ok {
+		change = &Change{}
+		c.Locals(localsKey, change)
+	}
+	return change
+}
+
+// sensitive lists the parts of field names whose values are never stored.
+var sensitive = []string{"password", "secret", "token"}
+
+// encode renders value as JSON with sensitive fields masked.
+func encode(value interface{}) modelaudit.JSON {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return Redact(raw)
+}
+
+// Redact masks the sensitive fields of a JSON document. Anything that is
+// not a JSON object or array is dropped.
+func Redact(raw []byte) modelaudit.JSON {
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return ""
+	}
+	switch doc.(type) {
+	case map[string]interface{}, []interface{}:
+	default:
+		return ""
+	}
+	out, err := json.Marshal(redact(doc))
+	if err != nil {
+		return ""
+	}
+	return modelaudit.JSON(out)
+}
+
+func redact(doc interface{}) interface{} {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if isSensitive(key) {
+				v[key] = "***"
+			} else {
+				v[key] = redact(value)
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redact(value)
+		}
+	}
+	return doc
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	if key == "pin" {
+		return true
+	}
+	for _, part := range sensitive {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff lists the top level fields that differ between two JSON objects as
+// {"field": {"from": ..., "to": ...}}. It is empty when either side is not
+// an object.
+func Diff(before, after modelaudit.JSON) modelaudit.JSON {
+	var from, to map[string]interface{}
+	if json.Unmarshal([]byte(before), &from) != nil || json.Unmarshal([]byte(after), &to) != nil {
+		return ""
+	}
+
+	diff := make(map[string]interface{})
+	for key, value := range from {
+		if other, ok := to[key]; !ok || !equal(value, other) {
+			diff[key] = map[string]interface{}{"from": value, "to": to[key]}
+		}
+	}
+	for key, value := range to {
+		if _, ok := from[key]; !ok {
+			diff[key] = map[string]interface{}{"from": nil, "to": value}
+		}
+	}
+	if len(diff) == 0 {
+		return ""
+	}
+	out, err := json.Marshal(diff)
+	if err != nil {
+		return ""
+	}
+	return modelaudit.JSON(out)
+}
+
+func equal(a, b interface{}) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
+
+var appendMu sync.Mutex
+
+// Append chains entry to the last entry and stores it.
+func Append(db *gorm.DB, entry *modelaudit.Entry) error {
+	// The database keeps microseconds; the hash must be over what is kept.
+	entry.At.Time = entry.At.Truncate(time.Microsecond)
+
+	appendMu.Lock()
+	defer appendMu.Unlock()
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Other servers append to the same chain.
+		if err := tx.Exec("LOCK TABLE audit_entries IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+		var last modelaudit.Entry
+		if err := tx.Select("hash").Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+			return err
+		}
+		entry.PrevHash = last.Hash
+		entry.Hash = Hash(*entry)
+		return tx.Create(entry).Error
+	})
+}
+
+// Hash computes the hash of an entry from its content and PrevHash.
+func Hash(entry modelaudit.Entry) string {
+	content, _ := json.Marshal([]interface{}{
+		entry.PrevHash,
+		entry.At.UTC().Format(time.RFC3339Nano),
+		entry.Actor,
+		entry.Role,
+		entry.IP,
+		entry.Method,
+		entry.Path,
+		entry.Action,
+		entry.Entity,
+		entry.EntityId,
+		entry.Status,
+		string(entry.Before),
+		string(entry.After),
+		string(entry.Diff),
+	})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verification is the result of checking the chain.
+type Verification struct {
+	Valid    bool   `json:"valid"`
+	Checked  int    `json:"checked"`
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	LastHash string `json:"last_hash"`
+}
+
+// Verify walks the chain from the first entry and stops at the first one
+// whose hash or link is wrong. Removing the newest entries cannot be seen
+// from the chain alone; comparing LastHash with a copy kept elsewhere
+// shows it.
+func Verify(db *gorm.DB) (Verification, error) {
+	var chain Chain
+	var batch []modelaudit.Entry
+	err := db.FindInBatches(&batch, 1000, func(tx *gorm.DB, _ int) error {
+		if !chain.Check(batch) {
+			return errStop
+		}
+		return nil
+	}).Error
+	if errors.Is(err, errStop) {
+		err = nil
+	}
+	return chain.Result(), err
+}
+
+var errStop = errors.New("stop")
+
+// Chain checks entries in the order they were appended, one batch after
+// another. The zero value expects the first entry of the trail.
+type Chain struct {
+	result Verification
+	broken bool
+}
+
+// Check checks the next entries of the chain. It returns false once an
+// entry was changed, or does not link to the entry before it because an
+// entry in between was removed.
+func (ch *Chain) Check(entries []modelaudit.Entry) bool {
+	if ch.broken {
+		return false
+	}
+	for _, entry := range entries {
+		if entry.PrevHash != ch.result.LastHash || Hash(entry) != entry.Hash {
+			ch.broken = true
+			ch.result.BrokenAt = entry.Id
+			return false
+		}
+		ch.result.Checked++
+		ch.result.LastHash = entry.Hash
+	}
+	return true
+}
+
+// Result returns the outcome of the entries checked so far.
+func (ch *Chain) Result() Verification {
+	result := ch.result
+	result.Valid = !ch.broken
+	return result
+}
@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	modelaudit "park/models/auditModel"
+	"park/util/sitetime"
+)
+
+func TestRedact(t *testing.T) {
+	got := Redact([]byte(`{"username":"bob","password":"x","Pin":"1234","cars":[{"refresh_token":"t"}]}`))
+	want := modelaudit.JSON(`{"Pin":"***","cars":[{"refresh_token":"***"}],"password":"***","username":"bob"}`)
+	if got != want {
+		t.Fatalf("Redact = %s, want %s", got, want)
+	}
+	if got := Redact([]byte(`"text"`)); got != "" {
+		t.Fatalf("Redact of a string = %s, want empty", got)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	got := Diff(`{"a":1,"b":2,"c":3}`, `{"a":1,"b":5,"d":4}`)
+	want := modelaudit.JSON(`{"b":{"from":2,"to":5},"c":{"from":3,"to":null},"d":{"from":null,"to":4}}`)
+	if got != want {
+		t.Fatalf("Diff = %s, want %s", got, want)
+	}
+	if got := Diff(`{"a":1}`, `{"a":1}`); got != "" {
+		t.Fatalf("Diff of equal objects = %s, want empty", got)
+	}
+}
+
+func TestHashChain(t *testing.T) {
+	first := modelaudit.Entry{At: sitetime.From(time.Date(2025, 1, 29, 10, 0, 0, 0, time.UTC)), Actor: "admin", Action: "DELETE /api/v1/users/:id"}
+	first.Hash = Hash(first)
+	second := modelaudit.Entry{At: first.At, Actor: "admin", Action: "POST /api/v1/tarifs", PrevHash: first.Hash}
+	second.Hash = Hash(second)
+
+	tampered := first
+	tampered.Actor = "operator"
+	if Hash(tampered) == first.Hash {
+		t.Fatal("changing an entry kept its hash")
+	}
+	relinked := second
+	relinked.PrevHash = ""
+	if Hash(relinked) == second.Hash {
+		t.Fatal("changing the link kept the hash")
+	}
+}
+
+func chain(n int) []modelaudit.Entry {
+	entries := make([]modelaudit.Entry, n)
+	prev := ""
+	for i := range entries {
+		entries[i] = modelaudit.Entry{Id: int64(i + 1), At: sitetime.From(time.Date(2025, 1, 29, 10, i, 0, 0, time.UTC)), Actor: "admin", Action: "PUT /api/v1/parks/:id", PrevHash: prev}
+		entries[i].Hash = Hash(entries[i])
+		prev = entries[i].Hash
+	}
+	return entries
+}
+
+func TestChainAcceptsIntactTrail(t *testing.T) {
+	entries := chain(5)
+	var ch Chain
+	if !ch.Check(entries[:2]) || !ch.Check(entries[2:]) {
+		t.Fatal("intact trail reported broken")
+	}
+	got := ch.Result()
+	if !got.Valid || got.Checked != 5 || got.LastHash != entries[4].Hash || got.BrokenAt != 0 {
+		t.Fatalf("Result = %+v", got)
+	}
+}
+
+func TestChainFindsTamperedRow(t *testing.T) {
+	entries := chain(5)
+	entries[2].Actor = "operator"
+	var ch Chain
+	if ch.Check(entries) {
+		t.Fatal("tampered trail reported intact")
+	}
+	got := ch.Result()
+	if got.Valid || got.BrokenAt != 3 || got.Checked != 2 {
+		t.Fatalf("Result = %+v, want broken at 3 after 2 checked", got)
+	}
+	if ch.Check(chain(1)) {
+		t.Fatal("broken chain accepted more entries")
+	}
+}
+
+func TestChainFindsRemovedRow(t *testing.T) {
+	entries := chain(5)
+	entries = append(entries[:2], entries[3:]...)
+	var ch Chain
+	if !ch.Check(entries[:2]) {
+		t.Fatal("entries before the gap reported broken")
+	}
+	if ch.Check(entries[2:]) {
+		t.Fatal("trail with a removed row reported intact")
+	}
+	if got := ch.Result(); got.Valid || got.BrokenAt != 4 {
+		t.Fatalf("Result = %+v, want broken at 4", got)
+	}
+}
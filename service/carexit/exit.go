@@ -0,0 +1,292 @@
+// Package carexit implements the exit pipeline of a car visit: it finds the
+// open visit of a plate, prices the stay, applies VIP subscriptions, stores
+// the result and runs post-actions such as websocket broadcasts.
+//
+// Every exit path (camera endpoints, replay, manual operator exits) goes
+// through Service.Exit so that pricing and state changes stay identical.
+package carexit
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	modelscar "park/models/modelsCar"
+	"park/models/tarif"
+	"park/util/plate"
+	"park/util/sitetime"
+)
+
+const (
+	StatusInside  = "Inside"
+	StatusPending = "Pending"
+	StatusExited  = "Exited"
+)
+
+var (
+	ErrNotFound      = errors.New("car not found")
+	ErrAlreadyExited = errors.New("car already exited")
+	ErrWrongPark     = errors.New("car is not in the right park")
+	ErrBeforeEntry   = errors.New("exit is earlier than the entry of the visit")
+	ErrHasEntry      = errors.New("visit already has an entry")
+)
+
+// Store persists visits for the exit pipeline.
+type Store interface {
+	// LatestVisit returns the newest visit of plate or ErrNotFound.
+	LatestVisit(plate string) (modelscar.Car_Model, error)
+	// OpenVisits returns the visits of a park that are inside or pending.
+	OpenVisits(parkNo string) ([]modelscar.Car_Model, error)
+	// SaveExit stores the exit fields of a priced visit.
+	SaveExit(car *modelscar.Car_Model) error
+	// CreateVisit stores a new visit and sets its ID.
+	CreateVisit(car *modelscar.Car_Model) error
+}
+
+// Pricer returns the fee of a stay from start to end.
+type Pricer func(start, end time.Time) (float64, error)
+
+// SubscriptionFinder returns the VIP subscription of plate valid at a time.
+type SubscriptionFinder func(plate string, at time.Time) (tarif.Tarif, bool)
+
+// PostAction runs after an exit has been stored. Errors are logged and do
+// not undo the exit.
+type PostAction interface {
+	AfterExit(res *Result) error
+}
+
+// PostActionFunc adapts a function to PostAction.
+type PostActionFunc func(res *Result) error
+
+func (f PostActionFunc) AfterExit(res *Result) error {
+	return f(res)
+}
+
+// Request describes one exit event.
+type Request struct {
+	Plate       string
+	ChannelName string
+	ChannelId   string
+	Reason      string
+	// ParkNo is the park of the exit camera. With CheckPark set the
+	// visit must belong to it.
+	ParkNo    string
+	CheckPark bool
+	// At is the exit time; zero means Service.Now.
+	At time.Time
+}
+
+// Result is the outcome of an exit.
+type Result struct {
+	Request      Request
+	Car          modelscar.Car_Model
+	Subscription *tarif.Tarif
+}
+
+type Service struct {
+	Store         Store
+	Price         Pricer
+	Subscriptions SubscriptionFinder
+	Now           func() time.Time
+	// MaxPlateDistance enables matching a misread plate to the closest
+	// open visit of the park. Zero disables fuzzy matching.
+	MaxPlateDistance int
+	// LostTicketFee prices exits that have no visit at all. When it is set
+	// such exits create a NoEntry visit instead of failing with ErrNotFound.
+	LostTicketFee func(at time.Time) (float64, error)
+	// Actions run after every exit, before the per-call actions.
+	Actions []PostAction
+}
+
+// Exit prices and stores the exit of req.Plate and runs the post-actions.
+// On ErrWrongPark the result still carries the visit that was found.
+//
+// An exit later than the end of the plate's last, exited visit belongs to
+// a new visit whose entry has not arrived yet, so it is handled like an
+// exit without any visit. Only an exit that is not later is refused with
+// ErrAlreadyExited, as a repeat of the one already stored.
+func (s *Service) Exit(req Request, actions ...PostAction) (*Result, error) {
+	number := plate.Normalize(req.Plate)
+	car, err := s.Store.LatestVisit(number)
+	if err == nil && car.Status == StatusExited && !car.End_time.IsZero() && s.exitTime(req).After(car.End_time.Time) {
+		err = ErrNotFound
+	}
+	fuzzy := false
+	if errors.Is(err, ErrNotFound) && s.MaxPlateDistance > 0 && req.ParkNo != "" {
+		car, err = s.closestOpenVisit(number, req.ParkNo)
+		fuzzy = err == nil
+	}
+	if errors.Is(err, ErrNotFound) && s.LostTicketFee != nil {
+		return s.lostTicket(number, req, actions)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if car.Status == StatusExited {
+		return &Result{Request: req, Car: car}, ErrAlreadyExited
+	}
+
+	endTime := s.exitTime(req)
+	startTime := car.Start_time.Time
+	if startTime.IsZero() {
+		return nil, fmt.Errorf("car %d has no start time", car.ID)
+	}
+	if endTime.Before(startTime) {
+		return &Result{Request: req, Car: car}, ErrBeforeEntry
+	}
+	car.Duration = int(endTime.Sub(startTime).Minutes())
+
+	fee, err := s.Price(startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate fee: %w", err)
+	}
+	car.Total_payment = fee
+
+	res := &Result{Request: req}
+	s.applySubscription(res, &car, endTime)
+	markExit(&car, req, endTime)
+	car.FuzzyMatch = fuzzy
+	res.Car = car
+
+	if req.CheckPark && car.ParkNo != req.ParkNo {
+		return res, ErrWrongPark
+	}
+
+	if err := s.Store.SaveExit(&res.Car); err != nil {
+		return nil, err
+	}
+
+	s.runActions(res, actions)
+	return res, nil
+}
+
+// lostTicket records an exit without any visit as a NoEntry visit that is
+// charged the lost ticket fee until its entry time becomes known.
+func (s *Service) lostTicket(number string, req Request, actions []PostAction) (*Result, error) {
+	endTime := s.exitTime(req)
+	fee, err := s.LostTicketFee(endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate lost ticket fee: %w", err)
+	}
+
+	car := modelscar.Car_Model{
+		Car_number:    number,
+		ParkNo:        req.ParkNo,
+		NoEntry:       true,
+		Total_payment: fee,
+		PayStatus:     true,
+	}
+	res := &Result{Request: req}
+	s.applySubscription(res, &car, endTime)
+	markExit(&car, req, endTime)
+
+	if err := s.Store.CreateVisit(&car); err != nil {
+		return nil, err
+	}
+	res.Car = car
+
+	s.runActions(res, actions)
+	return res, nil
+}
+
+// SetEntry gives a NoEntry visit its entry time and prices it as a normal
+// stay. An estimated entry keeps the visit flagged as NoEntry; an entry
+// seen by a camera clears the flag.
+func (s *Service) SetEntry(car modelscar.Car_Model, start time.Time, estimated bool) (modelscar.Car_Model, error) {
+	if !car.NoEntry {
+		return car, ErrHasEntry
+	}
+	if car.Status == StatusExited {
+		return car, ErrAlreadyExited
+	}
+
+	start = start.Truncate(time.Second)
+	end := car.End_time.Time
+	if end.Before(start) {
+		return car, ErrBeforeEntry
+	}
+
+	fee, err := s.Price(start, end)
+	if err != nil {
+		return car, fmt.Errorf("failed to calculate fee: %w", err)
+	}
+	car.Start_time = sitetime.From(start)
+	car.Duration = int(end.Sub(start).Minutes())
+	car.Total_payment = fee
+	if car.SubscriptionId != nil {
+		car.Total_payment = 0
+	}
+	car.NoEntry = estimated
+	car.EntryEstimated = estimated
+
+	if err := s.Store.SaveExit(&car); err != nil {
+		return car, err
+	}
+	return car, nil
+}
+
+func (s *Service) exitTime(req Request) time.Time {
+	endTime := req.At
+	if endTime.IsZero() {
+		endTime = s.now()
+	}
+	return endTime.Truncate(time.Second)
+}
+
+// applySubscription waives the fee of a car with a valid subscription.
+func (s *Service) applySubscription(res *Result, car *modelscar.Car_Model, at time.Time) {
+	car.SubscriptionId = nil
+	if s.Subscriptions == nil {
+		return
+	}
+	if sub, ok := s.Subscriptions(car.Car_number, at); ok {
+		car.Total_payment = 0
+		car.SubscriptionId = &sub.Id
+		res.Subscription = &sub
+	}
+}
+
+func markExit(car *modelscar.Car_Model, req Request, endTime time.Time) {
+	car.Status = StatusPending
+	car.End_time = sitetime.From(endTime)
+	car.Reason = req.Reason
+	car.CameraID = req.ChannelName
+	car.CamToken = req.ChannelId
+	car.ExitPlate = req.Plate
+}
+
+func (s *Service) runActions(res *Result, actions []PostAction) {
+	for _, action := range append(append([]PostAction{}, s.Actions...), actions...) {
+		if err := action.AfterExit(res); err != nil {
+			log.Println("Exit post-action failed for", res.Car.Car_number, "-", err)
+		}
+	}
+}
+
+// closestOpenVisit proposes the open visit of the park whose plate is the
+// nearest to a plate that has no visit of its own.
+func (s *Service) closestOpenVisit(number, parkNo string) (modelscar.Car_Model, error) {
+	visits, err := s.Store.OpenVisits(parkNo)
+	if err != nil {
+		return modelscar.Car_Model{}, err
+	}
+
+	plates := make([]string, len(visits))
+	for i, visit := range visits {
+		plates[i] = visit.Car_number
+	}
+	i, _, ok := plate.Closest(number, plates, s.MaxPlateDistance)
+	if !ok {
+		return modelscar.Car_Model{}, ErrNotFound
+	}
+	return visits[i], nil
+}
+
+func (s *Service) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
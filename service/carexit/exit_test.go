@@ -0,0 +1,335 @@
+package carexit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	modelscar "park/models/modelsCar"
+	"park/models/tarif"
+	"park/util/sitetime"
+)
+
+var testNow = time.Date(2025, 1, 29, 14, 0, 0, 0, time.UTC)
+
+func visit(id int, plate, status, park string, start time.Time) modelscar.Car_Model {
+	return modelscar.Car_Model{
+		ID:         id,
+		Car_number: plate,
+		Status:     status,
+		ParkNo:     park,
+		Start_time: sitetime.From(start),
+	}
+}
+
+func flatPrice(start, end time.Time) (float64, error) {
+	return end.Sub(start).Hours(), nil
+}
+
+func newService(store Store, subs ...tarif.Tarif) *Service {
+	return &Service{
+		Store: store,
+		Price: flatPrice,
+		Subscriptions: func(plate string, at time.Time) (tarif.Tarif, bool) {
+			for _, sub := range subs {
+				if sub.Plate == plate && !at.Before(sub.Start_time) && !at.After(sub.End_time) {
+					return sub, true
+				}
+			}
+			return tarif.Tarif{}, false
+		},
+		Now: func() time.Time { return testNow },
+	}
+}
+
+func TestExitPricesLatestVisit(t *testing.T) {
+	store := NewMemoryStore(
+		visit(1, "BE5084AG", StatusExited, "P4", testNow.Add(-48*time.Hour)),
+		visit(2, "BE5084AG", StatusInside, "P4", testNow.Add(-3*time.Hour)),
+	)
+
+	res, err := newService(store).Exit(Request{
+		Plate:       "BE5084AG",
+		ChannelName: "P4-6",
+		ChannelId:   "channel",
+		Reason:      "waiting",
+		ParkNo:      "P4",
+	})
+	if err != nil {
+		t.Fatalf("Exit returned error: %v", err)
+	}
+
+	car, _ := store.Visit(2)
+	if car.Status != StatusPending || car.Total_payment != 3 || car.Duration != 180 {
+		t.Fatalf("unexpected stored visit: %+v", car)
+	}
+	if !car.End_time.Equal(testNow) || car.Reason != "waiting" || car.CameraID != "P4-6" {
+		t.Fatalf("unexpected exit fields: %+v", car)
+	}
+	if res.Car != car {
+		t.Fatalf("result does not match stored visit: %+v != %+v", res.Car, car)
+	}
+}
+
+func TestExitUsesRequestTime(t *testing.T) {
+	store := NewMemoryStore(visit(1, "AG1234AG", StatusInside, "P1", testNow.Add(-5*time.Hour)))
+
+	res, err := newService(store).Exit(Request{Plate: "AG1234AG", At: testNow.Add(-4 * time.Hour)})
+	if err != nil {
+		t.Fatalf("Exit returned error: %v", err)
+	}
+	if res.Car.Duration != 60 || res.Car.Total_payment != 1 {
+		t.Fatalf("unexpected visit: %+v", res.Car)
+	}
+}
+
+func TestExitAppliesValidSubscription(t *testing.T) {
+	sub := tarif.Tarif{
+		Id:         7,
+		Plate:      "AG1234AG",
+		Start_time: testNow.Add(-24 * time.Hour),
+		End_time:   testNow.Add(24 * time.Hour),
+	}
+	store := NewMemoryStore(visit(1, "AG1234AG", StatusInside, "P1", testNow.Add(-2*time.Hour)))
+
+	res, err := newService(store, sub).Exit(Request{Plate: "AG1234AG"})
+	if err != nil {
+		t.Fatalf("Exit returned error: %v", err)
+	}
+	if res.Car.Total_payment != 0 || res.Car.SubscriptionId == nil || *res.Car.SubscriptionId != 7 {
+		t.Fatalf("subscription was not applied: %+v", res.Car)
+	}
+	if res.Subscription == nil || res.Subscription.Id != 7 {
+		t.Fatalf("result does not carry the subscription: %+v", res.Subscription)
+	}
+}
+
+func TestExitIgnoresExpiredSubscription(t *testing.T) {
+	sub := tarif.Tarif{
+		Id:         7,
+		Plate:      "AG1234AG",
+		Start_time: testNow.Add(-72 * time.Hour),
+		End_time:   testNow.Add(-24 * time.Hour),
+	}
+	store := NewMemoryStore(visit(1, "AG1234AG", StatusInside, "P1", testNow.Add(-2*time.Hour)))
+
+	res, err := newService(store, sub).Exit(Request{Plate: "AG1234AG"})
+	if err != nil {
+		t.Fatalf("Exit returned error: %v", err)
+	}
+	if res.Car.Total_payment != 2 || res.Car.SubscriptionId != nil {
+		t.Fatalf("expired subscription was applied: %+v", res.Car)
+	}
+}
+
+func TestExitErrors(t *testing.T) {
+	store := NewMemoryStore(
+		visit(1, "EXITED01", StatusExited, "P1", testNow.Add(-time.Hour)),
+		visit(2, "INSIDE01", StatusInside, "P1", testNow.Add(-time.Hour)),
+	)
+	service := newService(store)
+
+	if _, err := service.Exit(Request{Plate: "MISSING1"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := service.Exit(Request{Plate: "EXITED01"}); !errors.Is(err, ErrAlreadyExited) {
+		t.Fatalf("expected ErrAlreadyExited, got %v", err)
+	}
+
+	res, err := service.Exit(Request{Plate: "INSIDE01", ParkNo: "P2", CheckPark: true})
+	if !errors.Is(err, ErrWrongPark) {
+		t.Fatalf("expected ErrWrongPark, got %v", err)
+	}
+	if res == nil || res.Car.ID != 2 {
+		t.Fatalf("wrong park result does not carry the visit: %+v", res)
+	}
+	if car, _ := store.Visit(2); car.Status != StatusInside {
+		t.Fatalf("visit in the wrong park was updated: %+v", car)
+	}
+}
+
+func TestExitRunsPostActions(t *testing.T) {
+	store := NewMemoryStore(visit(1, "AG1234AG", StatusInside, "P1", testNow.Add(-time.Hour)))
+	service := newService(store)
+
+	var calls []string
+	service.Actions = []PostAction{PostActionFunc(func(res *Result) error {
+		calls = append(calls, "service")
+		return nil
+	})}
+	failing := PostActionFunc(func(res *Result) error {
+		calls = append(calls, "failing")
+		return errors.New("gate offline")
+	})
+	last := PostActionFunc(func(res *Result) error {
+		calls = append(calls, "last:"+res.Car.Status)
+		return nil
+	})
+
+	if _, err := service.Exit(Request{Plate: "AG1234AG"}, failing, last); err != nil {
+		t.Fatalf("Exit returned error: %v", err)
+	}
+	if len(calls) != 3 || calls[0] != "service" || calls[1] != "failing" || calls[2] != "last:"+StatusPending {
+		t.Fatalf("unexpected post-action calls: %v", calls)
+	}
+
+	calls = nil
+	if _, err := service.Exit(Request{Plate: "MISSING1"}, last); err == nil || len(calls) != 0 {
+		t.Fatalf("post-actions ran for a failed exit: %v", calls)
+	}
+}
+
+func TestExitNormalizesPlate(t *testing.T) {
+	store := NewMemoryStore(visit(1, "BE5084AG", StatusInside, "P4", testNow.Add(-time.Hour)))
+
+	res, err := newService(store).Exit(Request{Plate: "ве 5O84-ag", ParkNo: "P4"})
+	if err != nil {
+		t.Fatalf("Exit returned error: %v", err)
+	}
+	if res.Car.ID != 1 || res.Car.FuzzyMatch || res.Car.ExitPlate != "ве 5O84-ag" {
+		t.Fatalf("unexpected visit: %+v", res.Car)
+	}
+}
+
+func TestExitFuzzyMatchesClosestOpenVisit(t *testing.T) {
+	store := NewMemoryStore(
+		visit(1, "BE5084AG", StatusInside, "P4", testNow.Add(-time.Hour)),
+		visit(2, "BE5084AC", StatusInside, "P5", testNow.Add(-time.Hour)),
+		visit(3, "MR1234AG", StatusInside, "P4", testNow.Add(-time.Hour)),
+	)
+	service := newService(store)
+	service.MaxPlateDistance = 2
+
+	res, err := service.Exit(Request{Plate: "BE5084AH", ParkNo: "P4"})
+	if err != nil {
+		t.Fatalf("Exit returned error: %v", err)
+	}
+	if res.Car.ID != 1 || !res.Car.FuzzyMatch || res.Car.ExitPlate != "BE5084AH" {
+		t.Fatalf("unexpected fuzzy match: %+v", res.Car)
+	}
+
+	if _, err := service.Exit(Request{Plate: "XX0000LB", ParkNo: "P4"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a distant plate, got %v", err)
+	}
+
+	service.MaxPlateDistance = 0
+	if _, err := service.Exit(Request{Plate: "MR1234AH", ParkNo: "P4"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound with fuzzy matching disabled, got %v", err)
+	}
+}
+
+func TestExitBeforeEntryIsRejected(t *testing.T) {
+	store := NewMemoryStore(visit(1, "AG1234AG", StatusInside, "P1", testNow))
+
+	if _, err := newService(store).Exit(Request{Plate: "AG1234AG", At: testNow.Add(-time.Minute)}); !errors.Is(err, ErrBeforeEntry) {
+		t.Fatalf("expected ErrBeforeEntry, got %v", err)
+	}
+	if car, _ := store.Visit(1); car.Status != StatusInside {
+		t.Fatalf("visit was closed by an earlier exit: %+v", car)
+	}
+}
+
+func TestExitAfterExitedVisitHasNoVisit(t *testing.T) {
+	previous := visit(1, "AG1234AG", StatusExited, "P1", testNow.Add(-5*time.Hour))
+	previous.End_time = sitetime.From(testNow.Add(-4 * time.Hour))
+	store := NewMemoryStore(previous)
+	service := newService(store)
+
+	// The car came back and its exit arrived before its new entry: it must
+	// not be refused as a repeat of the exit of the previous visit.
+	if _, err := service.Exit(Request{Plate: "AG1234AG", ParkNo: "P1"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := service.Exit(Request{Plate: "AG1234AG", At: previous.End_time.Time}); !errors.Is(err, ErrAlreadyExited) {
+		t.Fatalf("repeated exit: expected ErrAlreadyExited, got %v", err)
+	}
+	if car, _ := store.Visit(1); car.Status != StatusExited || !car.End_time.Equal(previous.End_time.Time) {
+		t.Fatalf("previous visit was changed: %+v", car)
+	}
+}
+
+func TestExitWithoutVisitCreatesLostTicket(t *testing.T) {
+	store := NewMemoryStore(visit(1, "AG1234AG", StatusInside, "P1", testNow.Add(-time.Hour)))
+	service := newService(store)
+	service.LostTicketFee = func(at time.Time) (float64, error) { return 10, nil }
+
+	var pushed []bool
+	push := PostActionFunc(func(res *Result) error {
+		pushed = append(pushed, res.Car.NoEntry)
+		return nil
+	})
+
+	res, err := service.Exit(Request{Plate: "mr 7777 ag", ParkNo: "P1", ChannelId: "exit"}, push)
+	if err != nil {
+		t.Fatalf("Exit returned error: %v", err)
+	}
+
+	car, ok := store.Visit(res.Car.ID)
+	if !ok || res.Car.ID == 1 {
+		t.Fatalf("lost ticket visit was not created: %+v", res.Car)
+	}
+	if !car.NoEntry || car.Car_number != "MR7777AG" || car.ParkNo != "P1" || car.Status != StatusPending {
+		t.Fatalf("unexpected lost ticket visit: %+v", car)
+	}
+	if car.Total_payment != 10 || !car.Start_time.IsZero() || !car.End_time.Equal(testNow) {
+		t.Fatalf("unexpected lost ticket pricing: %+v", car)
+	}
+	if len(pushed) != 1 || !pushed[0] {
+		t.Fatalf("lost ticket was not passed to the post-actions: %v", pushed)
+	}
+}
+
+func TestExitAfterExitedVisitCreatesLostTicket(t *testing.T) {
+	previous := visit(1, "AG1234AG", StatusExited, "P1", testNow.Add(-5*time.Hour))
+	previous.End_time = sitetime.From(testNow.Add(-4 * time.Hour))
+	previous.Total_payment = 2
+	store := NewMemoryStore(previous)
+	service := newService(store)
+	service.LostTicketFee = func(at time.Time) (float64, error) { return 10, nil }
+
+	res, err := service.Exit(Request{Plate: "AG1234AG", ParkNo: "P1"})
+	if err != nil {
+		t.Fatalf("Exit returned error: %v", err)
+	}
+	if res.Car.ID == 1 || !res.Car.NoEntry || res.Car.Total_payment != 10 || res.Car.Status != StatusPending {
+		t.Fatalf("unexpected lost ticket visit: %+v", res.Car)
+	}
+	if car, _ := store.Visit(1); car.Status != StatusExited || car.Total_payment != 2 || !car.End_time.Equal(previous.End_time.Time) {
+		t.Fatalf("previous visit was changed: %+v", car)
+	}
+}
+
+func TestSetEntryPricesLostTicket(t *testing.T) {
+	store := NewMemoryStore()
+	service := newService(store)
+	service.LostTicketFee = func(at time.Time) (float64, error) { return 10, nil }
+
+	res, err := service.Exit(Request{Plate: "MR7777AG", ParkNo: "P1"})
+	if err != nil {
+		t.Fatalf("Exit returned error: %v", err)
+	}
+
+	car, err := service.SetEntry(res.Car, testNow.Add(-2*time.Hour), true)
+	if err != nil {
+		t.Fatalf("SetEntry returned error: %v", err)
+	}
+	stored, _ := store.Visit(car.ID)
+	if stored.Total_payment != 2 || stored.Duration != 120 || !stored.NoEntry || !stored.EntryEstimated {
+		t.Fatalf("unexpected estimated visit: %+v", stored)
+	}
+
+	car, err = service.SetEntry(stored, testNow.Add(-time.Hour), false)
+	if err != nil {
+		t.Fatalf("SetEntry returned error: %v", err)
+	}
+	if car.Total_payment != 1 || car.NoEntry || car.EntryEstimated {
+		t.Fatalf("unexpected visit after the camera entry: %+v", car)
+	}
+
+	if _, err := service.SetEntry(car, testNow.Add(-time.Hour), true); !errors.Is(err, ErrHasEntry) {
+		t.Fatalf("expected ErrHasEntry, got %v", err)
+	}
+	if _, err := service.SetEntry(stored, testNow.Add(time.Hour), true); !errors.Is(err, ErrBeforeEntry) {
+		t.Fatalf("expected ErrBeforeEntry, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package carexit
+
+import (
+	"errors"
+	"sync"
+
+	"gorm.io/gorm"
+
+	modelscar "park/models/modelsCar"
+)
+
+var exitColumns = []string{
+	"duration", "total_payment", "subscription_id", "status", "start_time", "end_time",
+	"reason", "camera_id", "cam_token", "exit_plate", "fuzzy_match", "no_entry", "entry_estimated",
+}
+
+type gormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore returns a Store backed by the car_models table.
+func NewGormStore(db *gorm.DB) Store {
+	return &gormStore{db: db}
+}
+
+func (s *gormStore) LatestVisit(plate string) (modelscar.Car_Model, error) {
+	var car modelscar.Car_Model
+	err := s.db.Where("car_number = ?", plate).Order("id desc").First(&car).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return car, ErrNotFound
+	}
+	return car, err
+}
+
+func (s *gormStore) OpenVisits(parkNo string) ([]modelscar.Car_Model, error) {
+	var cars []modelscar.Car_Model
+	err := s.db.Where("park_no = ? AND status IN ?", parkNo, []string{StatusInside, StatusPending}).Find(&cars).Error
+	return cars, err
+}
+
+func (s *gormStore) SaveExit(car *modelscar.Car_Model) error {
+	return s.db.Model(car).Select(exitColumns).Updates(car).Error
+}
+
+func (s *gormStore) CreateVisit(car *modelscar.Car_Model) error {
+	return s.db.Create(car).Error
+}
+
+// MemoryStore is an in-memory Store used by tests and dry runs.
+type MemoryStore struct {
+	mu     sync.Mutex
+	visits []modelscar.Car_Model
+}
+
+func NewMemoryStore(visits ...modelscar.Car_Model) *MemoryStore {
+	return &MemoryStore{visits: visits}
+}
+
+func (m *MemoryStore) LatestVisit(plate string) (modelscar.Car_Model, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found := -1
+	for i, car := range m.visits {
+		if car.Car_number == plate && (found < 0 || car.ID > m.visits[found].ID) {
+			found = i
+		}
+	}
+	if found < 0 {
+		return modelscar.Car_Model{}, ErrNotFound
+	}
+	return m.visits[found], nil
+}
+
+func (m *MemoryStore) OpenVisits(parkNo string) ([]modelscar.Car_Model, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var cars []modelscar.Car_Model
+	for _, car := range m.visits {
+		if car.ParkNo == parkNo && (car.Status == StatusInside || car.Status == StatusPending) {
+			cars = append(cars, car)
+		}
+	}
+	return cars, nil
+}
+
+func (m *MemoryStore) SaveExit(car *modelscar.Car_Model) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.visits {
+		if m.visits[i].ID == car.ID {
+			m.visits[i] = *car
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *MemoryStore) CreateVisit(car *modelscar.Car_Model) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, visit := range m.visits {
+		if visit.ID >= car.ID {
+			car.ID = visit.ID + 1
+		}
+	}
+	m.visits = append(m.visits, *car)
+	return nil
+}
+
+// Visit returns the stored visit with the given ID.
+func (m *MemoryStore) Visit(id int) (modelscar.Car_Model, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, car := range m.visits {
+		if car.ID == id {
+			return car, true
+		}
+	}
+	return modelscar.Car_Model{}, false
+}
@@ -0,0 +1,209 @@
+// Package exemption decides who may let a car out without payment. The
+// reason must come from the catalog; reasons marked for approval, and fees
+// above EXEMPTION_APPROVAL_THRESHOLD, need a supervisor, who either enters
+// a PIN at the terminal or approves a request sent to them.
+package exemption
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"park/config"
+	modelexemption "park/models/exemptionModel"
+	modelsuser "park/models/modelsUser"
+	"park/service/ledger"
+	"park/util/sitetime"
+)
+
+var (
+	ErrUnknownReason = errors.New("unknown exemption reason")
+	ErrInvalidPin    = errors.New("invalid supervisor or PIN")
+	ErrNotFound      = errors.New("exemption request not found")
+	ErrNotPending    = errors.New("exemption request is already decided")
+	ErrNotApproved   = errors.New("exemption request is not approved")
+	ErrExpired       = errors.New("exemption request has expired")
+	ErrMismatch      = errors.New("exemption request is for another car or reason")
+	ErrOwnRequest    = errors.New("a supervisor can not decide their own request")
+)
+
+// Threshold is the fee in minor units above which every exemption needs a
+// supervisor.
+func Threshold() int64 {
+	return ledger.ToMinor(config.Float("EXEMPTION_APPROVAL_THRESHOLD", 20))
+}
+
+// NeedsApproval reports whether waiving fee for reason needs a supervisor.
+func NeedsApproval(reason modelexemption.Reason, fee int64) bool {
+	return reason.RequiresApproval || fee > Threshold()
+}
+
+// approvalTTL is how long a request waits for a supervisor, and how long
+// an approval can be used.
+func approvalTTL() time.Duration {
+	return config.Duration("EXEMPTION_APPROVAL_TTL", 15*time.Minute)
+}
+
+// FindReason returns the active catalog entry of code.
+func FindReason(db *gorm.DB, code string) (modelexemption.Reason, error) {
+	var reason modelexemption.Reason
+	err := db.Where("code = ? AND active", strings.TrimSpace(code)).First(&reason).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return reason, ErrUnknownReason
+	}
+	return reason, err
+}
+
+// CheckPinFormat returns why pin can not be used, or nil. PINs are digits
+// only, at least SUPERVISOR_PIN_MIN_LENGTH of them.
+func CheckPinFormat(pin string) error {
+	minLength := config.Int("SUPERVISOR_PIN_MIN_LENGTH", 6)
+	if len(pin) < minLength {
+		return fmt.Errorf("PIN must be at least %d digits long", minLength)
+	}
+	for _, r := range pin {
+		if !unicode.IsDigit(r) {
+			return errors.New("PIN must contain digits only")
+		}
+	}
+	return nil
+}
+
+// SetPin stores the hash of the approval PIN of a user. The PIN must have
+// passed CheckPinFormat.
+func SetPin(db *gorm.DB, userID int, pin string, now time.Time) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&modelexemption.Pin{
+		UserId:    userID,
+		Hash:      string(hashed),
+		UpdatedAt: sitetime.From(now),
+	}).Error
+}
+
+// CheckPin returns the active user username when pin is their approval
+// PIN. Whether the user may approve is left to the caller.
+func CheckPin(db *gorm.DB, username, pin string) (modelsuser.User, error) {
+	var user modelsuser.User
+	if err := db.Where("username = ? AND is_active", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, ErrInvalidPin
+		}
+		return user, err
+	}
+	var stored modelexemption.Pin
+	if err := db.First(&stored, "user_id = ?", user.Id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, ErrInvalidPin
+		}
+		return user, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(stored.Hash), []byte(pin)) != nil {
+		return user, ErrInvalidPin
+	}
+	return user, nil
+}
+
+// Request stores ex as a request waiting for a supervisor.
+func Request(db *gorm.DB, ex *modelexemption.Exemption, now time.Time) error {
+	ex.Status = modelexemption.Pending
+	ex.CreatedAt = sitetime.From(now)
+	ex.ExpiresAt = sitetime.From(now.Add(approvalTTL()))
+	return db.Create(ex).Error
+}
+
+// Decide approves or rejects a pending request. An approval can be used
+// until it expires.
+func Decide(db *gorm.DB, id int64, approve bool, supervisor string, now time.Time) (modelexemption.Exemption, error) {
+	var ex modelexemption.Exemption
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if ex, err = lock(tx, id); err != nil {
+			return err
+		}
+		if err := checkDecide(ex, supervisor, now); err != nil {
+			return err
+		}
+
+		ex.Status = modelexemption.Rejected
+		if approve {
+			ex.Status = modelexemption.Approved
+			ex.Via = modelexemption.ViaRemote
+			ex.ExpiresAt = sitetime.From(now.Add(approvalTTL()))
+		}
+		ex.ApprovedBy = supervisor
+		ex.DecidedAt = sitetime.From(now)
+		return tx.Save(&ex).Error
+	})
+	return ex, err
+}
+
+// Claim takes an approved request for the exit of carID with reason code,
+// so that it can be applied once. It must run in the transaction that
+// applies it.
+func Claim(tx *gorm.DB, id int64, carID int, code string, now time.Time) (modelexemption.Exemption, error) {
+	ex, err := lock(tx, id)
+	if err != nil {
+		return ex, err
+	}
+	return ex, checkClaim(ex, carID, code, now)
+}
+
+// checkDecide returns why supervisor can not decide ex at now, or nil.
+func checkDecide(ex modelexemption.Exemption, supervisor string, now time.Time) error {
+	switch {
+	case ex.Status != modelexemption.Pending:
+		return ErrNotPending
+	case now.After(ex.ExpiresAt.Time):
+		return ErrExpired
+	case ex.RequestedBy == supervisor:
+		return ErrOwnRequest
+	}
+	return nil
+}
+
+// checkClaim returns why ex can not be applied to the exit of carID with
+// reason code at now, or nil. An applied exemption is no longer approved,
+// so an approval is used once.
+func checkClaim(ex modelexemption.Exemption, carID int, code string, now time.Time) error {
+	switch {
+	case ex.Status != modelexemption.Approved:
+		return ErrNotApproved
+	case now.After(ex.ExpiresAt.Time):
+		return ErrExpired
+	case ex.CarId != carID || ex.ReasonCode != code:
+		return ErrMismatch
+	}
+	return nil
+}
+
+// Apply records ex as the free exit it was granted for, waiving amount.
+// An exemption without a request is created.
+func Apply(tx *gorm.DB, ex *modelexemption.Exemption, amount int64, now time.Time) error {
+	ex.Status = modelexemption.Applied
+	ex.Amount = amount
+	ex.AppliedAt = sitetime.From(now)
+	if ex.Id == 0 {
+		ex.CreatedAt = ex.AppliedAt
+		ex.DecidedAt = ex.AppliedAt
+		return tx.Create(ex).Error
+	}
+	return tx.Save(ex).Error
+}
+
+func lock(tx *gorm.DB, id int64) (modelexemption.Exemption, error) {
+	var ex modelexemption.Exemption
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ex, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ex, ErrNotFound
+	}
+	return ex, err
+}
@@ -0,0 +1,96 @@
+package exemption
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	modelexemption "park/models/exemptionModel"
+	"park/util/sitetime"
+)
+
+func TestNeedsApproval(t *testing.T) {
+	t.Setenv("EXEMPTION_APPROVAL_THRESHOLD", "20")
+
+	plain := modelexemption.Reason{Code: "ambulance"}
+	if NeedsApproval(plain, 2000) {
+		t.Fatal("a fee at the threshold needs approval")
+	}
+	if !NeedsApproval(plain, 2001) {
+		t.Fatal("a fee above the threshold needs no approval")
+	}
+	if !NeedsApproval(modelexemption.Reason{Code: "staff", RequiresApproval: true}, 0) {
+		t.Fatal("a reason marked for approval needs no approval")
+	}
+}
+
+func TestCheckPinFormat(t *testing.T) {
+	t.Setenv("SUPERVISOR_PIN_MIN_LENGTH", "6")
+
+	for pin, ok := range map[string]bool{
+		"123456":  true,
+		"0042817": true,
+		"12345":   false,
+		"12a456":  false,
+		"":        false,
+	} {
+		if err := CheckPinFormat(pin); (err == nil) != ok {
+			t.Errorf("CheckPinFormat(%q) = %v", pin, err)
+		}
+	}
+}
+
+func TestCheckDecide(t *testing.T) {
+	now := time.Date(2025, 1, 29, 10, 0, 0, 0, time.UTC)
+	pending := modelexemption.Exemption{
+		Status:      modelexemption.Pending,
+		RequestedBy: "operator",
+		ExpiresAt:   sitetime.From(now.Add(time.Minute)),
+	}
+
+	if err := checkDecide(pending, "supervisor", now); err != nil {
+		t.Fatalf("checkDecide of a pending request = %v", err)
+	}
+	if err := checkDecide(pending, "supervisor", now.Add(2*time.Minute)); !errors.Is(err, ErrExpired) {
+		t.Fatalf("checkDecide of an expired request = %v, want ErrExpired", err)
+	}
+	if err := checkDecide(pending, "operator", now); !errors.Is(err, ErrOwnRequest) {
+		t.Fatalf("checkDecide of an own request = %v, want ErrOwnRequest", err)
+	}
+	decided := pending
+	decided.Status = modelexemption.Approved
+	if err := checkDecide(decided, "supervisor", now); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("checkDecide of a decided request = %v, want ErrNotPending", err)
+	}
+}
+
+func TestCheckClaim(t *testing.T) {
+	now := time.Date(2025, 1, 29, 10, 0, 0, 0, time.UTC)
+	approved := modelexemption.Exemption{
+		Status:     modelexemption.Approved,
+		CarId:      7,
+		ReasonCode: "staff",
+		ExpiresAt:  sitetime.From(now.Add(time.Minute)),
+	}
+
+	if err := checkClaim(approved, 7, "staff", now); err != nil {
+		t.Fatalf("checkClaim of an approval = %v", err)
+	}
+	if err := checkClaim(approved, 7, "staff", now.Add(2*time.Minute)); !errors.Is(err, ErrExpired) {
+		t.Fatalf("checkClaim of an expired approval = %v, want ErrExpired", err)
+	}
+	if err := checkClaim(approved, 8, "staff", now); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("checkClaim for another car = %v, want ErrMismatch", err)
+	}
+	if err := checkClaim(approved, 7, "ambulance", now); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("checkClaim for another reason = %v, want ErrMismatch", err)
+	}
+
+	for _, status := range []modelexemption.Status{modelexemption.Pending, modelexemption.Rejected, modelexemption.Applied} {
+		ex := approved
+		ex.Status = status
+		if err := checkClaim(ex, 7, "staff", now); !errors.Is(err, ErrNotApproved) {
+			t.Errorf("checkClaim of a %s request = %v, want ErrNotApproved", status, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package gate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+
+	modelgate "park/models/gateModel"
+)
+
+// Credentials returns the login and password of the Macroscop server.
+type Credentials func() (login, password string, err error)
+
+// Macroscop switches the relay of a camera channel through the command
+// interface of the Macroscop server. Gate.Relay is the relay number and
+// Gate.Address may override Server.
+type Macroscop struct {
+	Server      string
+	Credentials Credentials
+	Client      *http.Client
+}
+
+func (m *Macroscop) Switch(ctx context.Context, g modelgate.Gate, action Action) error {
+	login, password, err := m.Credentials()
+	if err != nil {
+		return err
+	}
+
+	server := m.Server
+	if g.Address != "" {
+		server = g.Address
+	}
+	state := "on"
+	if action == Close {
+		state = "off"
+	}
+
+	query := url.Values{
+		"type":         {"relay"},
+		"channelid":    {g.ChannelId},
+		"relayid":      {g.Relay},
+		"state":        {state},
+		"login":        {login},
+		"password":     {password},
+		"responsetype": {"json"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s/command?%s", server, query.Encode()), nil)
+	if err != nil {
+		return err
+	}
+	return do(m.Client, req)
+}
+
+// HTTPRelay posts {"gate", "relay", "action"} as JSON to Gate.Address. Any
+// 2xx answer counts as success.
+type HTTPRelay struct {
+	Client *http.Client
+}
+
+func (h *HTTPRelay) Switch(ctx context.Context, g modelgate.Gate, action Action) error {
+	if g.Address == "" {
+		return fmt.Errorf("gate %s has no address", g.Name)
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"gate":   g.Name,
+		"relay":  g.Relay,
+		"action": string(action),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.Address, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return do(h.Client, req)
+}
+
+func do(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("gate answered %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Simulator keeps barrier states in memory. It is used on test benches and
+// in sites without controllable barriers.
+type Simulator struct {
+	mu    sync.Mutex
+	state map[int]bool
+}
+
+func NewSimulator() *Simulator {
+	return &Simulator{state: make(map[int]bool)}
+}
+
+func (s *Simulator) Switch(ctx context.Context, g modelgate.Gate, action Action) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state[g.Id] = action == Open
+	log.Printf("Simulated gate %s (%d): %s", g.Name, g.Id, action)
+	return nil
+}
+
+// IsOpen reports whether the simulated gate is open.
+func (s *Simulator) IsOpen(gateId int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state[gateId]
+}
@@ -0,0 +1,116 @@
+// Package gate sends open and close commands to parking barriers through
+// pluggable drivers and records the result of every command.
+package gate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	modelgate "park/models/gateModel"
+)
+
+type Action string
+
+const (
+	Open  Action = "open"
+	Close Action = "close"
+)
+
+// Trigger tells why a gate was switched.
+type Trigger string
+
+const (
+	TriggerPayment   Trigger = "payment"
+	TriggerVIP       Trigger = "vip"
+	TriggerManual    Trigger = "manual"
+	TriggerAutoClose Trigger = "auto_close"
+)
+
+var ErrUnknownDriver = errors.New("unknown gate driver")
+
+// Driver switches a barrier. Implementations must honour ctx cancellation.
+type Driver interface {
+	Switch(ctx context.Context, g modelgate.Gate, action Action) error
+}
+
+// DriverFunc adapts a function to Driver.
+type DriverFunc func(ctx context.Context, g modelgate.Gate, action Action) error
+
+func (f DriverFunc) Switch(ctx context.Context, g modelgate.Gate, action Action) error {
+	return f(ctx, g, action)
+}
+
+// Command describes who or what asks for a gate to be switched.
+type Command struct {
+	Trigger Trigger
+	CarId   *int
+	User    string
+	Reason  string
+}
+
+// Controller dispatches commands to the driver of a gate and logs them.
+type Controller struct {
+	Drivers map[string]Driver
+	// Log stores the event of every command. Errors are only logged.
+	Log func(event *modelgate.Event) error
+	// Timeout bounds a single driver call; zero means 5 seconds.
+	Timeout time.Duration
+}
+
+// Open opens a gate. Gates with CloseAfter set are closed again by the
+// controller after that many seconds.
+func (c *Controller) Open(g modelgate.Gate, cmd Command) (modelgate.Event, error) {
+	event, err := c.Switch(g, Open, cmd)
+	if err == nil && g.CloseAfter > 0 {
+		time.AfterFunc(time.Duration(g.CloseAfter)*time.Second, func() {
+			c.Switch(g, Close, Command{Trigger: TriggerAutoClose, CarId: cmd.CarId})
+		})
+	}
+	return event, err
+}
+
+// Switch sends one action to a gate and records the result.
+func (c *Controller) Switch(g modelgate.Gate, action Action, cmd Command) (modelgate.Event, error) {
+	event := modelgate.Event{
+		GateId:  g.Id,
+		Action:  string(action),
+		Trigger: string(cmd.Trigger),
+		CarId:   cmd.CarId,
+		User:    cmd.User,
+		Reason:  cmd.Reason,
+		Driver:  g.Driver,
+	}
+
+	err := c.run(g, action)
+	event.Success = err == nil
+	if err != nil {
+		event.Error = err.Error()
+		log.Printf("Gate %s (%d) failed to %s: %v", g.Name, g.Id, action, err)
+	}
+
+	if c.Log != nil {
+		if logErr := c.Log(&event); logErr != nil {
+			log.Println("Failed to log gate event -", logErr)
+		}
+	}
+	return event, err
+}
+
+func (c *Controller) run(g modelgate.Gate, action Action) error {
+	driver, ok := c.Drivers[g.Driver]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownDriver, g.Driver)
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return driver.Switch(ctx, g, action)
+}
@@ -0,0 +1,149 @@
+// Package ledger records payments, refunds and voids of car visits. Every
+// sum of money taken is calculated from the payments table.
+package ledger
+
+import (
+	"errors"
+	"math"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	modelscar "park/models/modelsCar"
+	modelpayment "park/models/paymentModel"
+	modelshift "park/models/shiftModel"
+)
+
+var (
+	ErrInvalidAmount  = errors.New("amount must be positive")
+	ErrInvalidMethod  = errors.New("unknown payment method")
+	ErrNotPayment     = errors.New("only payments can be refunded or voided")
+	ErrExceedsBalance = errors.New("amount exceeds what is left of the payment")
+	ErrNoOpenShift    = errors.New("cash can only be taken in an open shift")
+)
+
+// ToMinor converts an amount such as 2.5 into minor units.
+func ToMinor(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// FromMinor converts minor units back into an amount.
+func FromMinor(amount int64) float64 {
+	return float64(amount) / 100
+}
+
+func ValidMethod(method modelpayment.Method) bool {
+	switch method {
+	case modelpayment.Cash, modelpayment.Card, modelpayment.QR, modelpayment.Subscription:
+		return true
+	}
+	return false
+}
+
+// CurrentShift returns the open shift of an operator, or nil.
+func CurrentShift(db *gorm.DB, operator string) *int64 {
+	var s modelshift.Shift
+	err := db.Where("operator = ? AND status = ?", operator, modelshift.StatusOpen).Order("id DESC").First(&s).Error
+	if err != nil {
+		return nil
+	}
+	return &s.Id
+}
+
+// Pay records a payment for a visit. Partial payments are allowed; the
+// amount is not checked against the fee of the visit. Cash needs an open
+// shift of the operator so that it can be reconciled.
+func Pay(db *gorm.DB, car modelscar.Car_Model, amount int64, method modelpayment.Method, operator, note string) (modelpayment.Payment, error) {
+	if amount < 0 || (amount == 0 && method != modelpayment.Subscription) {
+		return modelpayment.Payment{}, ErrInvalidAmount
+	}
+	if !ValidMethod(method) {
+		return modelpayment.Payment{}, ErrInvalidMethod
+	}
+
+	shiftID := CurrentShift(db, operator)
+	if shiftID == nil && method == modelpayment.Cash {
+		return modelpayment.Payment{}, ErrNoOpenShift
+	}
+
+	payment := modelpayment.Payment{
+		CarId:    car.ID,
+		Amount:   amount,
+		Method:   method,
+		Kind:     modelpayment.KindPayment,
+		Operator: operator,
+		ShiftId:  shiftID,
+		ParkNo:   car.ParkNo,
+		Note:     note,
+	}
+	err := db.Create(&payment).Error
+	return payment, err
+}
+
+// Refund returns part or all of a payment. The refunds of a payment can
+// never exceed its amount.
+func Refund(db *gorm.DB, paymentID int, amount int64, operator, note string) (modelpayment.Payment, error) {
+	if amount <= 0 {
+		return modelpayment.Payment{}, ErrInvalidAmount
+	}
+	return reverse(db, paymentID, amount, modelpayment.KindRefund, operator, note)
+}
+
+// Void cancels what is left of a payment, for example one that was taken
+// by mistake.
+func Void(db *gorm.DB, paymentID int, operator, note string) (modelpayment.Payment, error) {
+	return reverse(db, paymentID, 0, modelpayment.KindVoid, operator, note)
+}
+
+func reverse(db *gorm.DB, paymentID int, amount int64, kind modelpayment.Kind, operator, note string) (modelpayment.Payment, error) {
+	var row modelpayment.Payment
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var original modelpayment.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&original, paymentID).Error; err != nil {
+			return err
+		}
+		if original.Kind != modelpayment.KindPayment {
+			return ErrNotPayment
+		}
+
+		var reversed int64
+		if err := tx.Model(&modelpayment.Payment{}).Where("ref_id = ?", original.Id).
+			Select("COALESCE(SUM(amount), 0)").Scan(&reversed).Error; err != nil {
+			return err
+		}
+		left := original.Amount + reversed
+		if amount == 0 {
+			amount = left
+		}
+		if left <= 0 || amount > left {
+			return ErrExceedsBalance
+		}
+
+		row = modelpayment.Payment{
+			CarId:    original.CarId,
+			Amount:   -amount,
+			Method:   original.Method,
+			Kind:     kind,
+			Operator: operator,
+			ShiftId:  CurrentShift(tx, operator),
+			ParkNo:   original.ParkNo,
+			RefId:    &original.Id,
+			Note:     note,
+		}
+		return tx.Create(&row).Error
+	})
+	return row, err
+}
+
+// Sum adds up the amounts of the payments selected by query, which must be
+// a query on the payments table.
+func Sum(query *gorm.DB) (int64, error) {
+	var total int64
+	err := query.Select("COALESCE(SUM(amount), 0)").Scan(&total).Error
+	return total, err
+}
+
+// Paid returns the net amount paid for a visit.
+func Paid(db *gorm.DB, carID int) (int64, error) {
+	return Sum(db.Model(&modelpayment.Payment{}).Where("car_id = ?", carID))
+}
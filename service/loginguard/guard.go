@@ -0,0 +1,175 @@
+// Package loginguard slows down password guessing. Failed logins are
+// counted per username and per IP address; past a few free attempts every
+// failure doubles the wait before the next attempt, and too many failures
+// lock the username or address for a while.
+package loginguard
+
+import (
+	"sync"
+	"time"
+
+	"park/config"
+)
+
+// Limits are the thresholds of one kind of key.
+type Limits struct {
+	Free    int           // failures allowed without waiting
+	Lockout int           // failures that lock the key
+	Backoff time.Duration // wait after the first failure past Free
+	MaxWait time.Duration
+	LockFor time.Duration
+}
+
+type entry struct {
+	failures    int
+	last        time.Time
+	next        time.Time
+	lockedUntil time.Time
+}
+
+// Guard counts failed logins in memory.
+type Guard struct {
+	mu        sync.Mutex
+	user      Limits
+	ip        Limits
+	entries   map[string]*entry
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+func New(user, ip Limits) *Guard {
+	return &Guard{user: user, ip: ip, entries: make(map[string]*entry), now: time.Now}
+}
+
+var (
+	defaultGuard *Guard
+	defaultOnce  sync.Once
+)
+
+// guard returns the guard of the server, configured from the environment
+// on first use.
+func guard() *Guard {
+	defaultOnce.Do(func() {
+		lockFor := config.Duration("LOGIN_LOCKOUT", 15*time.Minute)
+		maxWait := config.Duration("LOGIN_MAX_BACKOFF", time.Minute)
+		defaultGuard = New(
+			Limits{
+				Free:    config.Int("LOGIN_FREE_ATTEMPTS", 3),
+				Lockout: config.Int("LOGIN_MAX_ATTEMPTS", 10),
+				Backoff: time.Second,
+				MaxWait: maxWait,
+				LockFor: lockFor,
+			},
+			Limits{
+				Free:    config.Int("LOGIN_IP_FREE_ATTEMPTS", 10),
+				Lockout: config.Int("LOGIN_IP_MAX_ATTEMPTS", 50),
+				Backoff: time.Second,
+				MaxWait: maxWait,
+				LockFor: lockFor,
+			},
+		)
+	})
+	return defaultGuard
+}
+
+// Wait returns how long a login for username from ip has to wait, and
+// whether the wait is a lockout.
+func Wait(username, ip string) (time.Duration, bool) { return guard().Wait(username, ip) }
+
+// Fail records a failed login and reports whether it locked the username
+// or the address.
+func Fail(username, ip string) bool { return guard().Fail(username, ip) }
+
+// Succeed forgets the failures of username.
+func Succeed(username string) { guard().Succeed(username) }
+
+func (g *Guard) Wait(username, ip string) (time.Duration, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	var wait time.Duration
+	locked := false
+	for _, key := range keys(username, ip) {
+		e := g.entries[key]
+		if e == nil {
+			continue
+		}
+		if now.Before(e.lockedUntil) {
+			locked = true
+			if d := e.lockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		} else if d := e.next.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait, locked
+}
+
+func (g *Guard) Fail(username, ip string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	g.prune(now)
+	locked := false
+	for i, key := range keys(username, ip) {
+		limits := g.user
+		if i == 1 {
+			limits = g.ip
+		}
+		e := g.entries[key]
+		if e == nil {
+			e = &entry{}
+			g.entries[key] = e
+		}
+		e.failures++
+		e.last = now
+
+		switch {
+		case limits.Lockout > 0 && e.failures >= limits.Lockout:
+			// The count starts over once the lockout is served.
+			e.failures = 0
+			e.lockedUntil = now.Add(limits.LockFor)
+			locked = true
+		case e.failures > limits.Free:
+			wait := limits.Backoff << (e.failures - limits.Free - 1)
+			if wait > limits.MaxWait || wait <= 0 {
+				wait = limits.MaxWait
+			}
+			e.next = now.Add(wait)
+		}
+	}
+	return locked
+}
+
+// Succeed forgets the failures of username. Those of the address are kept,
+// or one valid account would let an address guess the others.
+func (g *Guard) Succeed(username string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.entries, "user:"+username)
+}
+
+// prune drops the entries that have not failed for a lockout period, at
+// most once per period.
+func (g *Guard) prune(now time.Time) {
+	period := g.user.LockFor
+	if g.ip.LockFor > period {
+		period = g.ip.LockFor
+	}
+	if now.Sub(g.lastPrune) < period {
+		return
+	}
+	g.lastPrune = now
+	for key, e := range g.entries {
+		if now.Sub(e.last) > period && now.After(e.lockedUntil) {
+			delete(g.entries, key)
+		}
+	}
+}
+
+func keys(username, ip string) []string {
+	return []string{"user:" + username, "ip:" + ip}
+}
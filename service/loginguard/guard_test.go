@@ -0,0 +1,57 @@
+package loginguard
+
+import (
+	"testing"
+	"time"
+)
+
+func testGuard(now *time.Time) *Guard {
+	limits := Limits{Free: 2, Lockout: 5, Backoff: time.Second, MaxWait: 3 * time.Second, LockFor: time.Minute}
+	g := New(limits, Limits{Free: 10, Lockout: 100, Backoff: time.Second, MaxWait: time.Minute, LockFor: time.Minute})
+	g.now = func() time.Time { return *now }
+	return g
+}
+
+func TestBackoffDoublesAndLocks(t *testing.T) {
+	now := time.Date(2025, 1, 29, 10, 0, 0, 0, time.UTC)
+	g := testGuard(&now)
+
+	want := []time.Duration{0, 0, time.Second, 2 * time.Second}
+	for i, w := range want {
+		if g.Fail("op", "10.0.0.1") {
+			t.Fatalf("failure %d locked", i+1)
+		}
+		if wait, _ := g.Wait("op", "10.0.0.1"); wait != w {
+			t.Fatalf("wait after failure %d = %v, want %v", i+1, wait, w)
+		}
+	}
+
+	if !g.Fail("op", "10.0.0.1") {
+		t.Fatal("fifth failure did not lock")
+	}
+	if wait, locked := g.Wait("op", "10.0.0.2"); !locked || wait != time.Minute {
+		t.Fatalf("Wait = %v, %v; want a minute of lockout for the username", wait, locked)
+	}
+
+	now = now.Add(time.Minute)
+	if wait, locked := g.Wait("op", "10.0.0.2"); locked || wait != 0 {
+		t.Fatalf("Wait after the lockout = %v, %v", wait, locked)
+	}
+}
+
+func TestSucceedKeepsAddressFailures(t *testing.T) {
+	now := time.Date(2025, 1, 29, 10, 0, 0, 0, time.UTC)
+	g := testGuard(&now)
+	g.ip.Free = 1
+
+	g.Fail("a", "10.0.0.1")
+	g.Fail("b", "10.0.0.1")
+	g.Succeed("b")
+
+	if wait, _ := g.Wait("c", "10.0.0.1"); wait != time.Second {
+		t.Fatalf("address wait = %v, want 1s", wait)
+	}
+	if wait, _ := g.Wait("b", "10.0.0.2"); wait != 0 {
+		t.Fatalf("username wait after success = %v, want 0", wait)
+	}
+}
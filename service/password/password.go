@@ -0,0 +1,91 @@
+// Package password holds the password policy. New passwords need a
+// minimum length and characters of several classes (lower case, upper
+// case, digits, symbols), and must differ from the username. Admin
+// passwords expire.
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"park/config"
+	modelsuser "park/models/modelsUser"
+	"park/util/sitetime"
+)
+
+// Policy is read from the environment.
+type Policy struct {
+	MinLength   int
+	MinClasses  int
+	AdminMaxAge time.Duration // zero means admin passwords do not expire
+}
+
+func Current() Policy {
+	return Policy{
+		MinLength:   config.Int("PASSWORD_MIN_LENGTH", 8),
+		MinClasses:  config.Int("PASSWORD_MIN_CLASSES", 3),
+		AdminMaxAge: config.Duration("PASSWORD_ADMIN_MAX_AGE", 90*24*time.Hour),
+	}
+}
+
+// Check returns why password does not follow the policy, or nil.
+func (p Policy) Check(password, username string) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters long", p.MinLength)
+	}
+	if classes(password) < p.MinClasses {
+		return fmt.Errorf("password must contain %d of: lower case letters, upper case letters, digits and symbols", p.MinClasses)
+	}
+	if strings.EqualFold(password, username) {
+		return errors.New("password must not be the username")
+	}
+	return nil
+}
+
+// Expired reports whether the password of user has to be changed before
+// the next login. A password never changed counts from the creation of the
+// user.
+func (p Policy) Expired(user modelsuser.User, now time.Time) bool {
+	if user.Role != modelsuser.AdminRole || p.AdminMaxAge <= 0 {
+		return false
+	}
+	changed := user.PasswordChangedAt.Time
+	if changed.IsZero() && user.Model != nil {
+		changed = user.Model.CreatedAt
+	}
+	return !changed.IsZero() && now.Sub(changed) > p.AdminMaxAge
+}
+
+// Set stores the hash of password in user. The password must have passed
+// Check.
+func Set(user *modelsuser.User, password string, now time.Time) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	user.Password = string(hashed)
+	user.PasswordChangedAt = sitetime.From(now)
+	return nil
+}
+
+func classes(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
@@ -0,0 +1,93 @@
+package password
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	modelsuser "park/models/modelsUser"
+	"park/util/sitetime"
+)
+
+var testNow = time.Date(2025, 1, 29, 14, 0, 0, 0, time.UTC)
+
+func TestCheck(t *testing.T) {
+	p := Policy{MinLength: 8, MinClasses: 3}
+
+	cases := []struct {
+		password string
+		ok       bool
+	}{
+		{"Short1!", false},
+		{"alllowercase", false},
+		{"lowerUPPER", false},
+		{"lowerUPPER1", true},
+		{"lower1234!", true},
+		{"Çykyş2025", true},
+		{"Operator1", false}, // the username in another case
+	}
+	for _, tc := range cases {
+		err := p.Check(tc.password, "operator1")
+		if (err == nil) != tc.ok {
+			t.Errorf("Check(%q) = %v, want ok %v", tc.password, err, tc.ok)
+		}
+	}
+}
+
+func TestCheckCountsRunes(t *testing.T) {
+	p := Policy{MinLength: 4, MinClasses: 1}
+	if err := p.Check("ýüşç", "user"); err != nil {
+		t.Fatalf("four letters rejected: %v", err)
+	}
+	if err := p.Check("ýüş", "user"); err == nil {
+		t.Fatal("three letters accepted")
+	}
+}
+
+func TestExpired(t *testing.T) {
+	p := Policy{AdminMaxAge: 90 * 24 * time.Hour}
+	admin := func(changed, created time.Time) modelsuser.User {
+		u := modelsuser.User{Role: modelsuser.AdminRole, PasswordChangedAt: sitetime.From(changed)}
+		if !created.IsZero() {
+			u.Model = &gorm.Model{CreatedAt: created}
+		}
+		return u
+	}
+
+	if p.Expired(admin(testNow.AddDate(0, 0, -30), time.Time{}), testNow) {
+		t.Error("recent password expired")
+	}
+	if !p.Expired(admin(testNow.AddDate(0, 0, -91), time.Time{}), testNow) {
+		t.Error("old admin password did not expire")
+	}
+	if !p.Expired(admin(time.Time{}, testNow.AddDate(-1, 0, 0)), testNow) {
+		t.Error("never changed password of an old admin did not expire")
+	}
+	if p.Expired(admin(time.Time{}, time.Time{}), testNow) {
+		t.Error("password without any time expired")
+	}
+
+	operator := admin(testNow.AddDate(-1, 0, 0), time.Time{})
+	operator.Role = modelsuser.OperatorRole
+	if p.Expired(operator, testNow) {
+		t.Error("operator password expired")
+	}
+	if (Policy{}).Expired(admin(testNow.AddDate(-1, 0, 0), time.Time{}), testNow) {
+		t.Error("password expired without a maximum age")
+	}
+}
+
+func TestSet(t *testing.T) {
+	var user modelsuser.User
+	if err := Set(&user, "lowerUPPER1", testNow); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("lowerUPPER1")) != nil {
+		t.Fatal("stored hash does not match the password")
+	}
+	if !user.PasswordChangedAt.Equal(testNow) {
+		t.Fatalf("changed at %v, want %v", user.PasswordChangedAt, testNow)
+	}
+}
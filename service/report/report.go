@@ -0,0 +1,109 @@
+// Package report builds X-reports, the running totals of an open shift, and
+// Z-reports, which close a shift and are stored with a gapless number.
+package report
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	modelexemption "park/models/exemptionModel"
+	modelscar "park/models/modelsCar"
+	modelpayment "park/models/paymentModel"
+	modelreport "park/models/reportModel"
+	modelshift "park/models/shiftModel"
+	"park/service/ledger"
+	"park/service/shift"
+	"park/util/sitetime"
+)
+
+// Build returns the totals of a shift up to until. The result has no
+// number; it is the X-report of the shift.
+func Build(db *gorm.DB, s modelshift.Shift, until time.Time) (modelreport.ZReport, error) {
+	r := modelreport.ZReport{
+		ShiftId:      s.Id,
+		Operator:     s.Operator,
+		ParkNo:       s.ParkNo,
+		OpenedAt:     s.OpenedAt,
+		ClosedAt:     sitetime.From(until),
+		OpeningFloat: s.OpeningFloat,
+		ExpectedCash: s.ExpectedCash,
+		DeclaredCash: s.DeclaredCash,
+		Variance:     s.Variance,
+	}
+
+	payments := db.Model(&modelpayment.Payment{}).Where("shift_id = ?", s.Id)
+	if err := payments.Session(&gorm.Session{}).
+		Select("method, COUNT(*) FILTER (WHERE kind = ?) AS payments, SUM(amount) AS amount", modelpayment.KindPayment).
+		Group("method").Order("method").Scan(&r.Totals).Error; err != nil {
+		return r, err
+	}
+	refunds, err := ledger.Sum(payments.Session(&gorm.Session{}).Where("kind <> ?", modelpayment.KindPayment))
+	if err != nil {
+		return r, err
+	}
+	r.Refunds = refunds
+	for _, t := range r.Totals {
+		r.Net += t.Amount
+	}
+
+	// Free exits are the exemptions applied to the visits the operator let
+	// out during the shift.
+	if err := db.Model(&modelexemption.Exemption{}).
+		Select("exemptions.reason_code AS reason, COUNT(*) AS count").
+		Joins("JOIN car_models c ON c.id = exemptions.car_id").
+		Where("exemptions.status = ? AND exemptions.applied_at >= ? AND exemptions.applied_at <= ? AND c.user_id = ?",
+			modelexemption.Applied, s.OpenedAt, until, s.Operator).
+		Group("exemptions.reason_code").Order("exemptions.reason_code").Scan(&r.Exemptions).Error; err != nil {
+		return r, err
+	}
+	for _, e := range r.Exemptions {
+		r.ExemptionTotal += e.Count
+	}
+
+	var vip int64
+	if err := db.Model(&modelscar.Car_Model{}).
+		Where("status = ? AND end_time >= ? AND end_time <= ?", "Exited", s.OpenedAt, until).
+		Where("user_id = ? AND subscription_id IS NOT NULL AND total_payment = 0", s.Operator).
+		Count(&vip).Error; err != nil {
+		return r, err
+	}
+	r.VipExits = int(vip)
+
+	if s.Status == modelshift.StatusOpen {
+		expected, err := shift.ExpectedCash(db, s)
+		if err != nil {
+			return r, err
+		}
+		r.ExpectedCash = expected
+	}
+	return r, nil
+}
+
+// Z closes an open shift with the counted cash and stores its Z-report
+// under the next number. Both happen in one transaction, so a failed close
+// never uses up a number.
+func Z(db *gorm.DB, s modelshift.Shift, declared int64, denominations []modelshift.Denomination, note string, at time.Time) (modelreport.ZReport, modelshift.Shift, error) {
+	var r modelreport.ZReport
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		s, err = shift.Close(tx, s, declared, denominations, note, at)
+		if err != nil {
+			return err
+		}
+		if r, err = Build(tx, s, at); err != nil {
+			return err
+		}
+
+		// Numbers are taken under a table lock so that concurrent closes
+		// can not get the same one.
+		if err := tx.Exec("LOCK TABLE z_reports IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&modelreport.ZReport{}).Select("COALESCE(MAX(number), 0) + 1").Scan(&r.Number).Error; err != nil {
+			return err
+		}
+		return tx.Create(&r).Error
+	})
+	return r, s, err
+}
@@ -0,0 +1,122 @@
+// Package secrets keeps credentials of other systems encrypted at rest.
+// Values are sealed with AES-256-GCM under the key in SECRET_STORE_KEY and
+// bound to their name, so a sealed value can not be moved to another name.
+//
+// The key is 32 random bytes in standard base64, e.g. the output of
+// "openssl rand -base64 32". It is set in the environment of the service,
+// never in a file of the repository, and must not change once values have
+// been sealed under it.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	modelsecret "park/models/secretModel"
+)
+
+// Names of the Macroscop server credentials.
+const (
+	MacroscopUsername = "macroscop.username"
+	MacroscopPassword = "macroscop.password"
+)
+
+const prefix = "v1:"
+
+var (
+	ErrNoKey     = errors.New("SECRET_STORE_KEY must be 32 base64 encoded bytes")
+	ErrMalformed = errors.New("sealed value is malformed")
+)
+
+func aead() (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(os.Getenv("SECRET_STORE_KEY"))
+	if err != nil || len(key) != 32 {
+		return nil, ErrNoKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// CheckKey returns ErrNoKey unless SECRET_STORE_KEY holds a valid key. The
+// server refuses to start without one.
+func CheckKey() error {
+	_, err := aead()
+	return err
+}
+
+// Seal encrypts value for name.
+func Seal(name, value string) (string, error) {
+	gcm, err := aead()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(value), []byte(name))
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value sealed for name.
+func Open(name, sealed string) (string, error) {
+	gcm, err := aead()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, prefix))
+	if err != nil || !strings.HasPrefix(sealed, prefix) || len(data) < gcm.NonceSize() {
+		return "", ErrMalformed
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	value, err := gcm.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+// Get returns the value stored under name, or gorm.ErrRecordNotFound.
+func Get(db *gorm.DB, name string) (string, error) {
+	var secret modelsecret.Secret
+	if err := db.Where("name = ?", name).First(&secret).Error; err != nil {
+		return "", err
+	}
+	return Open(name, secret.Value)
+}
+
+// Set stores value under name, replacing any previous value.
+func Set(db *gorm.DB, name, value string) error {
+	sealed, err := Seal(name, value)
+	if err != nil {
+		return err
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&modelsecret.Secret{Name: name, Value: sealed}).Error
+}
+
+// Macroscop returns the login of the Macroscop server.
+func Macroscop(db *gorm.DB) (string, string, error) {
+	username, err := Get(db, MacroscopUsername)
+	if err != nil {
+		return "", "", err
+	}
+	password, err := Get(db, MacroscopPassword)
+	if err != nil {
+		return "", "", err
+	}
+	return username, password, nil
+}
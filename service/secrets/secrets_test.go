@@ -0,0 +1,53 @@
+package secrets
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+func TestSealOpen(t *testing.T) {
+	t.Setenv("SECRET_STORE_KEY", testKey)
+
+	sealed, err := Seal(MacroscopPassword, "s3cret")
+	if err != nil {
+		t.Fatalf("Seal returned error: %v", err)
+	}
+	if strings.Contains(sealed, "s3cret") {
+		t.Fatalf("sealed value contains the plain text: %s", sealed)
+	}
+
+	value, err := Open(MacroscopPassword, sealed)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	if value != "s3cret" {
+		t.Fatalf("Open = %q, want %q", value, "s3cret")
+	}
+
+	if _, err := Open(MacroscopUsername, sealed); err == nil {
+		t.Fatal("value sealed for one name opened under another")
+	}
+}
+
+func TestSealWithoutKey(t *testing.T) {
+	t.Setenv("SECRET_STORE_KEY", "")
+
+	if _, err := Seal(MacroscopPassword, "s3cret"); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("Seal without key returned %v, want ErrNoKey", err)
+	}
+	if err := CheckKey(); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("CheckKey without key returned %v, want ErrNoKey", err)
+	}
+
+	t.Setenv("SECRET_STORE_KEY", "c2hvcnQ=")
+	if err := CheckKey(); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("CheckKey with a short key returned %v, want ErrNoKey", err)
+	}
+	t.Setenv("SECRET_STORE_KEY", testKey)
+	if err := CheckKey(); err != nil {
+		t.Fatalf("CheckKey with a valid key returned %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+// Package security writes the security event log.
+package security
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+
+	"park/database"
+	modelsuser "park/models/modelsUser"
+	"park/util/sitetime"
+)
+
+// Kinds of security events.
+const (
+	LoginFailed       = "login_failed"
+	LoginThrottled    = "login_throttled"
+	LoginLocked       = "login_locked"
+	PasswordExpired   = "password_expired"
+	PasswordRejected  = "password_rejected"
+	PasswordChanged   = "password_changed"
+	RefreshFailed     = "refresh_failed"
+	TokenRejected     = "token_rejected"
+	PermissionDenied  = "permission_denied"
+	TwoFactorFailed   = "two_factor_failed"
+	TwoFactorDisabled = "two_factor_disabled"
+	TwoFactorReset    = "two_factor_reset"
+
+	SupervisorPinFailed    = "supervisor_pin_failed"
+	SupervisorPinThrottled = "supervisor_pin_throttled"
+
+	WebhookRejected = "webhook_rejected"
+)
+
+// Record writes an event about the request c. Failing to write it is
+// logged and does not fail the request.
+func Record(c *fiber.Ctx, kind, username, detail string) {
+	if database.DB == nil {
+		return
+	}
+	event := modelsuser.SecurityEvent{
+		Kind:      kind,
+		Username:  username,
+		IP:        c.IP(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		Method:    c.Method(),
+		Path:      c.Path(),
+		Detail:    detail,
+		CreatedAt: sitetime.From(sitetime.Now()),
+	}
+	if err := database.DB.Create(&event).Error; err != nil {
+		log.Println("Failed to record security event", kind, "for", username, ":", err)
+	}
+}
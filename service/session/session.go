@@ -0,0 +1,275 @@
+// Package session keeps the logins of users. A login gets a short-lived
+// access token and a refresh token that is replaced on every use. Revoked
+// sessions are remembered in memory for as long as their access tokens can
+// live, so that the middleware can refuse them without a query.
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	modelsuser "park/models/modelsUser"
+	"park/util/sitetime"
+)
+
+const (
+	AccessTTL  = 15 * time.Minute
+	RefreshTTL = 7 * 24 * time.Hour
+	// MaxLifetime ends a session however often it is refreshed; the user
+	// logs in again after it.
+	MaxLifetime = 30 * 24 * time.Hour
+	// RefreshGrace is how long a replaced refresh token is still accepted.
+	// Tabs that refresh at the same moment all send the same token, and
+	// only the first one is the current token when it arrives.
+	RefreshGrace = 30 * time.Second
+)
+
+// Reasons stored with revoked sessions.
+const (
+	ReasonLogout           = "logout"
+	ReasonRevoked          = "revoked"
+	ReasonReused           = "refresh token reused"
+	ReasonDeactivated      = "user deactivated"
+	ReasonDeleted          = "user deleted"
+	ReasonPassword         = "password changed"
+	ReasonRole             = "role changed"
+	ReasonTwoFactor        = "two-factor authentication reset"
+	ReasonTwoFactorMissing = "two-factor authentication required"
+)
+
+var (
+	ErrInvalid  = errors.New("refresh token is not valid")
+	ErrExpired  = errors.New("session has expired")
+	ErrRevoked  = errors.New("session has been revoked")
+	ErrReused   = errors.New("refresh token was used twice, the session is revoked")
+	ErrInactive = errors.New("user is not active")
+)
+
+var (
+	revokedMu sync.Mutex
+	// revoked maps the ID of a revoked session to the time its last access
+	// token expires.
+	revoked = make(map[string]time.Time)
+)
+
+// Start opens a session for user in park and returns it with its refresh
+// token.
+func Start(db *gorm.DB, user modelsuser.User, parkNo, userAgent, ip string) (modelsuser.Session, string, error) {
+	id, err := random(16)
+	if err != nil {
+		return modelsuser.Session{}, "", err
+	}
+	token, err := random(32)
+	if err != nil {
+		return modelsuser.Session{}, "", err
+	}
+
+	now := sitetime.Now()
+	s := modelsuser.Session{
+		Id:          hex.EncodeToString(id),
+		UserId:      user.Id,
+		Username:    user.Username,
+		ParkNo:      parkNo,
+		RefreshHash: hash(encode(token)),
+		UserAgent:   userAgent,
+		IP:          ip,
+		CreatedAt:   sitetime.From(now),
+		LastUsedAt:  sitetime.From(now),
+		ExpiresAt:   sitetime.From(now.Add(RefreshTTL)),
+	}
+	if err := db.Create(&s).Error; err != nil {
+		return modelsuser.Session{}, "", err
+	}
+	return s, encode(token), nil
+}
+
+// Refresh replaces the refresh token of its session and returns the
+// session, its user and the new token. Presenting a token that was already
+// replaced more than RefreshGrace ago revokes the session, since one of its
+// two holders is not its owner.
+func Refresh(db *gorm.DB, token, userAgent, ip string) (modelsuser.Session, modelsuser.User, string, error) {
+	var s modelsuser.Session
+	var user modelsuser.User
+	if token == "" {
+		return s, user, "", ErrInvalid
+	}
+
+	next, err := random(32)
+	if err != nil {
+		return s, user, "", err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		presented := hash(token)
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("refresh_hash = ? OR previous_hash = ?", presented, presented).First(&s).Error; err != nil {
+			return err
+		}
+		if err := rotate(&s, presented, hash(encode(next)), sitetime.Now()); err != nil {
+			return err
+		}
+
+		if err := tx.First(&user, s.UserId).Error; err != nil {
+			return err
+		}
+		if !user.IsActive {
+			return ErrInactive
+		}
+
+		return tx.Model(&s).Updates(map[string]interface{}{
+			"refresh_hash":  s.RefreshHash,
+			"previous_hash": s.PreviousHash,
+			"rotated_at":    s.RotatedAt,
+			"user_agent":    userAgent,
+			"ip":            ip,
+			"last_used_at":  s.LastUsedAt,
+			"expires_at":    s.ExpiresAt,
+		}).Error
+	})
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return s, user, "", ErrInvalid
+	case errors.Is(err, ErrReused):
+		if err := Revoke(db, s.Id, ReasonReused); err != nil {
+			return s, user, "", err
+		}
+		return s, user, "", ErrReused
+	case errors.Is(err, ErrInactive):
+		if err := Revoke(db, s.Id, ReasonDeactivated); err != nil {
+			return s, user, "", err
+		}
+		return s, user, "", ErrInactive
+	case err != nil:
+		return s, user, "", err
+	}
+	return s, user, encode(next), nil
+}
+
+// rotate replaces the refresh token of s, found by the hash of the
+// presented token, with the token hashed as next. A replaced token is only
+// accepted within RefreshGrace of its replacement; it stays the previous
+// token then, so that every concurrent refresh gets a new token.
+func rotate(s *modelsuser.Session, presented, next string, now time.Time) error {
+	switch {
+	case !s.RevokedAt.IsZero():
+		return ErrRevoked
+	case now.After(s.ExpiresAt.Time), !now.Before(s.CreatedAt.Add(MaxLifetime)):
+		return ErrExpired
+	}
+
+	if presented == s.RefreshHash {
+		s.PreviousHash = s.RefreshHash
+		s.RotatedAt = sitetime.From(now)
+	} else if presented != s.PreviousHash || now.Sub(s.RotatedAt.Time) > RefreshGrace {
+		return ErrReused
+	}
+
+	s.RefreshHash = next
+	s.LastUsedAt = sitetime.From(now)
+	expires := now.Add(RefreshTTL)
+	if end := s.CreatedAt.Add(MaxLifetime); expires.After(end) {
+		expires = end
+	}
+	s.ExpiresAt = sitetime.From(expires)
+	return nil
+}
+
+// List returns the sessions of a user that are neither revoked nor
+// expired, most recently used first.
+func List(db *gorm.DB, userID int) ([]modelsuser.Session, error) {
+	sessions := []modelsuser.Session{}
+	err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, sitetime.Now()).
+		Order("last_used_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// Revoke ends a session. Revoking a revoked session changes nothing.
+func Revoke(db *gorm.DB, id, reason string) error {
+	return revoke(db.Where("id = ?", id), reason)
+}
+
+// RevokeUser ends every session of a user.
+func RevokeUser(db *gorm.DB, userID int, reason string) error {
+	return revoke(db.Where("user_id = ?", userID), reason)
+}
+
+func revoke(query *gorm.DB, reason string) error {
+	var sessions []modelsuser.Session
+	err := query.Model(&sessions).Where("revoked_at IS NULL").
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Updates(map[string]interface{}{
+			"revoked_at":    sitetime.Now(),
+			"revoke_reason": reason,
+		}).Error
+	if err != nil {
+		return err
+	}
+	ids := make([]string, len(sessions))
+	for i, s := range sessions {
+		ids[i] = s.Id
+	}
+	remember(ids, time.Now().Add(AccessTTL))
+	return nil
+}
+
+// Revoked reports whether the session with the given ID has been revoked
+// while its access tokens may still be valid.
+func Revoked(id string) bool {
+	revokedMu.Lock()
+	defer revokedMu.Unlock()
+	until, ok := revoked[id]
+	return ok && time.Now().Before(until)
+}
+
+// LoadRevoked fills the cache with the sessions revoked recently enough to
+// have access tokens left. It runs at start.
+func LoadRevoked(db *gorm.DB) error {
+	var sessions []modelsuser.Session
+	if err := db.Select("id", "revoked_at").Where("revoked_at > ?", time.Now().Add(-AccessTTL)).
+		Find(&sessions).Error; err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		remember([]string{s.Id}, s.RevokedAt.Add(AccessTTL))
+	}
+	return nil
+}
+
+func remember(ids []string, until time.Time) {
+	revokedMu.Lock()
+	defer revokedMu.Unlock()
+	now := time.Now()
+	for id, expires := range revoked {
+		if now.After(expires) {
+			delete(revoked, id)
+		}
+	}
+	for _, id := range ids {
+		revoked[id] = until
+	}
+}
+
+func random(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	return b, err
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// hash is what is stored of a refresh token.
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,129 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	modelsuser "park/models/modelsUser"
+	"park/util/sitetime"
+)
+
+var testNow = time.Date(2025, 1, 29, 14, 0, 0, 0, time.UTC)
+
+func newSession(created time.Time) modelsuser.Session {
+	return modelsuser.Session{
+		Id:          "s1",
+		RefreshHash: hash("first"),
+		CreatedAt:   sitetime.From(created),
+		LastUsedAt:  sitetime.From(created),
+		ExpiresAt:   sitetime.From(created.Add(RefreshTTL)),
+	}
+}
+
+func TestRotateReplacesToken(t *testing.T) {
+	s := newSession(testNow.Add(-time.Hour))
+
+	if err := rotate(&s, hash("first"), hash("second"), testNow); err != nil {
+		t.Fatalf("rotate returned %v", err)
+	}
+	if s.RefreshHash != hash("second") || s.PreviousHash != hash("first") {
+		t.Fatalf("token was not replaced: %+v", s)
+	}
+	if !s.RotatedAt.Equal(testNow) || !s.ExpiresAt.Equal(testNow.Add(RefreshTTL)) {
+		t.Fatalf("unexpected times after rotation: %+v", s)
+	}
+
+	later := testNow.Add(time.Hour)
+	if err := rotate(&s, hash("second"), hash("third"), later); err != nil {
+		t.Fatalf("second rotation returned %v", err)
+	}
+	if s.PreviousHash != hash("second") {
+		t.Fatalf("previous token is %s, want the second one", s.PreviousHash)
+	}
+}
+
+func TestRotateAcceptsConcurrentRefresh(t *testing.T) {
+	s := newSession(testNow.Add(-time.Hour))
+	if err := rotate(&s, hash("first"), hash("second"), testNow); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another tab sends the first token a moment later.
+	if err := rotate(&s, hash("first"), hash("other"), testNow.Add(RefreshGrace)); err != nil {
+		t.Fatalf("concurrent refresh returned %v", err)
+	}
+	if s.RefreshHash != hash("other") || s.PreviousHash != hash("first") || !s.RotatedAt.Equal(testNow) {
+		t.Fatalf("grace refresh changed the rotation: %+v", s)
+	}
+}
+
+func TestRotateDetectsReuse(t *testing.T) {
+	s := newSession(testNow.Add(-time.Hour))
+	if err := rotate(&s, hash("first"), hash("second"), testNow); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rotate(&s, hash("first"), hash("stolen"), testNow.Add(RefreshGrace+time.Second)); !errors.Is(err, ErrReused) {
+		t.Fatalf("reuse after the grace window returned %v, want ErrReused", err)
+	}
+	if s.RefreshHash != hash("second") {
+		t.Fatal("reused token replaced the current one")
+	}
+
+	// Sessions rotated before RotatedAt existed have no grace window.
+	s = newSession(testNow.Add(-time.Hour))
+	s.PreviousHash = hash("old")
+	if err := rotate(&s, hash("old"), hash("next"), testNow); !errors.Is(err, ErrReused) {
+		t.Fatalf("reuse without rotation time returned %v, want ErrReused", err)
+	}
+}
+
+func TestRotateRefusesEndedSessions(t *testing.T) {
+	revoked := newSession(testNow.Add(-time.Hour))
+	revoked.RevokedAt = sitetime.From(testNow.Add(-time.Minute))
+	if err := rotate(&revoked, hash("first"), hash("next"), testNow); !errors.Is(err, ErrRevoked) {
+		t.Fatalf("revoked session returned %v, want ErrRevoked", err)
+	}
+
+	expired := newSession(testNow.Add(-RefreshTTL - time.Minute))
+	if err := rotate(&expired, hash("first"), hash("next"), testNow); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expired session returned %v, want ErrExpired", err)
+	}
+
+	// Refreshed every day, the session still ends after MaxLifetime.
+	s := newSession(testNow.Add(-MaxLifetime + 2*time.Hour))
+	s.ExpiresAt = sitetime.From(testNow.Add(RefreshTTL))
+	if err := rotate(&s, hash("first"), hash("second"), testNow); err != nil {
+		t.Fatal(err)
+	}
+	if want := s.CreatedAt.Add(MaxLifetime); !s.ExpiresAt.Equal(want) {
+		t.Fatalf("expires at %v, want the end of the lifetime %v", s.ExpiresAt, want)
+	}
+	if err := rotate(&s, hash("second"), hash("third"), testNow.Add(2*time.Hour)); !errors.Is(err, ErrExpired) {
+		t.Fatalf("session past its lifetime returned %v, want ErrExpired", err)
+	}
+}
+
+func TestRevokedCache(t *testing.T) {
+	remember([]string{"a", "b"}, time.Now().Add(time.Minute))
+	remember([]string{"old"}, time.Now().Add(-time.Second))
+
+	if !Revoked("a") || !Revoked("b") {
+		t.Fatal("revoked sessions are not remembered")
+	}
+	if Revoked("old") {
+		t.Fatal("session whose access tokens expired is still refused")
+	}
+	if Revoked("c") {
+		t.Fatal("unknown session is refused")
+	}
+
+	remember(nil, time.Now())
+	revokedMu.Lock()
+	_, kept := revoked["old"]
+	revokedMu.Unlock()
+	if kept {
+		t.Fatal("expired entries are not dropped")
+	}
+}
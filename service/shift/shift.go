@@ -0,0 +1,123 @@
+// Package shift opens, closes and approves operator shifts and reconciles
+// the cash declared at close with the payments ledger.
+package shift
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	modelpayment "park/models/paymentModel"
+	modelshift "park/models/shiftModel"
+	"park/service/ledger"
+	"park/util/sitetime"
+)
+
+var (
+	ErrShiftOpen     = errors.New("operator already has an open shift")
+	ErrNotOpen       = errors.New("shift is not open")
+	ErrNotClosed     = errors.New("shift is not closed")
+	ErrLocked        = errors.New("shift is approved and locked")
+	ErrInvalidAmount = errors.New("amounts can not be negative")
+)
+
+// Current returns the open shift of an operator or gorm.ErrRecordNotFound.
+func Current(db *gorm.DB, operator string) (modelshift.Shift, error) {
+	var s modelshift.Shift
+	err := db.Where("operator = ? AND status = ?", operator, modelshift.StatusOpen).Order("id DESC").First(&s).Error
+	return s, err
+}
+
+// Open starts a shift with the cash that is in the till.
+func Open(db *gorm.DB, operator, parkNo string, openingFloat int64, at time.Time) (modelshift.Shift, error) {
+	if openingFloat < 0 {
+		return modelshift.Shift{}, ErrInvalidAmount
+	}
+	if _, err := Current(db, operator); err == nil {
+		return modelshift.Shift{}, ErrShiftOpen
+	}
+
+	s := modelshift.Shift{
+		Operator:     operator,
+		ParkNo:       parkNo,
+		Status:       modelshift.StatusOpen,
+		OpenedAt:     sitetime.From(at),
+		OpeningFloat: openingFloat,
+	}
+	err := db.Create(&s).Error
+	return s, err
+}
+
+// ExpectedCash is the opening float plus the net cash taken in the shift.
+func ExpectedCash(db *gorm.DB, s modelshift.Shift) (int64, error) {
+	cash, err := ledger.Sum(db.Model(&modelpayment.Payment{}).
+		Where("shift_id = ? AND method = ?", s.Id, modelpayment.Cash))
+	if err != nil {
+		return 0, err
+	}
+	return s.OpeningFloat + cash, nil
+}
+
+// Close ends an open shift. When denominations are given the declared cash
+// is their sum.
+func Close(db *gorm.DB, s modelshift.Shift, declared int64, denominations []modelshift.Denomination, note string, at time.Time) (modelshift.Shift, error) {
+	if s.Status != modelshift.StatusOpen {
+		return s, ErrNotOpen
+	}
+	if len(denominations) > 0 {
+		declared = 0
+		for _, d := range denominations {
+			if d.Value < 0 || d.Count < 0 {
+				return s, ErrInvalidAmount
+			}
+			declared += d.Value * int64(d.Count)
+		}
+	}
+	if declared < 0 {
+		return s, ErrInvalidAmount
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		expected, err := ExpectedCash(tx, s)
+		if err != nil {
+			return err
+		}
+
+		s.Status = modelshift.StatusClosed
+		s.ClosedAt = sitetime.From(at)
+		s.ExpectedCash = expected
+		s.DeclaredCash = declared
+		s.Variance = declared - expected
+		s.Note = note
+		s.Denominations = nil
+		for _, d := range denominations {
+			s.Denominations = append(s.Denominations, modelshift.Denomination{Value: d.Value, Count: d.Count})
+		}
+		return tx.Save(&s).Error
+	})
+	return s, err
+}
+
+// Approve locks a closed shift on behalf of an accountant.
+func Approve(db *gorm.DB, id int64, accountant string, at time.Time) (modelshift.Shift, error) {
+	var s modelshift.Shift
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&s, id).Error; err != nil {
+			return err
+		}
+		switch s.Status {
+		case modelshift.StatusApproved:
+			return ErrLocked
+		case modelshift.StatusOpen:
+			return ErrNotClosed
+		}
+
+		s.Status = modelshift.StatusApproved
+		s.ApprovedBy = accountant
+		s.ApprovedAt = sitetime.From(at)
+		return tx.Model(&s).Select("status", "approved_by", "approved_at").Updates(&s).Error
+	})
+	return s, err
+}
@@ -0,0 +1,79 @@
+package twofactor
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+const (
+	challengeTTL      = 5 * time.Minute
+	challengeAttempts = 5
+)
+
+// Challenge is a login whose password was right and that waits for the
+// second factor. Setup is set when the user still has to enroll.
+type Challenge struct {
+	UserId   int
+	ParkNo   string
+	Setup    bool
+	expires  time.Time
+	attempts int
+}
+
+var (
+	challengesMu sync.Mutex
+	challenges   = make(map[string]*Challenge)
+)
+
+// NewChallenge starts the second step of a login and returns its token.
+func NewChallenge(userID int, parkNo string, setup bool) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	challengesMu.Lock()
+	defer challengesMu.Unlock()
+	now := time.Now()
+	for t, ch := range challenges {
+		if now.After(ch.expires) {
+			delete(challenges, t)
+		}
+	}
+	challenges[token] = &Challenge{UserId: userID, ParkNo: parkNo, Setup: setup, expires: now.Add(challengeTTL)}
+	return token, nil
+}
+
+// GetChallenge returns the challenge of token while it is valid.
+func GetChallenge(token string) (Challenge, bool) {
+	challengesMu.Lock()
+	defer challengesMu.Unlock()
+	ch, ok := challenges[token]
+	if !ok || time.Now().After(ch.expires) {
+		return Challenge{}, false
+	}
+	return *ch, true
+}
+
+// FailChallenge counts a wrong code. The challenge is dropped after a few,
+// and the login has to start again with the password.
+func FailChallenge(token string) {
+	challengesMu.Lock()
+	defer challengesMu.Unlock()
+	if ch, ok := challenges[token]; ok {
+		ch.attempts++
+		if ch.attempts >= challengeAttempts {
+			delete(challenges, token)
+		}
+	}
+}
+
+// EndChallenge drops a challenge once the login is complete.
+func EndChallenge(token string) {
+	challengesMu.Lock()
+	defer challengesMu.Unlock()
+	delete(challenges, token)
+}
@@ -0,0 +1,285 @@
+// Package twofactor adds TOTP codes to the login of users. A user enrolls
+// by scanning a QR code and confirming a first code, and gets recovery
+// codes for when the authenticator is lost. Roles listed in
+// TWO_FACTOR_REQUIRED_ROLES cannot log in without it.
+package twofactor
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"image/png"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	modelsuser "park/models/modelsUser"
+	"park/service/secrets"
+	"park/util/sitetime"
+)
+
+const (
+	Issuer        = "Park"
+	period        = 30
+	skew          = 1
+	recoveryCount = 10
+)
+
+var (
+	ErrInvalidCode    = errors.New("invalid two-factor code")
+	ErrNotEnrolled    = errors.New("two-factor authentication is not set up")
+	ErrAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrRequired       = errors.New("two-factor authentication is required for the role")
+)
+
+// Enrollment is what an authenticator app needs to add the account.
+type Enrollment struct {
+	Secret string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	URI    string `json:"uri" example:"otpauth://totp/Park:admin?issuer=Park&secret=JBSWY3DPEHPK3PXP"`
+	QR     string `json:"qr" example:"data:image/png;base64,iVBORw0KGgo..."`
+}
+
+// Required reports whether users of role must use two-factor
+// authentication. By default admins and accountants must.
+func Required(role modelsuser.RoleType) bool {
+	roles, ok := os.LookupEnv("TWO_FACTOR_REQUIRED_ROLES")
+	if !ok {
+		roles = "admin,accountant"
+	}
+	for _, r := range strings.Split(roles, ",") {
+		if strings.TrimSpace(r) == string(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled reports whether user has confirmed an enrollment.
+func Enabled(db *gorm.DB, userID int) (bool, error) {
+	var count int64
+	err := db.Model(&modelsuser.TwoFactor{}).Where("user_id = ? AND enabled", userID).Count(&count).Error
+	return count > 0, err
+}
+
+// RecoveryCodesLeft counts the unused recovery codes of a user.
+func RecoveryCodesLeft(db *gorm.DB, userID int) (int64, error) {
+	var count int64
+	err := db.Model(&modelsuser.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// Setup creates a new secret for user. It takes effect once Enable
+// confirms a code of it.
+func Setup(db *gorm.DB, user modelsuser.User) (Enrollment, error) {
+	enabled, err := Enabled(db, user.Id)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	if enabled {
+		return Enrollment{}, ErrAlreadyEnabled
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      Issuer,
+		AccountName: user.Username,
+		Period:      period,
+	})
+	if err != nil {
+		return Enrollment{}, err
+	}
+	sealed, err := secrets.Seal(secretName(user.Id), key.Secret())
+	if err != nil {
+		return Enrollment{}, err
+	}
+	err = db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&modelsuser.TwoFactor{
+		UserId: user.Id,
+		Secret: sealed,
+	}).Error
+	if err != nil {
+		return Enrollment{}, err
+	}
+
+	qr, err := qrPNG(key)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	return Enrollment{
+		Secret: key.Secret(),
+		URI:    key.URL(),
+		QR:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(qr),
+	}, nil
+}
+
+// Enable confirms the enrollment of a user with a code of the new secret
+// and returns the recovery codes, which are shown only this once.
+func Enable(db *gorm.DB, userID int, code string, now time.Time) ([]string, error) {
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		tf, err := lock(tx, userID)
+		if err != nil {
+			return err
+		}
+		if tf.Enabled {
+			return ErrAlreadyEnabled
+		}
+		step, err := checkTOTP(tf, code, now)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&tf).Updates(map[string]interface{}{
+			"enabled":    true,
+			"enabled_at": sitetime.From(now),
+			"last_step":  step,
+		}).Error; err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// Verify checks a TOTP code or an unused recovery code of a user with two
+// factor authentication enabled. Every code works once.
+func Verify(db *gorm.DB, userID int, code string, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		tf, err := lock(tx, userID)
+		if err != nil {
+			return err
+		}
+		if !tf.Enabled {
+			return ErrNotEnrolled
+		}
+
+		step, err := checkTOTP(tf, code, now)
+		if err == nil {
+			return tx.Model(&tf).Update("last_step", step).Error
+		}
+		if !errors.Is(err, ErrInvalidCode) {
+			return err
+		}
+
+		result := tx.Model(&modelsuser.RecoveryCode{}).
+			Where("user_id = ? AND hash = ? AND used_at IS NULL", userID, hashCode(code)).
+			Update("used_at", sitetime.From(now))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidCode
+		}
+		return nil
+	})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user.
+func RegenerateRecoveryCodes(db *gorm.DB, userID int) ([]string, error) {
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// Disable removes the enrollment and the recovery codes of a user.
+func Disable(db *gorm.DB, userID int) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&modelsuser.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&modelsuser.TwoFactor{}).Error
+	})
+}
+
+func lock(tx *gorm.DB, userID int) (modelsuser.TwoFactor, error) {
+	var tf modelsuser.TwoFactor
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&tf).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return tf, ErrNotEnrolled
+	}
+	return tf, err
+}
+
+// checkTOTP returns the time step of code when it is valid within the
+// allowed clock skew and newer than the last accepted one.
+func checkTOTP(tf modelsuser.TwoFactor, code string, now time.Time) (int64, error) {
+	code = strings.TrimSpace(code)
+	if len(code) != otp.DigitsSix.Length() {
+		return 0, ErrInvalidCode
+	}
+	secret, err := secrets.Open(secretName(tf.UserId), tf.Secret)
+	if err != nil {
+		return 0, err
+	}
+
+	current := now.Unix() / period
+	for step := current - skew; step <= current+skew; step++ {
+		if step <= tf.LastStep {
+			continue
+		}
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*period, 0), totp.ValidateOpts{
+			Period:    period,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, nil
+		}
+	}
+	return 0, ErrInvalidCode
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID int) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&modelsuser.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCount)
+	rows := make([]modelsuser.RecoveryCode, recoveryCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes[i] = code[:4] + "-" + code[4:]
+		rows[i] = modelsuser.RecoveryCode{UserId: userID, Hash: hashCode(codes[i])}
+	}
+	return codes, tx.Create(&rows).Error
+}
+
+// hashCode hashes a recovery code, ignoring case and dashes.
+func hashCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func secretName(userID int) string {
+	return "totp." + strconv.Itoa(userID)
+}
+
+func qrPNG(key *otp.Key) ([]byte, error) {
+	img, err := key.Image(256, 256)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = png.Encode(&buf, img)
+	return buf.Bytes(), err
+}
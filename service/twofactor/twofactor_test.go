@@ -0,0 +1,54 @@
+package twofactor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+
+	modelsuser "park/models/modelsUser"
+	"park/service/secrets"
+)
+
+func TestCheckTOTP(t *testing.T) {
+	t.Setenv("SECRET_STORE_KEY", "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: Issuer, AccountName: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := secrets.Seal(secretName(7), key.Secret())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tf := modelsuser.TwoFactor{UserId: 7, Secret: sealed}
+
+	now := time.Date(2025, 1, 29, 10, 0, 0, 0, time.UTC)
+	code, err := totp.GenerateCode(key.Secret(), now.Add(-period*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step, err := checkTOTP(tf, code, now)
+	if err != nil {
+		t.Fatalf("code of the previous step was refused: %v", err)
+	}
+	if want := now.Unix()/period - 1; step != want {
+		t.Fatalf("step = %d, want %d", step, want)
+	}
+
+	tf.LastStep = step
+	if _, err := checkTOTP(tf, code, now); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("reused code returned %v, want ErrInvalidCode", err)
+	}
+	if _, err := checkTOTP(tf, code, now.Add(5*time.Minute)); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("old code returned %v, want ErrInvalidCode", err)
+	}
+}
+
+func TestHashCodeIgnoresCaseAndDashes(t *testing.T) {
+	if hashCode("abcd-efgh") != hashCode(" ABCDEFGH ") {
+		t.Fatal("recovery codes differ by case or dashes")
+	}
+}
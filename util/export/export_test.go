@@ -0,0 +1,137 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type sheetXML struct {
+	Rows []struct {
+		Cells []struct {
+			Type   string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readSheet(t *testing.T, data []byte) sheetXML {
+	t.Helper()
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("workbook is not a zip archive: %v", err)
+	}
+
+	names := make(map[string]bool)
+	var sheet sheetXML
+	for _, f := range r.File {
+		names[f.Name] = true
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		body, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", f.Name, err)
+		}
+		// Every part must be well-formed XML.
+		var v struct{}
+		if err := xml.Unmarshal(body, &v); err != nil {
+			t.Fatalf("%s is not valid XML: %v", f.Name, err)
+		}
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			if err := xml.Unmarshal(body, &sheet); err != nil {
+				t.Fatalf("sheet: %v", err)
+			}
+		}
+	}
+	for _, part := range xlsxParts {
+		if !names[part.name] {
+			t.Errorf("workbook has no %s", part.name)
+		}
+	}
+	if !names["xl/worksheets/sheet1.xml"] {
+		t.Fatal("workbook has no sheet")
+	}
+	return sheet
+}
+
+func TestXLSXRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewXLSX(&buf)
+	if err != nil {
+		t.Fatalf("NewXLSX: %v", err)
+	}
+	amount := 12.5
+	var missing *int
+	rows := [][]interface{}{
+		{"Plate", "Fee", "Paid"},
+		{`<AG & "1234">`, amount, true},
+		{"  spaced  ", 42, false},
+		{&amount, missing, nil},
+	}
+	for _, row := range rows {
+		if err := w.Row(row...); err != nil {
+			t.Fatalf("Row: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	sheet := readSheet(t, buf.Bytes())
+	if len(sheet.Rows) != len(rows) {
+		t.Fatalf("got %d rows, want %d", len(sheet.Rows), len(rows))
+	}
+
+	type cell struct{ typ, value string }
+	want := [][]cell{
+		{{"inlineStr", "Plate"}, {"inlineStr", "Fee"}, {"inlineStr", "Paid"}},
+		{{"inlineStr", `<AG & "1234">`}, {"", "12.5"}, {"b", "1"}},
+		{{"inlineStr", "  spaced  "}, {"", "42"}, {"b", "0"}},
+		{{"", "12.5"}, {"inlineStr", ""}, {"inlineStr", ""}},
+	}
+	for i, row := range sheet.Rows {
+		var got []cell
+		for _, c := range row.Cells {
+			value := c.Value
+			if c.Type == "inlineStr" {
+				value = c.Inline
+			}
+			got = append(got, cell{c.Type, value})
+		}
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("row %d = %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+func TestCSV(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCSV(&buf)
+	w.Row("Plate", "Fee")
+	w.Row("AG,1234", 2.5)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	body, ok := strings.CutPrefix(buf.String(), "\ufeff")
+	if !ok {
+		t.Fatal("CSV has no byte order mark")
+	}
+	records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV is not readable: %v", err)
+	}
+	want := [][]string{{"Plate", "Fee"}, {"AG,1234", "2.5"}}
+	if !reflect.DeepEqual(records, want) {
+		t.Fatalf("got %v, want %v", records, want)
+	}
+}
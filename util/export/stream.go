@@ -0,0 +1,63 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Stream sends the records selected by query as a CSV or XLSX attachment,
+// depending on the format query parameter. Records are read from the
+// database and written to the client one at a time; row turns a record
+// into the cells under header.
+func Stream[T any](c *fiber.Ctx, query *gorm.DB, name string, header []string, row func(T) []interface{}) error {
+	format := c.Query("format", "csv")
+	contentType, err := ContentType(format)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to query the export", "details": err.Error()})
+	}
+
+	c.Set("Content-Type", contentType)
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", name, format))
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer rows.Close()
+
+		out, err := New(format, w)
+		if err == nil {
+			err = out.Row(toCells(header)...)
+		}
+		for err == nil && rows.Next() {
+			var record T
+			if err = query.ScanRows(rows, &record); err == nil {
+				err = out.Row(row(record)...)
+			}
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+		if err == nil {
+			err = out.Close()
+		}
+		if err != nil {
+			log.Println("Export of", name, "failed:", err)
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+func toCells(values []string) []interface{} {
+	cells := make([]interface{}, len(values))
+	for i, v := range values {
+		cells[i] = v
+	}
+	return cells
+}
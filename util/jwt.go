@@ -4,25 +4,26 @@ import (
 	"fmt"
 	"os"
 	modelsuser "park/models/modelsUser"
+	"park/service/session"
 	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-func CreateJWT(userID int, username string, role modelsuser.RoleType, parkno string, macusername string, macpassword string, keys string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+// CreateJWT signs an access token of a session. It expires after
+// session.AccessTTL and is renewed with the refresh token of the session.
+func CreateJWT(userID int, username string, role modelsuser.RoleType, parkno, sessionID string) (string, error) {
+	expirationTime := time.Now().Add(session.AccessTTL)
 
 	secretKey := os.Getenv("SECRET_KEY_JWT")
 	claims := jwt.MapClaims{
-		"user_id":     strconv.Itoa(userID),
-		"username":    username,
-		"role":        role,
-		"parkno":      parkno,
-		"macusername": macusername,
-		"macpassword": macpassword,
-		"keys":        keys,
-		"exp":         expirationTime.Unix(),
+		"user_id":  strconv.Itoa(userID),
+		"username": username,
+		"role":     role,
+		"parkno":   parkno,
+		"sid":      sessionID,
+		"exp":      expirationTime.Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
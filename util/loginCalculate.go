@@ -3,21 +3,22 @@ package util
 import (
 	"fmt"
 	"log"
-	"park/config"
 	"park/database"
-	modelscar "park/models/modelsCar"
 	modelsuser "park/models/modelsUser"
 	modeloperator "park/models/operatorModel"
+	modelpayment "park/models/paymentModel"
+	"park/service/ledger"
+	"park/util/sitetime"
 	"time"
 )
 
 func LoginMath(username string, role string, park string) error {
-	now := time.Now().Format(config.TimeFormat)
+	now := sitetime.Now()
 
 	login := modeloperator.Operator{
 		Operator: username,
 		Park:     park,
-		LoginAt:  now,
+		LoginAt:  sitetime.From(now),
 	}
 	if role == string(modelsuser.OperatorRole) {
 		if err := database.DB.Create(&login).Error; err != nil {
@@ -29,7 +30,7 @@ func LoginMath(username string, role string, park string) error {
 }
 
 func LoginOut(username string, role string) error {
-	now := time.Now().Add(time.Minute).Format(config.TimeFormat)
+	now := sitetime.Now().Add(time.Minute)
 
 	if role == string(modelsuser.OperatorRole) {
 		var lastLogin modeloperator.Operator
@@ -37,7 +38,7 @@ func LoginOut(username string, role string) error {
 		if err := database.DB.Where("operator = ? ", username).Order("id DESC").First(&lastLogin).Error; err != nil {
 			return err
 		}
-		lastLogin.LogoutAt = now
+		lastLogin.LogoutAt = sitetime.From(now)
 		if err := database.DB.Save(&lastLogin).Error; err != nil {
 			return err
 		}
@@ -46,46 +47,32 @@ func LoginOut(username string, role string) error {
 	return nil
 }
 
+// CalculateV2 closes the session of an operator and stores the net amount
+// taken so far in the operator's open shift, in whole currency units. The
+// shift itself stays open until it is closed explicitly.
 func CalculateV2(username string, role string) (int, error) {
-	now := time.Now().Format(config.TimeFormat)
+	now := sitetime.Now()
 
-	var calculations []modelscar.Car_Model
 	var totalPayment float64
 
 	if role == string(modelsuser.OperatorRole) {
-		if err := database.DB.Where("user_id = ? AND pay_status = true", username).Find(&calculations).Error; err != nil {
-			log.Println("Error fetching car models for user:", username, "Error:", err)
-			return 0, err
-		}
-
-		if len(calculations) == 0 {
-			return 0, fmt.Errorf("no records found for user %s", username)
-		}
-
-		for _, car := range calculations {
-			totalPayment += car.Total_payment
-		}
-
-		if err := database.DB.Model(&modelscar.Car_Model{}).
-			Where("user_id = ? AND pay_status = true", username).
-			Update("pay_status", false).Error; err != nil {
-			log.Println("Failed to update paystatus for user:", username, "Error:", err)
-			return 0, err
-		}
-
 		var operator modeloperator.Operator
 		if err := database.DB.Where("operator = ?", username).Order("id DESC").First(&operator).Error; err != nil {
 			log.Println("Operator not found for user:", username, "Error:", err)
 			return 0, fmt.Errorf("operator not found for user %s", username)
 		}
 
-		if err := database.DB.Model(&operator).
-			Update("money", totalPayment).Error; err != nil {
-			log.Println("Failed to update operator money for user:", username, "Error:", err)
-			return 0, err
+		if shiftID := ledger.CurrentShift(database.DB, username); shiftID != nil {
+			total, err := ledger.Sum(database.DB.Model(&modelpayment.Payment{}).Where("shift_id = ?", *shiftID))
+			if err != nil {
+				log.Println("Error summing payments for user:", username, "Error:", err)
+				return 0, err
+			}
+			totalPayment = ledger.FromMinor(total)
 		}
 
-		operator.LogoutAt = now
+		operator.Money = int(totalPayment)
+		operator.LogoutAt = sitetime.From(now)
 		if err := database.DB.Save(&operator).Error; err != nil {
 			log.Println("Failed to update operator logout time for user:", username, "Error:", err)
 			return 0, err
@@ -0,0 +1,51 @@
+package util
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"park/database"
+	"park/models/camera"
+	modelpark "park/models/parkModel"
+)
+
+// ErrUnknownChannel is returned for cameras that are not assigned to a park.
+var ErrUnknownChannel = errors.New("camera is not assigned to a park")
+
+// ParkByChannel returns the park of the camera with the given Macroscop
+// channel id.
+func ParkByChannel(channelId string) (modelpark.Park, error) {
+	var park modelpark.Park
+	if channelId == "" {
+		return park, ErrUnknownChannel
+	}
+
+	var cam camera.CamFix
+	if err := database.DB.Where("channel_id = ?", channelId).First(&cam).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return park, ErrUnknownChannel
+		}
+		return park, err
+	}
+	if cam.ParkId == nil {
+		return park, ErrUnknownChannel
+	}
+
+	err := database.DB.First(&park, *cam.ParkId).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return park, ErrUnknownChannel
+	}
+	return park, err
+}
+
+// ParkLocation returns the time zone of the park with code. Without a code,
+// or for a park that is not found, the site time zone is used.
+func ParkLocation(code string) *time.Location {
+	var park modelpark.Park
+	if code != "" && database.DB != nil {
+		database.DB.Where("code = ?", code).Limit(1).Find(&park)
+	}
+	return park.Location()
+}
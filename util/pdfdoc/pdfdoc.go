@@ -0,0 +1,82 @@
+// Package pdfdoc creates PDF documents with an embedded DejaVu font, which
+// has the Turkmen letters (ň, ç, ş, ž, ä, ý, ü) that the core PDF fonts lack,
+// and renders them in memory.
+package pdfdoc
+
+import (
+	"bytes"
+	_ "embed"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Font is the family name the embedded font is registered under.
+const Font = "DejaVu"
+
+var (
+	//go:embed fonts/DejaVuSansCondensed.ttf
+	regular []byte
+	//go:embed fonts/DejaVuSansCondensed-Bold.ttf
+	bold []byte
+)
+
+// New returns an A4 document in orientation "P" or "L" with the embedded
+// font selected.
+func New(orientation string) *gofpdf.Fpdf {
+	pdf := gofpdf.New(orientation, "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(Font, "", regular)
+	pdf.AddUTF8FontFromBytes(Font, "B", bold)
+	pdf.SetFont(Font, "", 10)
+	return pdf
+}
+
+// Title writes a bold heading followed by a line of smaller text.
+func Title(pdf *gofpdf.Fpdf, title, subtitle string) {
+	pdf.SetFont(Font, "B", 14)
+	pdf.CellFormat(0, 10, title, "", 1, "", false, 0, "")
+	if subtitle != "" {
+		pdf.SetFont(Font, "", 9)
+		pdf.CellFormat(0, 6, subtitle, "", 1, "", false, 0, "")
+	}
+	pdf.Ln(4)
+	pdf.SetFont(Font, "", 10)
+}
+
+// Table writes a bordered table. The header, if any, is repeated on every
+// page the table runs over.
+func Table(pdf *gofpdf.Fpdf, widths []float64, header []string, rows [][]string) {
+	writeHeader := func() {
+		if header == nil {
+			return
+		}
+		pdf.SetFont(Font, "B", 9)
+		for i, h := range header {
+			pdf.CellFormat(widths[i], 7, h, "1", 0, "C", false, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont(Font, "", 9)
+	}
+
+	writeHeader()
+	pdf.SetFont(Font, "", 9)
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	for _, row := range rows {
+		if pdf.GetY()+7 > pageHeight-bottom {
+			pdf.AddPage()
+			writeHeader()
+		}
+		for i, value := range row {
+			pdf.CellFormat(widths[i], 7, value, "1", 0, "", false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.SetFont(Font, "", 10)
+}
+
+// Bytes renders the document.
+func Bytes(pdf *gofpdf.Fpdf) ([]byte, error) {
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	return buf.Bytes(), err
+}
@@ -0,0 +1,115 @@
+// Package plate normalizes licence plates read by the cameras so that entry
+// and exit reads of the same car compare equal, and offers an edit distance
+// for matching reads that still differ.
+package plate
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// lookalikes folds Cyrillic letters that look like Latin ones and Turkmen
+// letters with diacritics to the plain Latin letters used on plates.
+var lookalikes = map[rune]rune{
+	'А': 'A', 'В': 'B', 'Е': 'E', 'Ё': 'E', 'К': 'K', 'М': 'M', 'Н': 'H',
+	'О': 'O', 'Р': 'P', 'С': 'C', 'Т': 'T', 'У': 'Y', 'Х': 'X', 'І': 'I',
+	'Ä': 'A', 'Ç': 'C', 'Ň': 'N', 'Ö': 'O', 'Ş': 'S', 'Ü': 'U', 'Ý': 'Y', 'Ž': 'Z',
+}
+
+// Positions of a Turkmen plate (two series letters, four digits and a two
+// letter region code) only hold letters or only digits, so OCR confusions
+// between the two can be undone.
+var (
+	letterToDigit = map[rune]rune{'O': '0', 'D': '0', 'Q': '0', 'I': '1', 'L': '1', 'Z': '2', 'S': '5', 'G': '6', 'T': '7', 'B': '8'}
+	digitToLetter = map[rune]rune{'0': 'O', '1': 'I', '2': 'Z', '5': 'S', '6': 'G', '7': 'T', '8': 'B'}
+
+	turkmenPlate = regexp.MustCompile(`^[A-Z]{2}[0-9]{4}(AG|AH|BN|DZ|LB|MR)$`)
+)
+
+const turkmenLayout = "LLDDDDLL"
+
+// Normalize upper-cases a plate, folds look-alike letters, drops separators
+// and fixes letter/digit confusions when the result fits the Turkmen format.
+func Normalize(raw string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(strings.TrimSpace(raw)) {
+		if folded, ok := lookalikes[r]; ok {
+			r = folded
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	folded := b.String()
+
+	if fixed := fixLayout(folded); turkmenPlate.MatchString(fixed) {
+		return fixed
+	}
+	return folded
+}
+
+// Valid reports whether a normalized plate has the Turkmen plate format.
+func Valid(plate string) bool {
+	return turkmenPlate.MatchString(plate)
+}
+
+func fixLayout(plate string) string {
+	runes := []rune(plate)
+	if len(runes) != len(turkmenLayout) {
+		return plate
+	}
+	for i, kind := range turkmenLayout {
+		r := runes[i]
+		if kind == 'D' {
+			if d, ok := letterToDigit[r]; ok {
+				runes[i] = d
+			}
+		} else if l, ok := digitToLetter[r]; ok {
+			runes[i] = l
+		}
+	}
+	return string(runes)
+}
+
+// Distance returns the Levenshtein distance between two plates.
+func Distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// Closest returns the index of the candidate nearest to plate. It fails when
+// no candidate is within maxDistance or when the best distance is shared,
+// because an ambiguous guess must not close somebody else's visit.
+func Closest(plate string, candidates []string, maxDistance int) (int, int, bool) {
+	best, bestDistance, tie := -1, maxDistance+1, false
+	for i, candidate := range candidates {
+		d := Distance(plate, candidate)
+		switch {
+		case d < bestDistance:
+			best, bestDistance, tie = i, d, false
+		case best >= 0 && d == bestDistance && candidate != candidates[best]:
+			tie = true
+		}
+	}
+	if best < 0 || tie {
+		return -1, 0, false
+	}
+	return best, bestDistance, true
+}
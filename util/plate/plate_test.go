@@ -0,0 +1,82 @@
+package plate
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		raw, want string
+	}{
+		{"AG1234AG", "AG1234AG"},
+		{" ag 1234 ag ", "AG1234AG"},
+		{"AG-1234-AG", "AG1234AG"},
+		// Cyrillic look-alikes and Turkmen letters.
+		{"АВ1234АG", "AB1234AG"},
+		{"Ýa5678mr", "YA5678MR"},
+		// Letter and digit confusions in the Turkmen layout.
+		{"AG12O4AG", "AG1204AG"},
+		{"A61234AG", "AG1234AG"},
+		{"BE5O84AG", "BE5084AG"},
+		// Plates that do not fit the layout are only folded.
+		{"O1234", "O1234"},
+		{"ABC123", "ABC123"},
+		{"", ""},
+	} {
+		if got := Normalize(tc.raw); got != tc.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tc.raw, got, tc.want)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"AG1234AG", "AG1234AG", 0},
+		{"AG1234AG", "AG1284AG", 1},
+		{"AG1234AG", "AG134AG", 1},
+		{"AG1234AG", "AG12345AG", 1},
+		{"AG1234AG", "GA1234AG", 2},
+		{"", "AG12", 4},
+		{"ÝA", "YA", 1},
+	} {
+		if got := Distance(tc.a, tc.b); got != tc.want {
+			t.Errorf("Distance(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+		if got := Distance(tc.b, tc.a); got != tc.want {
+			t.Errorf("Distance(%q, %q) = %d, want %d", tc.b, tc.a, got, tc.want)
+		}
+	}
+}
+
+func TestClosest(t *testing.T) {
+	candidates := []string{"AG1234AG", "BE5084AG", "MR7777MR"}
+
+	for _, tc := range []struct {
+		plate    string
+		max      int
+		index    int
+		distance int
+		ok       bool
+	}{
+		{"AG1234AG", 2, 0, 0, true},
+		{"BE5O84AG", 2, 1, 1, true},
+		{"MR7177LB", 2, -1, 0, false},
+		{"MR7177LB", 3, 2, 3, true},
+	} {
+		index, distance, ok := Closest(tc.plate, candidates, tc.max)
+		if index != tc.index || distance != tc.distance || ok != tc.ok {
+			t.Errorf("Closest(%q, %d) = %d, %d, %v, want %d, %d, %v",
+				tc.plate, tc.max, index, distance, ok, tc.index, tc.distance, tc.ok)
+		}
+	}
+
+	// Two visits at the same distance are ambiguous.
+	if _, _, ok := Closest("AG1234AG", []string{"AG1235AG", "AG1236AG"}, 2); ok {
+		t.Error("Closest picked one of two equally near plates")
+	}
+	// The same plate twice is not a tie.
+	if index, _, ok := Closest("AG1234AG", []string{"AG1235AG", "AG1235AG"}, 2); !ok || index != 0 {
+		t.Errorf("Closest over duplicates = %d, %v, want 0, true", index, ok)
+	}
+}
@@ -0,0 +1,152 @@
+package util
+
+import (
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"park/database"
+	"park/models/tarif"
+)
+
+const minutesPerDay = 1440
+
+var (
+	tariffPlans []tarif.Plan
+	tariffMutex sync.RWMutex
+)
+
+// LegacyPlan reproduces the fee that used to be hardcoded in the exit
+// handlers: 2 up to 6 hours, 3 up to a day, then 3 per started day.
+func LegacyPlan() tarif.Plan {
+	return tarif.Plan{
+		Name:          "Standard",
+		Active:        true,
+		DailyCap:      3,
+		PerStartedDay: true,
+		LostTicketFee: 3,
+		Bands: []tarif.Band{
+			{UpToMinutes: 360, Price: 2},
+			{UpToMinutes: minutesPerDay, Price: 3},
+		},
+	}
+}
+
+func LoadTariffPlans() {
+	var plans []tarif.Plan
+	// Ended plans are loaded too: they still price the visits that started
+	// before their end.
+	if err := database.DB.Preload("Bands").Where("active = ? OR valid_until IS NOT NULL", true).Order("valid_from").Find(&plans).Error; err != nil {
+		log.Println("Error occurred while loading tariff plans:", err)
+		return
+	}
+
+	if len(plans) == 0 {
+		var total int64
+		database.DB.Model(&tarif.Plan{}).Count(&total)
+		if total == 0 {
+			plan := LegacyPlan()
+			if err := database.DB.Create(&plan).Error; err != nil {
+				log.Println("Error occurred while creating default tariff plan:", err)
+				return
+			}
+			plans = append(plans, plan)
+		}
+	}
+
+	if planAt(plans, time.Now()).Id == 0 {
+		log.Println("No active tariff plan, exits are priced with the standard fee")
+	}
+
+	tariffMutex.Lock()
+	tariffPlans = plans
+	tariffMutex.Unlock()
+	log.Println("Tariff plans loaded successfully!")
+}
+
+// PlanAt returns the plan in effect at the given time. Before the first
+// plan takes effect, or after every plan has ended, the legacy fee
+// applies, so exits are never left without a price.
+func PlanAt(at time.Time) tarif.Plan {
+	tariffMutex.RLock()
+	defer tariffMutex.RUnlock()
+	return planAt(tariffPlans, at)
+}
+
+// planAt picks from plans sorted by ValidFrom.
+func planAt(plans []tarif.Plan, at time.Time) tarif.Plan {
+	plan := LegacyPlan()
+	for _, p := range plans {
+		if !p.ValidFrom.After(at) && (p.ValidUntil == nil || at.Before(*p.ValidUntil)) {
+			plan = p
+		}
+	}
+	return plan
+}
+
+// QuoteFee prices a stay from start to end with the plan in effect at start.
+func QuoteFee(start, end time.Time) (float64, error) {
+	return QuotePlan(PlanAt(start), start, end), nil
+}
+
+// LostTicketFee returns the fee of an exit without entry at the given time.
+func LostTicketFee(at time.Time) (float64, error) {
+	return PlanAt(at).LostTicketFee, nil
+}
+
+// QuotePlan prices a stay from start to end with the given plan.
+func QuotePlan(plan tarif.Plan, start, end time.Time) float64 {
+	minutes := end.Sub(start).Minutes()
+	if minutes < 0 {
+		minutes = 0
+	}
+
+	bands := make([]tarif.Band, len(plan.Bands))
+	copy(bands, plan.Bands)
+	sort.Slice(bands, func(i, j int) bool {
+		return bands[i].UpToMinutes < bands[j].UpToMinutes
+	})
+
+	if minutes <= minutesPerDay {
+		return dayCharge(plan, bands, minutes)
+	}
+
+	fullDay := dayCharge(plan, bands, minutesPerDay)
+	if plan.PerStartedDay {
+		return math.Ceil(minutes/minutesPerDay) * fullDay
+	}
+
+	days := math.Floor(minutes / minutesPerDay)
+	total := days * fullDay
+	if rest := minutes - days*minutesPerDay; rest > 0 {
+		total += dayCharge(plan, bands, rest)
+	}
+	return total
+}
+
+// dayCharge prices up to one day of parking: the first matching band wins,
+// otherwise the first hour rate plus one increment per started period.
+func dayCharge(plan tarif.Plan, bands []tarif.Band, minutes float64) float64 {
+	price := -1.0
+	for _, band := range bands {
+		if minutes <= float64(band.UpToMinutes) {
+			price = band.Price
+			break
+		}
+	}
+
+	if price < 0 {
+		price = plan.FirstHourPrice
+		if minutes > 60 && plan.IncrementMinutes > 0 {
+			periods := math.Ceil((minutes - 60) / float64(plan.IncrementMinutes))
+			price += periods * plan.IncrementPrice
+		}
+	}
+
+	if plan.DailyCap > 0 && price > plan.DailyCap {
+		price = plan.DailyCap
+	}
+	return price
+}
@@ -0,0 +1,121 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"park/models/tarif"
+)
+
+var day = time.Date(2025, 1, 29, 0, 0, 0, 0, time.UTC)
+
+func TestQuotePlanBands(t *testing.T) {
+	plan := LegacyPlan()
+	start := day.Add(8 * time.Hour)
+
+	for _, tc := range []struct {
+		stay time.Duration
+		want float64
+	}{
+		{0, 2},
+		{6 * time.Hour, 2},
+		{6*time.Hour + time.Minute, 3},
+		{24 * time.Hour, 3},
+		{24*time.Hour + time.Minute, 6},
+		{72 * time.Hour, 9},
+		{-time.Hour, 2},
+	} {
+		if got := QuotePlan(plan, start, start.Add(tc.stay)); got != tc.want {
+			t.Errorf("stay of %v = %v, want %v", tc.stay, got, tc.want)
+		}
+	}
+}
+
+func TestQuotePlanIncrementsAndCap(t *testing.T) {
+	plan := tarif.Plan{FirstHourPrice: 1, IncrementMinutes: 30, IncrementPrice: 0.5, DailyCap: 4}
+	start := day.Add(9 * time.Hour)
+
+	for _, tc := range []struct {
+		stay time.Duration
+		want float64
+	}{
+		{45 * time.Minute, 1},
+		{61 * time.Minute, 1.5},
+		{2 * time.Hour, 2},
+		{10 * time.Hour, 4},
+		// Whole days at the cap, then the rest of the stay priced again.
+		{26 * time.Hour, 6},
+	} {
+		if got := QuotePlan(plan, start, start.Add(tc.stay)); got != tc.want {
+			t.Errorf("stay of %v = %v, want %v", tc.stay, got, tc.want)
+		}
+	}
+}
+
+func TestQuotePlanAcrossMidnight(t *testing.T) {
+	plan := LegacyPlan()
+	start := day.Add(23 * time.Hour)
+
+	// A night stay is priced by its length, not by the calendar days it
+	// touches.
+	if got := QuotePlan(plan, start, start.Add(2*time.Hour)); got != 2 {
+		t.Fatalf("23:00 to 01:00 = %v, want 2", got)
+	}
+	if got := QuotePlan(plan, start, start.Add(25*time.Hour)); got != 6 {
+		t.Fatalf("23:00 to 00:00 two days later = %v, want 6", got)
+	}
+}
+
+func TestPlanAtSwitchesPlans(t *testing.T) {
+	first := tarif.Plan{Id: 1, Name: "Winter", ValidFrom: day}
+	second := tarif.Plan{Id: 2, Name: "Summer", ValidFrom: day.AddDate(0, 5, 0)}
+	plans := []tarif.Plan{first, second}
+
+	for _, tc := range []struct {
+		at   time.Time
+		want string
+	}{
+		{day.Add(-time.Hour), LegacyPlan().Name},
+		{day, "Winter"},
+		{second.ValidFrom.Add(-time.Second), "Winter"},
+		{second.ValidFrom, "Summer"},
+		{second.ValidFrom.AddDate(1, 0, 0), "Summer"},
+	} {
+		if got := planAt(plans, tc.at); got.Name != tc.want {
+			t.Errorf("plan at %v = %q, want %q", tc.at, got.Name, tc.want)
+		}
+	}
+}
+
+func TestPlanAtKeepsEndedPlans(t *testing.T) {
+	ended := day.AddDate(0, 2, 0)
+	first := tarif.Plan{Id: 1, Name: "Winter", ValidFrom: day}
+	second := tarif.Plan{Id: 2, Name: "Promotion", ValidFrom: day.AddDate(0, 1, 0), ValidUntil: &ended}
+	plans := []tarif.Plan{first, second}
+
+	for _, tc := range []struct {
+		at   time.Time
+		want string
+	}{
+		{second.ValidFrom.Add(-time.Second), "Winter"},
+		{second.ValidFrom, "Promotion"},
+		{ended.Add(-time.Second), "Promotion"},
+		{ended, "Winter"},
+	} {
+		if got := planAt(plans, tc.at); got.Name != tc.want {
+			t.Errorf("plan at %v = %q, want %q", tc.at, got.Name, tc.want)
+		}
+	}
+
+	first.ValidUntil = &ended
+	if got := planAt([]tarif.Plan{first}, ended); got.Id != 0 {
+		t.Fatalf("plan after every plan ended = %q, want the legacy plan", got.Name)
+	}
+}
+
+func TestPlanAtWithoutPlans(t *testing.T) {
+	plan := planAt(nil, day)
+	if plan.Name != LegacyPlan().Name || len(plan.Bands) == 0 {
+		t.Fatalf("no active plan gave %+v, want the legacy plan", plan)
+	}
+}
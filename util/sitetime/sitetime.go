@@ -0,0 +1,132 @@
+// Package sitetime holds the time zone of the parking site and a timestamp
+// type that is stored as timestamptz but keeps the "2006-01-02 15:04:05"
+// JSON format the API has always used.
+package sitetime
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"park/config"
+)
+
+const defaultZone = "Asia/Ashgabat"
+
+var (
+	location     *time.Location
+	locationOnce sync.Once
+)
+
+// Location returns the site time zone configured by SITE_TIMEZONE.
+func Location() *time.Location {
+	locationOnce.Do(func() {
+		zone := os.Getenv("SITE_TIMEZONE")
+		if zone == "" {
+			zone = defaultZone
+		}
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			log.Println("Unknown SITE_TIMEZONE", zone, "- using the server time zone:", err)
+			loc = time.Local
+		}
+		location = loc
+	})
+	return location
+}
+
+// Now returns the current time in the site time zone.
+func Now() time.Time {
+	return time.Now().In(Location())
+}
+
+// Parse reads a "2006-01-02 15:04:05" value as site local time.
+func Parse(value string) (time.Time, error) {
+	return time.ParseInLocation(config.TimeFormat, value, Location())
+}
+
+// ParseDate reads a "2006-01-02" value as midnight in the site time zone.
+func ParseDate(value string) (time.Time, error) {
+	return ParseDateIn(value, Location())
+}
+
+// ParseDateIn reads a "2006-01-02" value as midnight in loc.
+func ParseDateIn(value string, loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02", value, loc)
+}
+
+// Time is a nullable timestamp. The zero value is stored as NULL and
+// rendered as an empty string.
+type Time struct {
+	time.Time
+}
+
+func From(t time.Time) Time {
+	return Time{Time: t}
+}
+
+func (Time) GormDataType() string {
+	return "timestamptz"
+}
+
+// String formats the time in the site time zone, or "" when unset.
+func (t Time) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(Location()).Format(config.TimeFormat)
+}
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *Time) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	if value == "" {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	parsed, err := Parse(value)
+	if err != nil {
+		parsed, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("invalid time %q, use %s", value, config.TimeFormat)
+		}
+	}
+	t.Time = parsed
+	return nil
+}
+
+func (t Time) Value() (driver.Value, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+	return t.Time, nil
+}
+
+func (t *Time) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		t.Time = time.Time{}
+	case time.Time:
+		t.Time = v
+	default:
+		return fmt.Errorf("cannot scan %T into sitetime.Time", value)
+	}
+	return nil
+}
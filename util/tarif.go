@@ -3,48 +3,66 @@ package util
 import (
 	"log"
 	"sync"
+	"time"
 
 	"park/database"
-
-	"github.com/bits-and-blooms/bloom/v3"
+	"park/models/tarif"
+	"park/util/plate"
 )
 
 var (
-	vipFilter *bloom.BloomFilter
-	vipMutex  sync.Mutex
+	vipIndex map[string][]tarif.Tarif
+	vipMutex sync.RWMutex
 )
 
+// LoadVIPPlates rebuilds the in-memory subscription index from the tarifs
+// table. It must be called again whenever a subscription changes.
 func LoadVIPPlates() {
-	vipMutex.Lock()
-	defer vipMutex.Unlock()
-
-	vipFilter = nil
-	vipFilter = bloom.NewWithEstimates(1000000, 0.01)
-	var plates []string
-	err := database.DB.Raw("SELECT plate FROM tarifs").Scan(&plates).Error
-	if err != nil {
+	var subscriptions []tarif.Tarif
+	if err := database.DB.Order("start_time").Find(&subscriptions).Error; err != nil {
 		log.Println("Error occurred while loading VIP plates:", err)
 		return
 	}
 
-	for _, plate := range plates {
-		vipFilter.AddString(plate)
+	index := make(map[string][]tarif.Tarif, len(subscriptions))
+	for _, sub := range subscriptions {
+		key := plate.Normalize(sub.Plate)
+		if key == "" {
+			continue
+		}
+		index[key] = append(index[key], sub)
 	}
+
+	vipMutex.Lock()
+	vipIndex = index
+	vipMutex.Unlock()
 	log.Println("VIP plates loaded successfully!")
 }
 
-func IsVIPPlate(plate string) bool {
-	if plate == "" {
-		return false
+// FindSubscription returns the subscription of a plate that is valid at the
+// given time. A zero End_time means the subscription never expires.
+func FindSubscription(number string, at time.Time) (tarif.Tarif, bool) {
+	key := plate.Normalize(number)
+	if key == "" {
+		return tarif.Tarif{}, false
 	}
 
-	vipMutex.Lock()
-	defer vipMutex.Unlock()
+	vipMutex.RLock()
+	defer vipMutex.RUnlock()
 
-	if vipFilter == nil {
-		log.Println("VIP Bloom Filter has not been loaded yet!")
-		return false
+	if vipIndex == nil {
+		log.Println("VIP index has not been loaded yet!")
+		return tarif.Tarif{}, false
 	}
 
-	return vipFilter.TestString(plate)
+	for _, sub := range vipIndex[key] {
+		if at.Before(sub.Start_time) {
+			continue
+		}
+		if !sub.End_time.IsZero() && at.After(sub.End_time) {
+			continue
+		}
+		return sub, true
+	}
+	return tarif.Tarif{}, false
 }